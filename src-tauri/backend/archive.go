@@ -0,0 +1,439 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Límites de importación para protegerse de archivos comprimidos maliciosos (zip bombs)
+const (
+	maxImportArchiveSize = 1 << 30 // tamaño del archivo subido
+	maxImportTotalSize   = 4 << 30 // suma de los tamaños descomprimidos
+	maxImportEntries     = 100000
+)
+
+// archiveEntry es una entrada de un zip o tar.gz, independiente del formato
+type archiveEntry struct {
+	name  string
+	isDir bool
+	mode  os.FileMode
+	size  int64
+	open  func() (io.Reader, error)
+}
+
+type ImportEvent struct {
+	Type    string `json:"type"` // "start", "progress", "error" o "done"
+	Path    string `json:"path,omitempty"`
+	Action  string `json:"action,omitempty"` // "created", "overwritten", "renamed" o "skipped"
+	NewPath string `json:"newPath,omitempty"`
+	Message string `json:"message,omitempty"`
+	Done    int    `json:"done,omitempty"`
+	Total   int    `json:"total,omitempty"`
+	Success bool   `json:"success,omitempty"`
+	// Totales por acción, solo en el evento "done"
+	Created     int `json:"created,omitempty"`
+	Overwritten int `json:"overwritten,omitempty"`
+	Renamed     int `json:"renamed,omitempty"`
+	Skipped     int `json:"skipped,omitempty"`
+}
+
+// exportHandler descarga una carpeta del workspace como zip o tar.gz respetando las reglas de ignore
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	dir := resolveWorkspacePath(q.Get("path"), q.Get("projectBaseDir"))
+	format := q.Get("format")
+	if format == "" {
+		format = "zip"
+	}
+	if format != "zip" && format != "tar.gz" {
+		http.Error(w, "Unsupported format: "+format, http.StatusBadRequest)
+		return
+	}
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		http.Error(w, "Not a directory", http.StatusBadRequest)
+		return
+	}
+	fmt.Printf("[BACK] /api/export '%s' as %s\n", dir, format)
+	// Las reglas de ignore del workspace también valen al exportar solo una de sus carpetas
+	opts := walkOptions{noIgnore: q.Get("noIgnore") == "true", ignoreRoot: workspaceRoot(q.Get("projectBaseDir"))}
+
+	name := filepath.Base(dir)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	var count int
+	if format == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		zw := zip.NewWriter(w)
+		err = walkWorkspaceFiles(dir, opts, func(absPath, relPath string, info fs.FileInfo) error {
+			header, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			header.Name = name + "/" + relPath
+			header.Method = zip.Deflate
+			dst, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			count++
+			return copyFileTo(dst, absPath)
+		})
+		if err == nil {
+			err = zw.Close()
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/gzip")
+		gw := gzip.NewWriter(w)
+		tw := tar.NewWriter(gw)
+		err = walkWorkspaceFiles(dir, opts, func(absPath, relPath string, info fs.FileInfo) error {
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = name + "/" + relPath
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			count++
+			return copyFileTo(tw, absPath)
+		})
+		if err == nil {
+			err = tw.Close()
+		}
+		if err == nil {
+			err = gw.Close()
+		}
+	}
+	if err != nil {
+		// Las cabeceras ya se enviaron: solo queda cortar la descarga
+		fmt.Printf("[BACK] Export of '%s' failed: %v\n", dir, err)
+		return
+	}
+	fmt.Printf("[BACK] Exported %d files from '%s'\n", count, dir)
+}
+
+// copyFileTo vuelca el contenido de un archivo en el writer
+func copyFileTo(dst io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+// readArchiveEntries recorre las entradas de un zip o tar.gz ya subido
+func readArchiveEntries(file io.ReaderAt, size int64, fn func(archiveEntry) error) error {
+	magic := make([]byte, 4)
+	if _, err := file.ReadAt(magic, 0); err != nil {
+		return fmt.Errorf("cannot read archive: %v", err)
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")) || bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		zr, err := zip.NewReader(file, size)
+		if err != nil {
+			return err
+		}
+		for _, zf := range zr.File {
+			zf := zf
+			entry := archiveEntry{
+				name:  zf.Name,
+				isDir: zf.FileInfo().IsDir(),
+				mode:  zf.Mode(),
+				size:  int64(zf.UncompressedSize64),
+				open:  func() (io.Reader, error) { return zf.Open() },
+			}
+			if entry.mode&os.ModeSymlink != 0 {
+				return fmt.Errorf("symlinks are not supported: %s", zf.Name)
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(io.NewSectionReader(file, 0, size))
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		tr := tar.NewReader(gr)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			entry := archiveEntry{
+				name: header.Name,
+				mode: os.FileMode(header.Mode).Perm(),
+				size: header.Size,
+				open: func() (io.Reader, error) { return tr, nil },
+			}
+			switch header.Typeflag {
+			case tar.TypeDir:
+				entry.isDir = true
+			case tar.TypeReg:
+			case tar.TypeXGlobalHeader:
+				continue
+			default:
+				return fmt.Errorf("unsupported entry type in archive: %s", header.Name)
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("unsupported archive format (expected zip or tar.gz)")
+}
+
+// archiveEntryTarget valida el nombre de una entrada y devuelve su ruta dentro de dest (protección contra zip slip)
+func archiveEntryTarget(dest, name string) (string, error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("unsafe path in archive: %s", name)
+	}
+	for _, part := range strings.Split(slashed, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("unsafe path in archive: %s", name)
+		}
+	}
+	target := filepath.Join(dest, filepath.FromSlash(path.Clean(slashed)))
+	if !isSubPath(dest, target) {
+		return "", fmt.Errorf("unsafe path in archive: %s", name)
+	}
+	// Un directorio ya existente dentro de dest puede ser un symlink que apunta fuera del workspace
+	if target != dest && !isSubPath(realPath(dest), realPath(filepath.Dir(target))) {
+		return "", fmt.Errorf("path in archive goes through a symlink outside the destination: %s", name)
+	}
+	return target, nil
+}
+
+// uniqueImportPath busca un nombre libre agregando " (n)" antes de la extensión
+func uniqueImportPath(target string) string {
+	ext := filepath.Ext(target)
+	if ext == filepath.Base(target) {
+		// Archivos ocultos como ".env" no tienen extensión
+		ext = ""
+	}
+	base := strings.TrimSuffix(target, ext)
+	for i := 1; ; i++ {
+		candidate := base + " (" + strconv.Itoa(i) + ")" + ext
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// writeImportedFile crea el archivo con el contenido de la entrada
+func writeImportedFile(target string, entry archiveEntry, remaining *int64) error {
+	src, err := entry.open()
+	if err != nil {
+		return err
+	}
+	if closer, ok := src.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	mode := entry.mode.Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode|0600)
+	if err != nil {
+		return err
+	}
+	// El tamaño declarado puede mentir: se corta al superar el total permitido
+	n, err := io.Copy(f, io.LimitReader(src, *remaining+1))
+	*remaining -= n
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && *remaining < 0 {
+		err = fmt.Errorf("archive exceeds the maximum uncompressed size")
+	}
+	if err != nil {
+		os.Remove(target)
+	}
+	return err
+}
+
+// importHandler extrae un zip o tar.gz subido (campo "archive") en una carpeta del workspace,
+// enviando el progreso como NDJSON
+func importHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportArchiveSize)
+	// Lo que supere 32 MB se guarda en un archivo temporal
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	upload, header, err := r.FormFile("archive")
+	if err != nil {
+		http.Error(w, "Missing archive file", http.StatusBadRequest)
+		return
+	}
+	defer upload.Close()
+
+	root := workspaceRoot(r.FormValue("projectBaseDir"))
+	dest := resolveWorkspacePath(r.FormValue("path"), r.FormValue("projectBaseDir"))
+	// Los archivos reemplazados van a la papelera de este workspace, así que el destino debe estar dentro
+	if !isSubPath(root, dest) {
+		http.Error(w, "Destination is outside the workspace: "+dest, http.StatusBadRequest)
+		return
+	}
+	conflict := r.FormValue("conflict")
+	if conflict == "" {
+		conflict = "skip"
+	}
+	if conflict != "skip" && conflict != "overwrite" && conflict != "rename" {
+		http.Error(w, "Invalid conflict policy: "+conflict, http.StatusBadRequest)
+		return
+	}
+	fmt.Printf("[BACK] /api/import '%s' (%d bytes) into '%s', conflict=%s\n", header.Filename, header.Size, dest, conflict)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	send := func(ev ImportEvent) {
+		enc.Encode(ev)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	fail := func(msg string) {
+		fmt.Println("[BACK] Import failed:", msg)
+		send(ImportEvent{Type: "done", Success: false, Message: msg})
+	}
+
+	// Primera pasada: validar todas las rutas y tamaños antes de escribir nada
+	total := 0
+	var declared int64
+	fromUI := isUIRequest(r)
+	err = readArchiveEntries(upload, header.Size, func(entry archiveEntry) error {
+		target, err := archiveEntryTarget(dest, entry.name)
+		if err != nil {
+			return err
+		}
+		if !fromUI && isWorkspaceConfigPath(target) {
+			return fmt.Errorf("%s: %s", entry.name, workspaceConfigReadOnly)
+		}
+		total++
+		declared += entry.size
+		if total > maxImportEntries {
+			return fmt.Errorf("archive has more than %d entries", maxImportEntries)
+		}
+		if declared > maxImportTotalSize {
+			return fmt.Errorf("archive exceeds the maximum uncompressed size")
+		}
+		return nil
+	})
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	send(ImportEvent{Type: "start", Total: total})
+
+	done := ImportEvent{Type: "done"}
+	remaining := int64(maxImportTotalSize)
+	lastFlush := time.Now()
+	n := 0
+	err = readArchiveEntries(upload, header.Size, func(entry archiveEntry) error {
+		n++
+		target, err := archiveEntryTarget(dest, entry.name)
+		if err != nil {
+			return err
+		}
+		rel := filepath.ToSlash(strings.TrimPrefix(strings.TrimPrefix(target, dest), string(filepath.Separator)))
+		if entry.isDir {
+			return os.MkdirAll(target, 0755)
+		}
+		ev := ImportEvent{Type: "progress", Path: rel, Action: "created", Done: n, Total: total}
+		if existing, statErr := os.Lstat(target); statErr == nil {
+			switch {
+			case conflict == "skip":
+				ev.Action = "skipped"
+			case conflict == "rename" || existing.IsDir():
+				target = uniqueImportPath(target)
+				ev.Action = "renamed"
+				ev.NewPath = filepath.ToSlash(strings.TrimPrefix(strings.TrimPrefix(target, dest), string(filepath.Separator)))
+			default:
+				// Igual que en rename con overwrite, el archivo reemplazado va a la papelera
+				if _, err := moveToTrash(root, target); err != nil {
+					return err
+				}
+				ev.Action = "overwritten"
+			}
+		}
+		if ev.Action != "skipped" {
+			if err := writeImportedFile(target, entry, &remaining); err != nil {
+				return fmt.Errorf("%s: %v", rel, err)
+			}
+		}
+		switch ev.Action {
+		case "created":
+			done.Created++
+		case "overwritten":
+			done.Overwritten++
+		case "renamed":
+			done.Renamed++
+		case "skipped":
+			done.Skipped++
+		}
+		// Limitar los eventos de progreso para archivos con miles de entradas
+		if n == total || time.Since(lastFlush) > 100*time.Millisecond || total <= 1000 {
+			send(ev)
+			lastFlush = time.Now()
+		}
+		return nil
+	})
+	invalidateFileIndex(dest)
+	if err != nil {
+		send(ImportEvent{Type: "error", Message: err.Error()})
+		done.Message = "Import stopped: " + err.Error()
+		fmt.Println("[BACK]", done.Message)
+		send(done)
+		return
+	}
+	done.Success = true
+	done.Message = fmt.Sprintf("Imported %d entries", total)
+	done.Done, done.Total = n, total
+	fmt.Printf("[BACK] %s into '%s'\n", done.Message, dest)
+	send(done)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func TestArchiveEntryTarget(t *testing.T) {
+	root := t.TempDir()
+	dest := filepath.Join(root, "dest")
+	outside := t.TempDir()
+	writeTestFile(t, filepath.Join(dest, "real", "a.txt"), "a")
+	symlinks := runtime.GOOS != "windows"
+	if symlinks {
+		if err := os.Symlink(outside, filepath.Join(dest, "escape")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(dest, "real"), filepath.Join(dest, "inside")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		entry   string
+		want    string // relativo a dest; "" si se rechaza
+		symlink bool
+	}{
+		{"plain file", "a.txt", "a.txt", false},
+		{"nested file", "src/pkg/main.go", "src/pkg/main.go", false},
+		{"dot slash", "./src/x.go", "src/x.go", false},
+		{"root dir entry", "./", ".", false},
+		{"inner dot dot that stays inside", "src/../x", "", false},
+		{"parent", "../evil.sh", "", false},
+		{"deep parent", "a/b/../../../evil.sh", "", false},
+		{"absolute", "/etc/passwd", "", false},
+		{"backslash parent", `..\evil.sh`, "", false},
+		{"backslash absolute", `\windows\evil.dll`, "", false},
+		{"through symlink outside", "escape/evil.sh", "", true},
+		{"through nested symlink outside", "escape/new/dir/evil.sh", "", true},
+		{"through symlink inside", "inside/b.txt", "inside/b.txt", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.symlink && !symlinks {
+				t.Skip("symlinks need privileges on Windows")
+			}
+			got, err := archiveEntryTarget(dest, tt.entry)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("archiveEntryTarget(%q) = %q, want an error", tt.entry, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("archiveEntryTarget(%q): %v", tt.entry, err)
+			}
+			if want := filepath.Join(dest, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("archiveEntryTarget(%q) = %q, want %q", tt.entry, got, want)
+			}
+		})
+	}
+}
+
+func TestExportSubfolderKeepsWorkspaceIgnoreRules(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, ".gitignore"), "*.log\nweb/dist/\n")
+	writeTestFile(t, filepath.Join(root, "web", ".airideignore"), "secret.txt\n")
+	writeTestFile(t, filepath.Join(root, "web", "app", "main.js"), "x")
+	writeTestFile(t, filepath.Join(root, "web", "app", "debug.log"), "x")
+	writeTestFile(t, filepath.Join(root, "web", "app", "secret.txt"), "x")
+	writeTestFile(t, filepath.Join(root, "web", "app", "dist", "bundle.js"), "x")
+	writeTestFile(t, filepath.Join(root, "web", "dist", "bundle.js"), "x")
+
+	q := url.Values{"path": {"web/app"}, "projectBaseDir": {root}}
+	w := httptest.NewRecorder()
+	exportHandler(w, httptest.NewRequest("GET", "/api/export?"+q.Encode(), nil))
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	// web/dist/ está anclado a web, así que web/app/dist no coincide
+	want := []string{"app/dist/bundle.js", "app/main.js"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("exported %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// pathLock serializa las escrituras de un archivo; refs cuenta quién lo usa para liberar la entrada al terminar
+type pathLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var (
+	pathLocksMu sync.Mutex
+	pathLocks   = map[string]*pathLock{}
+)
+
+// lockPaths toma el lock de escritura de cada ruta (en orden, para no bloquearse con otro lote) y devuelve
+// la función que los libera. Se mantiene desde la comprobación de versión hasta que termina la escritura.
+func lockPaths(paths ...string) (unlock func()) {
+	keys := make([]string, 0, len(paths))
+	seen := map[string]bool{}
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		if !seen[p] {
+			seen[p] = true
+			keys = append(keys, p)
+		}
+	}
+	sort.Strings(keys)
+	locks := make([]*pathLock, len(keys))
+	pathLocksMu.Lock()
+	for i, k := range keys {
+		l := pathLocks[k]
+		if l == nil {
+			l = &pathLock{}
+			pathLocks[k] = l
+		}
+		l.refs++
+		locks[i] = l
+	}
+	pathLocksMu.Unlock()
+	for _, l := range locks {
+		l.mu.Lock()
+	}
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].mu.Unlock()
+		}
+		pathLocksMu.Lock()
+		for i, k := range keys {
+			if locks[i].refs--; locks[i].refs == 0 {
+				delete(pathLocks, k)
+			}
+		}
+		pathLocksMu.Unlock()
+	}
+}
+
+// atomicWriteFile escribe en un archivo temporal del mismo directorio, hace fsync y lo renombra sobre el destino,
+// de modo que un corte a mitad de escritura nunca deja el archivo truncado. Conserva permisos y dueño del original.
+func atomicWriteFile(path string, data []byte, defaultMode os.FileMode) error {
+	// Si el destino es un symlink se escribe en el archivo real para no reemplazar el enlace
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	mode := defaultMode
+	existing, statErr := os.Stat(path)
+	if statErr == nil {
+		mode = existing.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".airide-tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmpName)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if statErr == nil {
+		preserveOwner(tmpName, existing)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// textFormat describe el formato de un archivo de texto en disco
+type textFormat struct {
+	CRLF bool
+	// MixedEOL indica que el archivo tiene líneas con LF y con CRLF
+	MixedEOL     bool
+	BOM          bool
+	FinalNewline bool
+}
+
+// detectTextFormat inspecciona fines de línea, BOM y salto final del contenido
+func detectTextFormat(content []byte) textFormat {
+	var f textFormat
+	f.BOM = bytes.HasPrefix(content, utf8BOM)
+	crlf := bytes.Count(content, []byte("\r\n"))
+	lf := bytes.Count(content, []byte("\n")) - crlf
+	f.CRLF = crlf > lf
+	f.MixedEOL = crlf > 0 && lf > 0
+	f.FinalNewline = bytes.HasSuffix(content, []byte("\n"))
+	return f
+}
+
+// formatTextForSave aplica al contenido del editor el formato del archivo original o el forzado por la configuración.
+// original es nil cuando el archivo no existe todavía.
+func formatTextForSave(content string, original []byte, settings FileSettings) []byte {
+	var orig textFormat
+	if original != nil {
+		orig = detectTextFormat(original)
+	} else {
+		orig = detectTextFormat([]byte(content))
+	}
+
+	content = strings.TrimPrefix(content, string(utf8BOM))
+	useCRLF := orig.CRLF
+	// Un archivo con fines de línea mezclados se guarda tal como lo manda el editor, salvo que eol los fuerce:
+	// pasarlo entero al estilo mayoritario cambiaría líneas que el usuario no tocó
+	keepEOL := orig.MixedEOL
+	switch settings.EOL {
+	case "lf":
+		useCRLF, keepEOL = false, false
+	case "crlf":
+		useCRLF, keepEOL = true, false
+	}
+	if !keepEOL {
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	newline := "\n"
+	if keepEOL && useCRLF {
+		newline = "\r\n"
+	}
+
+	hasOriginal := original != nil
+	switch settings.FinalNewline {
+	case "insert":
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += newline
+		}
+	case "trim":
+		content = strings.TrimRight(content, "\r\n")
+	default:
+		// En modo auto se conserva el salto final que tenía el archivo en disco
+		if hasOriginal {
+			if orig.FinalNewline && content != "" && !strings.HasSuffix(content, "\n") {
+				content += newline
+			} else if !orig.FinalNewline {
+				content = strings.TrimSuffix(strings.TrimSuffix(content, "\n"), "\r")
+			}
+		}
+	}
+
+	if useCRLF && !keepEOL {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+
+	useBOM := orig.BOM
+	switch settings.BOM {
+	case "add":
+		useBOM = true
+	case "remove":
+		useBOM = false
+	}
+	if useBOM {
+		return append(append([]byte{}, utf8BOM...), content...)
+	}
+	return []byte(content)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriteFileResolvesAgainstProjectBaseDir(t *testing.T) {
+	root := t.TempDir()
+
+	if resp := writeFile(root, "src/a.txt", "hello\n", "", ""); !resp.Success {
+		t.Fatalf("writeFile = %+v", resp)
+	}
+	resp := readFile(root, "src/a.txt", "")
+	if !resp.Success || resp.Content != "hello\n" {
+		t.Fatalf("readFile = %+v", resp)
+	}
+	if got := readTestFile(t, filepath.Join(root, "src", "a.txt")); got != "hello\n" {
+		t.Errorf("file in workspace = %q", got)
+	}
+}
+
+func TestWriteFileVersionCheckIsAtomic(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.txt")
+	writeTestFile(t, path, "v0")
+	version := contentHash([]byte("v0"))
+
+	// Todos los guardados parten de la misma versión: solo el primero puede escribir
+	const writers = 20
+	var wg sync.WaitGroup
+	results := make([]FileResponse, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = writeFile(root, path, string(rune('a'+i)), version, "")
+		}(i)
+	}
+	wg.Wait()
+	saved := 0
+	for _, resp := range results {
+		if resp.Success {
+			saved++
+		} else if !resp.Conflict {
+			t.Errorf("unexpected failure: %+v", resp)
+		}
+	}
+	if saved != 1 {
+		t.Errorf("%d writes succeeded from the same version, want 1", saved)
+	}
+	if len(pathLocks) != 0 {
+		t.Errorf("pathLocks leaked %d entries", len(pathLocks))
+	}
+}
+
+func TestFormatTextForSave(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		original string
+		new      bool // el archivo todavía no existe
+		settings FileSettings
+		want     string
+	}{
+		{"auto restores final newline", "a\nb", "a\nb\n", false, FileSettings{}, "a\nb\n"},
+		{"auto keeps missing final newline", "a\nb\n", "a\nb", false, FileSettings{}, "a\nb"},
+		{"auto keeps crlf", "a\nb\n", "x\r\ny\r\n", false, FileSettings{}, "a\r\nb\r\n"},
+		{"auto keeps utf-8 bom", "a\n", "\xef\xbb\xbfx\n", false, FileSettings{}, "\xef\xbb\xbfa\n"},
+		{"new file keeps editor format", "a\r\nb", "", true, FileSettings{}, "a\r\nb"},
+		{"insert final newline", "a", "a", false, FileSettings{FinalNewline: "insert"}, "a\n"},
+		{"trim final newlines", "a\n\n", "a", false, FileSettings{FinalNewline: "trim"}, "a"},
+		{"force lf", "a\r\nb\r\n", "x\r\n", false, FileSettings{EOL: "lf"}, "a\nb\n"},
+		{"force crlf", "a\nb", "x\ny", false, FileSettings{EOL: "crlf"}, "a\r\nb"},
+		{"mixed eol left untouched", "a\r\nb\nc\r\nd\n", "a\r\nb\nc\r\n", false, FileSettings{}, "a\r\nb\nc\r\nd\n"},
+		{"mixed eol keeps final newline", "a\r\nb\nc", "a\r\nb\nc\r\n", false, FileSettings{}, "a\r\nb\nc\r\n"},
+		{"mixed eol forced to lf", "a\r\nb\n", "a\r\nb\n", false, FileSettings{EOL: "lf"}, "a\nb\n"},
+		{"add bom", "a", "a", false, FileSettings{BOM: "add"}, "\xef\xbb\xbfa"},
+		{"remove bom", "\xef\xbb\xbfa", "\xef\xbb\xbfa", false, FileSettings{BOM: "remove"}, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var original []byte
+			if !tt.new {
+				original = []byte(tt.original)
+			}
+			if got := string(formatTextForSave(tt.content, original, tt.settings)); got != tt.want {
+				t.Errorf("formatTextForSave(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// preserveOwner copia dueño y grupo del archivo original al temporal (solo posible si el proceso tiene permisos)
+func preserveOwner(path string, original os.FileInfo) {
+	if st, ok := original.Sys().(*syscall.Stat_t); ok {
+		os.Lchown(path, int(st.Uid), int(st.Gid))
+	}
+}
+
+// syncDir persiste la entrada de directorio creada por el rename
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// preserveOwner no aplica en Windows: el archivo nuevo hereda las ACL del directorio
+func preserveOwner(path string, original os.FileInfo) {}
+
+// syncDir no aplica en Windows: los directorios no se pueden abrir para fsync
+func syncDir(dir string) {}
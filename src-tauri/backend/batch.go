@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Máximo de operaciones aceptadas en un solo lote
+const maxBatchOperations = 1000
+
+type BatchOperation struct {
+	Operation       string `json:"operation"` // "create", "write", "rename" o "delete"
+	Path            string `json:"path"`
+	Content         string `json:"content,omitempty"`
+	NewPath         string `json:"newPath,omitempty"`
+	ExpectedVersion string `json:"expectedVersion,omitempty"`
+	Overwrite       bool   `json:"overwrite,omitempty"`
+	Encoding        string `json:"encoding,omitempty"`     // charset de create/write; vacío conserva el del archivo
+	Recursive       bool   `json:"recursive,omitempty"`    // delete de un directorio con contenido
+	ConfirmToken    string `json:"confirmToken,omitempty"` // token devuelto por el primer intento de delete recursivo
+}
+
+type BatchRequest struct {
+	Operations     []BatchOperation `json:"operations"`
+	DryRun         bool             `json:"dryRun,omitempty"`
+	ProjectBaseDir string           `json:"projectBaseDir,omitempty"`
+}
+
+type BatchOperationResult struct {
+	Index     int    `json:"index"`
+	Operation string `json:"operation"`
+	Path      string `json:"path"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message,omitempty"`
+	Version   string `json:"version,omitempty"`
+	// ConfirmationToken se emite cuando un delete necesita confirmación para borrar un directorio con contenido
+	ConfirmationToken string `json:"confirmationToken,omitempty"`
+}
+
+type BatchResponse struct {
+	Success    bool                   `json:"success"`
+	Message    string                 `json:"message"`
+	Results    []BatchOperationResult `json:"results"`
+	RolledBack bool                   `json:"rolledBack,omitempty"`
+}
+
+// batchUndo es la acción compensatoria de una operación ya aplicada
+type batchUndo struct {
+	path      string
+	original  []byte // contenido previo de un write; nil si el archivo no existía
+	renamedTo string // destino de un rename ya hecho
+	trashID   string // elemento mandado a la papelera (delete o destino pisado por rename)
+	// createdDirs son las carpetas que la operación tuvo que crear, de la más profunda a la más alta
+	createdDirs []string
+}
+
+// mkdirTracked crea dir con los padres que falten y devuelve cuáles creó, de la más profunda a la más alta.
+// Si falla a mitad de camino también devuelve la lista, para poder limpiar lo que sí se creó.
+func mkdirTracked(dir string) ([]string, error) {
+	var missing []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	return missing, os.MkdirAll(dir, 0755)
+}
+
+// removeCreatedDirs borra las carpetas que creó una operación revertida; las que ya no están vacías se conservan
+func removeCreatedDirs(dirs []string) {
+	for _, dir := range dirs {
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("[BACK] Batch rollback left directory '%s': %v\n", dir, err)
+		}
+	}
+}
+
+// validateBatch comprueba todas las operaciones contra un estado simulado del disco antes de tocar nada
+func validateBatch(ops []BatchOperation, baseDir string) []BatchOperationResult {
+	results := make([]BatchOperationResult, len(ops))
+	// exists[path] refleja el efecto de las operaciones anteriores del lote
+	exists := map[string]bool{}
+	pathExists := func(p string) bool {
+		if e, ok := exists[p]; ok {
+			return e
+		}
+		_, err := os.Lstat(p)
+		return err == nil
+	}
+	for i, op := range ops {
+		res := BatchOperationResult{Index: i, Operation: op.Operation, Path: op.Path, Success: true}
+		path := resolveWorkspacePath(op.Path, baseDir)
+		fail := func(msg string) {
+			res.Success = false
+			res.Message = msg
+		}
+		switch {
+		case op.Path == "":
+			fail("path is required")
+		case op.Operation == "create":
+			if pathExists(path) {
+				fail("file already exists")
+			}
+			exists[path] = true
+		case op.Operation == "write":
+			if op.ExpectedVersion != "" {
+				if _, touched := exists[path]; touched {
+					fail("expectedVersion cannot be used on a path modified earlier in the batch")
+				} else if content, err := os.ReadFile(path); err != nil || contentHash(content) != op.ExpectedVersion {
+					fail("file changed on disk since it was loaded")
+				}
+			}
+			exists[path] = true
+		case op.Operation == "rename":
+			newPath := resolveWorkspacePath(op.NewPath, baseDir)
+			if op.NewPath == "" {
+				fail("newPath is required")
+			} else if !pathExists(path) {
+				fail("source does not exist")
+			} else if pathExists(newPath) && !op.Overwrite {
+				fail("destination already exists")
+			} else {
+				exists[path] = false
+				exists[newPath] = true
+			}
+		case op.Operation == "delete":
+			if !pathExists(path) {
+				fail("file does not exist")
+			} else if n := nonEmptyDirEntries(path); n > 0 && (!op.Recursive || !validConfirmToken(op.ConfirmToken, path)) {
+				// Mismo requisito que deleteFile: recursive y el token emitido en el primer intento
+				fail(fmt.Sprintf("directory is not empty (%d entries); resend with recursive and confirmToken to delete it", n))
+				res.ConfirmationToken = newConfirmToken(path)
+			}
+			exists[path] = false
+		default:
+			fail("unknown operation: " + op.Operation)
+		}
+		results[i] = res
+	}
+	return results
+}
+
+// nonEmptyDirEntries devuelve cuántas entradas tiene path si es un directorio (no sigue symlinks)
+func nonEmptyDirEntries(path string) int {
+	info, err := os.Lstat(path)
+	if err != nil || !info.IsDir() {
+		return 0
+	}
+	entries, _ := os.ReadDir(path)
+	return len(entries)
+}
+
+// applyBatchOperation ejecuta una operación y devuelve cómo deshacerla
+func applyBatchOperation(op BatchOperation, baseDir string, settings WorkspaceSettings) (batchUndo, string, error) {
+	root := workspaceRoot(baseDir)
+	path := resolveWorkspacePath(op.Path, baseDir)
+	undo := batchUndo{path: path}
+	switch op.Operation {
+	case "create", "write":
+		original, err := os.ReadFile(path)
+		if err == nil {
+			undo.original = original
+		}
+		if undo.createdDirs, err = mkdirTracked(filepath.Dir(path)); err != nil {
+			return undo, "", err
+		}
+		data, _, _, err := encodeForSave(path, op.Content, op.Encoding, settings.Files)
+		if err != nil {
+			return undo, "", err
+		}
+		if err := atomicWriteFile(path, data, 0644); err != nil {
+			return undo, "", err
+		}
+		return undo, contentHash(data), nil
+	case "rename":
+		newPath := resolveWorkspacePath(op.NewPath, baseDir)
+		if _, err := os.Lstat(newPath); err == nil {
+			id, err := moveToTrash(root, newPath)
+			if err != nil {
+				return undo, "", err
+			}
+			undo.trashID = id
+		}
+		var err error
+		if undo.createdDirs, err = mkdirTracked(filepath.Dir(newPath)); err != nil {
+			return undo, "", err
+		}
+		if err := movePath(path, newPath); err != nil {
+			return undo, "", err
+		}
+		undo.renamedTo = newPath
+		return undo, "", nil
+	case "delete":
+		if nonEmptyDirEntries(path) > 0 && !consumeConfirmToken(op.ConfirmToken, path) {
+			return undo, "", fmt.Errorf("confirmToken for '%s' is no longer valid", op.Path)
+		}
+		id, err := moveToTrash(root, path)
+		if err != nil {
+			return undo, "", err
+		}
+		undo.trashID = id
+		return undo, "", nil
+	}
+	return undo, "", fmt.Errorf("unknown operation: %s", op.Operation)
+}
+
+// revertBatchOperation deshace una operación aplicada; los errores solo se registran
+func revertBatchOperation(op BatchOperation, undo batchUndo, root string) {
+	var err error
+	switch op.Operation {
+	case "create", "write":
+		if undo.original != nil {
+			err = atomicWriteFile(undo.path, undo.original, 0644)
+		} else if err = os.Remove(undo.path); os.IsNotExist(err) {
+			err = nil
+		}
+	case "rename":
+		if undo.renamedTo != "" {
+			err = movePath(undo.renamedTo, undo.path)
+		}
+	}
+	if err == nil && undo.trashID != "" {
+		_, err = restoreFromTrash(root, undo.trashID, false)
+	}
+	if err != nil {
+		fmt.Printf("[BACK] Batch rollback of %s '%s' failed: %v\n", op.Operation, undo.path, err)
+	}
+	removeCreatedDirs(undo.createdDirs)
+}
+
+// runBatch valida el lote completo, lo aplica y, si algo falla, revierte en orden inverso lo ya aplicado
+func runBatch(req BatchRequest) BatchResponse {
+	if len(req.Operations) == 0 {
+		return BatchResponse{Success: false, Message: "No operations provided"}
+	}
+	if len(req.Operations) > maxBatchOperations {
+		return BatchResponse{Success: false, Message: fmt.Sprintf("Too many operations (max %d)", maxBatchOperations)}
+	}
+	// Las rutas del lote quedan bloqueadas desde la validación (expectedVersion) hasta el final de la escritura
+	var paths []string
+	for _, op := range req.Operations {
+		paths = append(paths, resolveWorkspacePath(op.Path, req.ProjectBaseDir))
+		if op.NewPath != "" {
+			paths = append(paths, resolveWorkspacePath(op.NewPath, req.ProjectBaseDir))
+		}
+	}
+	defer lockPaths(paths...)()
+	results := validateBatch(req.Operations, req.ProjectBaseDir)
+	for _, res := range results {
+		if !res.Success {
+			return BatchResponse{Success: false, Message: "Validation failed; nothing was changed", Results: results}
+		}
+	}
+	if req.DryRun {
+		return BatchResponse{Success: true, Message: "Validation passed", Results: results}
+	}
+
+	root := workspaceRoot(req.ProjectBaseDir)
+	settings := loadWorkspaceSettings(root)
+	var undos []batchUndo
+	for i, op := range req.Operations {
+		undo, version, err := applyBatchOperation(op, req.ProjectBaseDir, settings)
+		if err != nil {
+			fmt.Printf("[BACK] Batch operation %d (%s '%s') failed: %v; rolling back\n", i, op.Operation, op.Path, err)
+			results[i].Success = false
+			results[i].Message = err.Error()
+			// Las escrituras son atómicas; lo único que puede quedar a medias es el destino que un rename mandó a la papelera
+			if undo.trashID != "" && undo.renamedTo == "" {
+				if _, err := restoreFromTrash(root, undo.trashID, false); err != nil {
+					fmt.Printf("[BACK] Batch rollback of %s '%s' failed: %v\n", op.Operation, undo.path, err)
+				}
+			}
+			removeCreatedDirs(undo.createdDirs)
+			for j := i - 1; j >= 0; j-- {
+				revertBatchOperation(req.Operations[j], undos[j], root)
+				results[j].Success = false
+				results[j].Version = ""
+				results[j].Message = "rolled back"
+			}
+			for j := i + 1; j < len(results); j++ {
+				results[j].Success = false
+				results[j].Message = "not applied"
+			}
+			return BatchResponse{Success: false, Message: fmt.Sprintf("Batch failed at operation %d; all changes were rolled back", i), Results: results, RolledBack: true}
+		}
+		results[i].Version = version
+		undos = append(undos, undo)
+	}
+
+	recordUndo(root, batchUndoEntry(root, req.Operations, undos))
+	for i, op := range req.Operations {
+		path := resolveWorkspacePath(op.Path, req.ProjectBaseDir)
+		switch op.Operation {
+		case "create", "write":
+			if undos[i].original != nil {
+				recordHistorySnapshot(root, path, undos[i].original, "external")
+				notifyLanguageServers(lspFileChanged, path)
+			} else {
+				notifyLanguageServers(lspFileCreated, path)
+			}
+			if content, err := os.ReadFile(path); err == nil {
+				recordHistorySnapshot(root, path, content, "save")
+			}
+		case "rename":
+			notifyLanguageServers(lspFileDeleted, path)
+			notifyLanguageServers(lspFileCreated, undos[i].renamedTo)
+		case "delete":
+			notifyLanguageServers(lspFileDeleted, path)
+		}
+	}
+	invalidateFileIndex(root)
+	return BatchResponse{Success: true, Message: fmt.Sprintf("%d operations applied", len(req.Operations)), Results: results}
+}
+
+// batchUndoEntry arma la entrada de /api/undo que revierte el lote completo
+func batchUndoEntry(root string, ops []BatchOperation, undos []batchUndo) undoEntry {
+	entry := undoEntry{Operation: "batch"}
+	for i, op := range ops {
+		u := undos[i]
+		switch op.Operation {
+		case "create", "write":
+			if u.original == nil {
+				entry.Entries = append(entry.Entries, undoEntry{Operation: "create", Path: u.path})
+				continue
+			}
+			// El contenido anterior se guarda como objeto del historial, aunque el historial esté desactivado
+			id, err := storeHistoryObject(root, u.original)
+			if err != nil {
+				fmt.Printf("[BACK] Cannot keep the previous content of '%s' for undo: %v\n", u.path, err)
+				continue
+			}
+			entry.Entries = append(entry.Entries, undoEntry{Operation: "write", Path: u.path, Revision: id})
+		case "rename":
+			entry.Entries = append(entry.Entries, undoEntry{Operation: "rename", Path: u.path, NewPath: u.renamedTo, ReplacedTrashID: u.trashID})
+		case "delete":
+			entry.Entries = append(entry.Entries, undoEntry{Operation: "delete", Path: u.path, TrashID: u.trashID})
+		}
+	}
+	return entry
+}
+
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	fmt.Println("[BACK] /api/batch endpoint hit")
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fmt.Println("[BACK] Error decoding batch request:", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	fmt.Printf("[BACK] BatchRequest: %d operations, dryRun=%v\n", len(req.Operations), req.DryRun)
+	if !isUIRequest(r) {
+		for _, op := range req.Operations {
+			if isWorkspaceConfigPath(resolveWorkspacePath(op.Path, req.ProjectBaseDir)) ||
+				(op.NewPath != "" && isWorkspaceConfigPath(resolveWorkspacePath(op.NewPath, req.ProjectBaseDir))) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(BatchResponse{Success: false, Message: workspaceConfigReadOnly})
+				return
+			}
+		}
+	}
+	resp := runBatch(req)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestBatchWriteKeepsCharsetAndLineEndings(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "sjis.txt")
+	original, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte("日本語\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := runBatch(BatchRequest{ProjectBaseDir: root, Operations: []BatchOperation{
+		{Operation: "write", Path: "sjis.txt", Content: "日本語\nです\n"},
+	}})
+	if !resp.Success {
+		t.Fatalf("runBatch failed: %+v", resp)
+	}
+	want, _ := japanese.ShiftJIS.NewEncoder().Bytes([]byte("日本語\r\nです\r\n"))
+	if got := readTestFile(t, path); got != string(want) {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+}
+
+func TestBatchDeleteNonEmptyDirNeedsConfirmation(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "dir")
+	writeTestFile(t, filepath.Join(dir, "a.txt"), "a")
+	deleteOp := BatchOperation{Operation: "delete", Path: "dir"}
+
+	resp := runBatch(BatchRequest{ProjectBaseDir: root, Operations: []BatchOperation{deleteOp}})
+	if resp.Success || resp.Results[0].ConfirmationToken == "" {
+		t.Fatalf("delete without confirmation = %+v", resp)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("directory removed without confirmation: %v", err)
+	}
+
+	deleteOp.Recursive = true
+	deleteOp.ConfirmToken = resp.Results[0].ConfirmationToken
+	// Un dry run valida el token sin gastarlo
+	if resp := runBatch(BatchRequest{ProjectBaseDir: root, DryRun: true, Operations: []BatchOperation{deleteOp}}); !resp.Success {
+		t.Fatalf("dry run = %+v", resp)
+	}
+	if resp := runBatch(BatchRequest{ProjectBaseDir: root, Operations: []BatchOperation{deleteOp}}); !resp.Success {
+		t.Fatalf("confirmed delete = %+v", resp)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("directory still exists after confirmed delete: %v", err)
+	}
+
+	// El token es de un solo uso
+	writeTestFile(t, filepath.Join(dir, "b.txt"), "b")
+	if resp := runBatch(BatchRequest{ProjectBaseDir: root, Operations: []BatchOperation{deleteOp}}); resp.Success {
+		t.Fatal("confirmation token was accepted twice")
+	}
+}
+
+func TestBatchRollbackRemovesCreatedDirectories(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "keep", "old.txt"), "old")
+	resp := runBatch(BatchRequest{ProjectBaseDir: root, Operations: []BatchOperation{
+		{Operation: "create", Path: "new/deep/a.txt", Content: "a"},
+		{Operation: "rename", Path: "keep/old.txt", NewPath: "moved/here/old.txt"},
+		// Shift_JIS no puede representar el emoji: la escritura falla después de la validación
+		{Operation: "create", Path: "keep/b.txt", Content: "😀", Encoding: "shift_jis"},
+	}})
+	if resp.Success || !resp.RolledBack {
+		t.Fatalf("runBatch = %+v, want a rolled back failure", resp)
+	}
+	for _, dir := range []string{"new", "moved"} {
+		if _, err := os.Stat(filepath.Join(root, dir)); !os.IsNotExist(err) {
+			t.Errorf("%s left behind after rollback", dir)
+		}
+	}
+	if readTestFile(t, filepath.Join(root, "keep", "old.txt")) != "old" {
+		t.Error("renamed file not restored")
+	}
+}
+
+func TestBatchCanBeUndone(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a.txt"), "before")
+	writeTestFile(t, filepath.Join(root, "b.txt"), "b")
+	writeTestFile(t, filepath.Join(root, "c.txt"), "c")
+	resp := runBatch(BatchRequest{ProjectBaseDir: root, Operations: []BatchOperation{
+		{Operation: "write", Path: "a.txt", Content: "after"},
+		{Operation: "create", Path: "src/new.txt", Content: "new"},
+		{Operation: "rename", Path: "b.txt", NewPath: "renamed.txt"},
+		{Operation: "delete", Path: "c.txt"},
+	}})
+	if !resp.Success {
+		t.Fatalf("runBatch = %+v", resp)
+	}
+	entry, err := undoLast(root)
+	if err != nil || entry.Operation != "batch" {
+		t.Fatalf("undoLast = %+v, %v", entry, err)
+	}
+	if got := readTestFile(t, filepath.Join(root, "a.txt")); got != "before" {
+		t.Errorf("a.txt = %q, want the content before the batch", got)
+	}
+	if readTestFile(t, filepath.Join(root, "b.txt")) != "b" || readTestFile(t, filepath.Join(root, "c.txt")) != "c" {
+		t.Error("rename or delete not undone")
+	}
+	for _, rel := range []string{"renamed.txt", "src/new.txt"} {
+		if _, err := os.Stat(filepath.Join(root, rel)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after undo", rel)
+		}
+	}
+}
@@ -0,0 +1,1303 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Configuraciones de depuración y breakpoints guardados, dentro de .airide
+	launchConfigFileName = "launch.json"
+	breakpointsFileName  = "breakpoints.json"
+	// Tiempo para que un adaptador TCP (dlv, js-debug) empiece a aceptar conexiones
+	debugAdapterStartTimeout = 15 * time.Second
+	debugStopTimeout         = 2 * time.Second
+	// Tras el evento terminated se espera el disconnect del editor antes de cerrar la sesión
+	debugTerminatedGrace = 5 * time.Second
+	// Mensajes guardados mientras no hay editor conectado
+	debugBacklogLimit = 1000
+	debugClientQueue  = 1024
+)
+
+const debugUserOnlyMessage = "Only the IDE can attach to debug sessions or change breakpoints"
+
+var debugVariablePattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// SourceBreakpoint es un breakpoint guardado, con los mismos campos que setBreakpoints de DAP
+type SourceBreakpoint struct {
+	Line         int    `json:"line"`
+	Column       int    `json:"column,omitempty"`
+	Condition    string `json:"condition,omitempty"`
+	HitCondition string `json:"hitCondition,omitempty"`
+	LogMessage   string `json:"logMessage,omitempty"`
+}
+
+// normalizeDebugType acepta los nombres de VS Code para cada adaptador
+func normalizeDebugType(t string) string {
+	switch strings.ToLower(t) {
+	case "go", "dlv", "delve":
+		return "go"
+	case "python", "debugpy", "py":
+		return "python"
+	case "node", "pwa-node", "javascript", "js", "js-debug":
+		return "node"
+	}
+	return ""
+}
+
+// loadLaunchConfigurations lee .airide/launch.json; sin archivo propone configuraciones según los manifiestos
+func loadLaunchConfigurations(root string) ([]map[string]interface{}, string, error) {
+	data, err := os.ReadFile(filepath.Join(root, workspaceConfigDir, launchConfigFileName))
+	if os.IsNotExist(err) {
+		return defaultLaunchConfigurations(root), "default", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var file struct {
+		Configurations []map[string]interface{} `json:"configurations"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, "", fmt.Errorf("invalid %s: %v", launchConfigFileName, err)
+	}
+	return file.Configurations, launchConfigFileName, nil
+}
+
+func defaultLaunchConfigurations(root string) []map[string]interface{} {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(root, name))
+		return err == nil
+	}
+	goConfig := map[string]interface{}{"name": "Launch Go package", "type": "go", "request": "launch", "mode": "debug", "program": "${workspaceFolder}"}
+	pythonConfig := map[string]interface{}{"name": "Python: current file", "type": "python", "request": "launch", "program": "${file}", "console": "internalConsole"}
+	nodeConfig := map[string]interface{}{"name": "Node: current file", "type": "node", "request": "launch", "program": "${file}"}
+	var configs []map[string]interface{}
+	if exists("go.mod") {
+		configs = append(configs, goConfig)
+	}
+	if exists("pyproject.toml") || exists("requirements.txt") || exists("setup.py") || exists(".venv") {
+		configs = append(configs, pythonConfig)
+	}
+	if exists("package.json") {
+		configs = append(configs, nodeConfig)
+	}
+	if len(configs) == 0 {
+		configs = []map[string]interface{}{pythonConfig, nodeConfig, goConfig}
+	}
+	return configs
+}
+
+// substituteDebugVariables reemplaza ${workspaceFolder}, ${file}, ${env:NAME}... en toda la configuración
+func substituteDebugVariables(v interface{}, vars map[string]string, env *commandEnv) interface{} {
+	switch val := v.(type) {
+	case string:
+		return debugVariablePattern.ReplaceAllStringFunc(val, func(m string) string {
+			name := m[2 : len(m)-1]
+			if strings.HasPrefix(name, "env:") {
+				return env.get(strings.TrimPrefix(name, "env:"))
+			}
+			if value, ok := vars[name]; ok {
+				return value
+			}
+			return m
+		})
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = substituteDebugVariables(item, vars, env)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = substituteDebugVariables(item, vars, env)
+		}
+		return out
+	}
+	return v
+}
+
+func resolveLaunchConfiguration(root, file string, config map[string]interface{}, env *commandEnv) map[string]interface{} {
+	vars := map[string]string{
+		"workspaceFolder":         root,
+		"workspaceRoot":           root,
+		"workspaceFolderBasename": filepath.Base(root),
+		"cwd":                     root,
+		"pathSeparator":           string(filepath.Separator),
+	}
+	if file != "" {
+		abs := resolveWorkspacePath(file, root)
+		rel, _ := filepath.Rel(root, abs)
+		base := filepath.Base(abs)
+		vars["file"] = abs
+		vars["relativeFile"] = rel
+		vars["fileBasename"] = base
+		vars["fileBasenameNoExtension"] = strings.TrimSuffix(base, filepath.Ext(base))
+		vars["fileDirname"] = filepath.Dir(abs)
+		vars["fileExtname"] = filepath.Ext(abs)
+	}
+	resolved := substituteDebugVariables(config, vars, env).(map[string]interface{})
+	if _, ok := resolved["cwd"]; !ok {
+		resolved["cwd"] = root
+	}
+	if _, ok := resolved["request"]; !ok {
+		resolved["request"] = "launch"
+	}
+	return resolved
+}
+
+// Breakpoints guardados en .airide/breakpoints.json, con rutas relativas al workspace
+
+var breakpointsMu sync.Mutex
+
+func loadBreakpoints(root string) map[string][]SourceBreakpoint {
+	breakpointsMu.Lock()
+	defer breakpointsMu.Unlock()
+	return readBreakpointsFile(root)
+}
+
+func readBreakpointsFile(root string) map[string][]SourceBreakpoint {
+	var file struct {
+		Breakpoints map[string][]SourceBreakpoint `json:"breakpoints"`
+	}
+	data, err := os.ReadFile(filepath.Join(root, workspaceConfigDir, breakpointsFileName))
+	if err == nil {
+		if err := json.Unmarshal(data, &file); err != nil {
+			fmt.Printf("[BACK] Invalid breakpoints file in %s: %v\n", root, err)
+		}
+	}
+	if file.Breakpoints == nil {
+		file.Breakpoints = map[string][]SourceBreakpoint{}
+	}
+	return file.Breakpoints
+}
+
+// saveBreakpoints reemplaza los breakpoints de un archivo (o de todos con path vacío y clear)
+func saveBreakpoints(root, path string, breakpoints []SourceBreakpoint, clearAll bool) error {
+	breakpointsMu.Lock()
+	defer breakpointsMu.Unlock()
+	all := readBreakpointsFile(root)
+	if clearAll {
+		all = map[string][]SourceBreakpoint{}
+	} else {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || !isSubPath(root, path) {
+			return errors.New("path is outside the workspace: " + path)
+		}
+		rel = filepath.ToSlash(rel)
+		if len(breakpoints) == 0 {
+			delete(all, rel)
+		} else {
+			all[rel] = breakpoints
+		}
+	}
+	dir := filepath.Join(root, workspaceConfigDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{"breakpoints": all}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(filepath.Join(dir, breakpointsFileName), data, 0644)
+}
+
+func setBreakpointsArguments(path string, breakpoints []SourceBreakpoint) map[string]interface{} {
+	lines := make([]int, len(breakpoints))
+	for i, bp := range breakpoints {
+		lines[i] = bp.Line
+	}
+	if breakpoints == nil {
+		breakpoints = []SourceBreakpoint{}
+	}
+	return map[string]interface{}{
+		"source":      map[string]string{"path": path, "name": filepath.Base(path)},
+		"breakpoints": breakpoints,
+		"lines":       lines,
+	}
+}
+
+// debugClient es el editor conectado por WebSocket a una sesión
+type debugClient struct {
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+}
+
+func (c *debugClient) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+func (c *debugClient) deliver(msg []byte) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	select {
+	case c.send <- msg:
+	default:
+		fmt.Println("[BACK] Debug client is not reading, disconnecting it")
+		c.close()
+	}
+}
+
+// debugPending es una petición reenviada al adaptador; la respuesta vuelve con el seq original del editor
+type debugPending struct {
+	clientSeq int
+	command   string
+	callback  func(lspRaw) // peticiones propias del backend (breakpoints guardados, disconnect)
+}
+
+// stdioConn une stdin y stdout de un adaptador que habla DAP por stdio (debugpy)
+type stdioConn struct {
+	io.ReadCloser
+	w io.WriteCloser
+}
+
+func (c stdioConn) Write(p []byte) (int, error) { return c.w.Write(p) }
+
+func (c stdioConn) Close() error {
+	c.w.Close()
+	return c.ReadCloser.Close()
+}
+
+// debugSession es un adaptador de depuración con un editor conectado. Las sesiones hijas (startDebugging
+// de js-debug) abren otra conexión al adaptador de la sesión padre en lugar de lanzar uno nuevo.
+type debugSession struct {
+	ID       string
+	Name     string
+	Type     string
+	Request  string
+	Root     string
+	ParentID string
+	config   map[string]interface{}
+	// source y policy son los de la petición autorizada: deciden qué variables secretas recibe el adaptador
+	source string
+	policy CommandPolicySettings
+
+	mu             sync.Mutex
+	writeMu        sync.Mutex
+	cmd            *exec.Cmd
+	exited         chan struct{}
+	command        string
+	addr           string // dirección TCP del adaptador
+	conn           io.ReadWriteCloser
+	status         string // "starting", "running" o "terminated"
+	started        time.Time
+	seq            int
+	pending        map[int]debugPending
+	client         *debugClient
+	backlog        [][]byte
+	debuggeePid    int
+	debuggeeExited bool
+	disconnectSent bool
+	stderr         []byte
+	closeOnce      sync.Once
+}
+
+var (
+	debugSessionsMu sync.Mutex
+	debugSessions   = map[string]*debugSession{}
+)
+
+func getDebugSession(id string) *debugSession {
+	debugSessionsMu.Lock()
+	defer debugSessionsMu.Unlock()
+	return debugSessions[id]
+}
+
+// freeLocalPort reserva un puerto libre para los adaptadores que escuchan por TCP
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// debugAdapterCommand devuelve el ejecutable y los argumentos del adaptador; port es 0 si habla por stdio
+func debugAdapterCommand(root, typ string, config map[string]interface{}, env *commandEnv) (string, []string, int, error) {
+	pathList := env.get("PATH")
+	switch typ {
+	case "go":
+		path, err := lookPathIn("dlv", pathList)
+		if err != nil {
+			return "", nil, 0, errors.New("Delve is not installed: go install github.com/go-delve/delve/cmd/dlv@latest")
+		}
+		port, err := freeLocalPort()
+		if err != nil {
+			return "", nil, 0, err
+		}
+		return path, []string{"dap", "--listen=127.0.0.1:" + strconv.Itoa(port)}, port, nil
+	case "python":
+		python, err := debugPythonInterpreter(root, config, env)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		return python, []string{"-m", "debugpy.adapter"}, 0, nil
+	case "node":
+		path, err := lookPathIn("js-debug-adapter", pathList)
+		if err != nil {
+			return "", nil, 0, errors.New("js-debug is not installed: js-debug-adapter was not found in PATH")
+		}
+		port, err := freeLocalPort()
+		if err != nil {
+			return "", nil, 0, err
+		}
+		return path, []string{strconv.Itoa(port), "127.0.0.1"}, port, nil
+	}
+	return "", nil, 0, errors.New("unsupported debug type")
+}
+
+// debugPythonInterpreter usa el "python" de la configuración o el del venv, y comprueba que tenga debugpy
+func debugPythonInterpreter(root string, config map[string]interface{}, env *commandEnv) (string, error) {
+	python, _ := config["python"].(string)
+	if python == "" {
+		for _, name := range []string{"python3", "python"} {
+			if path, err := lookPathIn(name, env.get("PATH")); err == nil {
+				python = path
+				break
+			}
+		}
+	}
+	if python == "" {
+		return "", errors.New("Python is not installed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), toolVersionTimeout)
+	defer cancel()
+	check := exec.CommandContext(ctx, python, "-c", "import debugpy")
+	check.Dir = root
+	check.Env = env.list()
+	if err := check.Run(); err != nil {
+		return "", fmt.Errorf("debugpy is not installed for %s: pip install debugpy", python)
+	}
+	return python, nil
+}
+
+// Campos de launch/attach que deciden qué se ejecuta; solo valen los de la configuración autorizada
+// al arrancar la sesión, no los que el editor manda en su launch/attach
+var debugExecutionKeys = map[string]bool{
+	"type": true, "request": true, "program": true, "args": true, "module": true, "python": true, "pythonPath": true,
+	"runtimeExecutable": true, "runtimeArgs": true, "cwd": true, "env": true, "envFile": true, "mode": true,
+	"buildFlags": true, "dlvFlags": true, "processId": true, "console": true, "sudo": true,
+	"debugServer": true, "preLaunchTask": true, "postDebugTask": true,
+}
+
+// debugLaunchCommand describe como un comando de shell lo que ejecuta la configuración,
+// para evaluarlo con la política del workspace y registrarlo en la auditoría
+func debugLaunchCommand(typ string, config map[string]interface{}) string {
+	str := func(key string) string {
+		v, _ := config[key].(string)
+		return v
+	}
+	list := func(key string) []string {
+		switch v := config[key].(type) {
+		case string:
+			return strings.Fields(v)
+		case []interface{}:
+			var res []string
+			for _, item := range v {
+				res = append(res, fmt.Sprint(item))
+			}
+			return res
+		}
+		return nil
+	}
+	var words []string
+	if str("request") == "attach" {
+		words = []string{"attach"}
+		for _, key := range []string{"processId", "host", "port"} {
+			if v, ok := config[key]; ok {
+				words = append(words, fmt.Sprint(v))
+			}
+		}
+	} else {
+		switch typ {
+		case "go":
+			mode := str("mode")
+			if mode == "" {
+				mode = "debug"
+			}
+			words = append([]string{"dlv", mode}, list("buildFlags")...)
+			if program := str("program"); program != "" {
+				words = append(words, program)
+			}
+			if args := list("args"); len(args) > 0 {
+				words = append(append(words, "--"), args...)
+			}
+		case "python":
+			python := str("python")
+			if python == "" {
+				python = "python"
+			}
+			words = []string{python}
+			if module := str("module"); module != "" {
+				words = append(words, "-m", module)
+			} else if program := str("program"); program != "" {
+				words = append(words, program)
+			}
+			words = append(words, list("args")...)
+		case "node":
+			runtime := str("runtimeExecutable")
+			if runtime == "" {
+				runtime = "node"
+			}
+			words = append([]string{runtime}, list("runtimeArgs")...)
+			if program := str("program"); program != "" {
+				words = append(words, program)
+			}
+			words = append(words, list("args")...)
+		}
+	}
+	for i, w := range words {
+		words[i] = shellQuote(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// debugConfigEnv devuelve el "env" de la configuración, que recibe el programa depurado
+func debugConfigEnv(config map[string]interface{}) map[string]string {
+	env := map[string]string{}
+	if vars, ok := config["env"].(map[string]interface{}); ok {
+		for k, v := range vars {
+			env[k] = fmt.Sprint(v)
+		}
+	}
+	return env
+}
+
+// startDebugSession lanza el adaptador de la configuración y se conecta a él
+func startDebugSession(root string, config map[string]interface{}, parent *debugSession, source string, policy CommandPolicySettings) (*debugSession, error) {
+	typ := normalizeDebugType(fmt.Sprint(config["type"]))
+	if parent != nil {
+		typ = parent.Type
+	}
+	if typ == "" {
+		return nil, fmt.Errorf("unsupported debug type: %v", config["type"])
+	}
+	if typ == "node" {
+		// js-debug solo reconoce sus propios tipos
+		config["type"] = "pwa-node"
+	}
+	name, _ := config["name"].(string)
+	request, _ := config["request"].(string)
+	s := &debugSession{
+		ID:      randomID(8),
+		Name:    name,
+		Type:    typ,
+		Request: request,
+		Root:    root,
+		config:  config,
+		source:  source,
+		policy:  policy,
+		status:  "starting",
+		started: time.Now(),
+		pending: map[int]debugPending{},
+	}
+	if parent != nil {
+		s.ParentID = parent.ID
+		parent.mu.Lock()
+		s.addr = parent.addr
+		s.command = parent.command
+		parent.mu.Unlock()
+		if s.addr == "" {
+			return nil, errors.New("the parent session adapter does not accept more connections")
+		}
+		conn, err := net.DialTimeout("tcp", s.addr, debugStopTimeout)
+		if err != nil {
+			return nil, err
+		}
+		s.conn = conn
+	} else if err := s.spawnAdapter(); err != nil {
+		return nil, err
+	}
+	s.status = "running"
+	debugSessionsMu.Lock()
+	debugSessions[s.ID] = s
+	debugSessionsMu.Unlock()
+	go s.readLoop()
+
+	fmt.Printf("[BACK] Started debug session %s (%s, %s) for %s\n", s.ID, typ, name, root)
+	return s, nil
+}
+
+func (s *debugSession) spawnAdapter() error {
+	env := buildCommandEnv(s.Root, nil)
+	if s.source != "user" {
+		// El adaptador tampoco se busca en node_modules/.bin ni en el venv del workspace
+		env.withoutWorkspaceTools(s.Root)
+	}
+	path, args, port, err := debugAdapterCommand(s.Root, s.Type, s.config, env)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Dir = s.Root
+	// Igual que en /terminal, el adaptador (y el programa que lanza) no hereda los secretos si lo pidió la IA
+	cmd.Env = scrubEnv(env.list(), s.policy, s.source)
+	setProcessGroup(cmd)
+	var stdin io.WriteCloser
+	var stdout io.ReadCloser
+	if port == 0 {
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return err
+		}
+		if stdout, err = cmd.StdoutPipe(); err != nil {
+			return err
+		}
+		cmd.Stderr = debugOutputWriter{s: s}
+	} else {
+		// La salida del programa depurado pasa por el adaptador: se reenvía como eventos output
+		cmd.Stdout = debugOutputWriter{s: s, category: "stdout"}
+		cmd.Stderr = debugOutputWriter{s: s, category: "stderr"}
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	s.cmd = cmd
+	s.command = path
+	s.exited = make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(s.exited)
+	}()
+	if port == 0 {
+		s.conn = stdioConn{ReadCloser: stdout, w: stdin}
+		return nil
+	}
+	s.addr = "127.0.0.1:" + strconv.Itoa(port)
+	deadline := time.Now().Add(debugAdapterStartTimeout)
+	for {
+		conn, err := net.DialTimeout("tcp", s.addr, time.Second)
+		if err == nil {
+			s.conn = conn
+			return nil
+		}
+		select {
+		case <-s.exited:
+			return fmt.Errorf("%s exited before accepting connections: %s", filepath.Base(path), strings.TrimSpace(string(s.stderrTail())))
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			signalProcessGroup(cmd.Process.Pid, syscall.SIGKILL)
+			return fmt.Errorf("%s did not accept connections on %s", filepath.Base(path), s.addr)
+		}
+	}
+}
+
+// debugOutputWriter guarda el final del stderr del adaptador y, con category, lo reenvía como eventos output
+type debugOutputWriter struct {
+	s        *debugSession
+	category string
+}
+
+func (w debugOutputWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	w.s.stderr = append(w.s.stderr, p...)
+	if len(w.s.stderr) > lspStderrLimit {
+		w.s.stderr = append([]byte{}, w.s.stderr[len(w.s.stderr)-lspStderrLimit/2:]...)
+	}
+	w.s.mu.Unlock()
+	if w.category != "" {
+		w.s.event("output", map[string]interface{}{"category": w.category, "output": string(p)})
+	}
+	return len(p), nil
+}
+
+func (s *debugSession) stderrTail() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return trimToRuneBoundary(append([]byte{}, s.stderr...))
+}
+
+func (s *debugSession) nextSeq() int {
+	s.seq++
+	return s.seq
+}
+
+func (s *debugSession) writeAdapter(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := fmt.Fprintf(s.conn, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = s.conn.Write(body)
+	return err
+}
+
+// request envía una petición propia del backend al adaptador
+func (s *debugSession) request(command string, arguments interface{}, callback func(lspRaw)) error {
+	s.mu.Lock()
+	seq := s.nextSeq()
+	s.pending[seq] = debugPending{command: command, callback: callback}
+	s.mu.Unlock()
+	return s.writeAdapter(map[string]interface{}{"seq": seq, "type": "request", "command": command, "arguments": arguments})
+}
+
+// event manda al editor un evento generado por el backend
+func (s *debugSession) event(event string, body interface{}) {
+	s.mu.Lock()
+	seq := s.nextSeq()
+	s.mu.Unlock()
+	msg, _ := json.Marshal(map[string]interface{}{"seq": seq, "type": "event", "event": event, "body": body})
+	s.deliver(msg)
+}
+
+// deliver envía al editor o guarda el mensaje hasta que se conecte
+func (s *debugSession) deliver(msg []byte) {
+	s.mu.Lock()
+	c := s.client
+	if c == nil {
+		if len(s.backlog) < debugBacklogLimit {
+			s.backlog = append(s.backlog, msg)
+		}
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	c.deliver(msg)
+}
+
+func (s *debugSession) attach(c *debugClient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == "terminated" {
+		return errors.New("debug session has ended")
+	}
+	if s.client != nil {
+		return errors.New("debug session already has an editor attached")
+	}
+	s.client = c
+	for _, msg := range s.backlog {
+		c.deliver(msg)
+	}
+	s.backlog = nil
+	return nil
+}
+
+func (s *debugSession) readLoop() {
+	r := bufio.NewReaderSize(s.conn, 64*1024)
+	for {
+		body, err := readLSPMessage(r)
+		if err != nil {
+			if tail := strings.TrimSpace(string(s.stderrTail())); tail != "" && s.cmd != nil && s.Type == "python" {
+				s.event("output", map[string]interface{}{"category": "stderr", "output": tail + "\n"})
+			}
+			s.close("adapter closed the connection")
+			return
+		}
+		s.fromAdapter(body)
+	}
+}
+
+// fromAdapter devuelve las respuestas al editor con su seq original y vigila los eventos que afectan a la sesión
+func (s *debugSession) fromAdapter(body []byte) {
+	var msg lspRaw
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return
+	}
+	var typ, event string
+	json.Unmarshal(msg["type"], &typ)
+	json.Unmarshal(msg["event"], &event)
+	switch typ {
+	case "response":
+		var requestSeq int
+		json.Unmarshal(msg["request_seq"], &requestSeq)
+		s.mu.Lock()
+		p, ok := s.pending[requestSeq]
+		delete(s.pending, requestSeq)
+		s.mu.Unlock()
+		if !ok {
+			return
+		}
+		if p.callback != nil {
+			p.callback(msg)
+			return
+		}
+		msg["request_seq"] = json.RawMessage(strconv.Itoa(p.clientSeq))
+		body, _ = json.Marshal(msg)
+	case "event":
+		switch event {
+		case "initialized":
+			// Los breakpoints guardados van antes de que el editor envíe los suyos y configurationDone
+			s.sendStoredBreakpoints()
+		case "process":
+			var process struct {
+				SystemProcessID int `json:"systemProcessId"`
+			}
+			json.Unmarshal(msg["body"], &process)
+			s.mu.Lock()
+			s.debuggeePid = process.SystemProcessID
+			s.mu.Unlock()
+		case "exited":
+			s.mu.Lock()
+			s.debuggeeExited = true
+			s.mu.Unlock()
+		case "terminated":
+			time.AfterFunc(debugTerminatedGrace, func() { s.close("debuggee terminated") })
+		}
+	}
+	s.deliver(body)
+}
+
+// fromClient renumera los seq del editor (el backend también envía peticiones al adaptador), completa
+// launch/attach con la configuración resuelta y guarda los breakpoints que pone el editor
+func (s *debugSession) fromClient(body []byte) {
+	var msg lspRaw
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return
+	}
+	var typ, command string
+	var clientSeq int
+	json.Unmarshal(msg["type"], &typ)
+	json.Unmarshal(msg["command"], &command)
+	json.Unmarshal(msg["seq"], &clientSeq)
+	if typ == "request" {
+		switch command {
+		case "launch", "attach":
+			args := map[string]interface{}{}
+			for k, v := range s.config {
+				args[k] = v
+			}
+			var override map[string]interface{}
+			json.Unmarshal(msg["arguments"], &override)
+			for k, v := range override {
+				// Lo que decide qué se ejecuta ya se autorizó al arrancar la sesión
+				if debugExecutionKeys[k] {
+					fmt.Printf("[BACK] Debug session %s: ignoring %q from the editor's %s\n", s.ID, k, command)
+					continue
+				}
+				args[k] = v
+			}
+			msg["arguments"], _ = json.Marshal(args)
+		case "setBreakpoints":
+			var args struct {
+				Source struct {
+					Path string `json:"path"`
+				} `json:"source"`
+				Breakpoints []SourceBreakpoint `json:"breakpoints"`
+			}
+			json.Unmarshal(msg["arguments"], &args)
+			if args.Source.Path != "" && isSubPath(s.Root, args.Source.Path) {
+				if err := saveBreakpoints(s.Root, args.Source.Path, args.Breakpoints, false); err != nil {
+					fmt.Println("[BACK] Error saving breakpoints:", err)
+				}
+			}
+		case "disconnect":
+			s.mu.Lock()
+			s.disconnectSent = true
+			s.mu.Unlock()
+		}
+	}
+	s.mu.Lock()
+	seq := s.nextSeq()
+	if typ == "request" {
+		s.pending[seq] = debugPending{clientSeq: clientSeq, command: command}
+	}
+	s.mu.Unlock()
+	msg["seq"] = json.RawMessage(strconv.Itoa(seq))
+	if err := s.writeAdapter(msg); err != nil && typ == "request" {
+		s.mu.Lock()
+		delete(s.pending, seq)
+		s.mu.Unlock()
+		resp, _ := json.Marshal(map[string]interface{}{
+			"seq": 0, "type": "response", "request_seq": clientSeq, "command": command,
+			"success": false, "message": err.Error(),
+		})
+		s.deliver(resp)
+	}
+}
+
+func (s *debugSession) sendStoredBreakpoints() {
+	for rel, breakpoints := range loadBreakpoints(s.Root) {
+		path := filepath.Join(s.Root, filepath.FromSlash(rel))
+		s.request("setBreakpoints", setBreakpointsArguments(path, breakpoints), func(lspRaw) {})
+	}
+}
+
+// pushBreakpoints aplica en una sesión en curso los breakpoints cambiados desde /api/debug/breakpoints
+// y se los comunica al editor con eventos breakpoint
+func (s *debugSession) pushBreakpoints(path string, breakpoints []SourceBreakpoint) {
+	s.request("setBreakpoints", setBreakpointsArguments(path, breakpoints), func(resp lspRaw) {
+		var body struct {
+			Breakpoints []map[string]interface{} `json:"breakpoints"`
+		}
+		json.Unmarshal(resp["body"], &body)
+		for _, bp := range body.Breakpoints {
+			if _, ok := bp["source"]; !ok {
+				bp["source"] = map[string]string{"path": path, "name": filepath.Base(path)}
+			}
+			s.event("breakpoint", map[string]interface{}{"reason": "new", "breakpoint": bp})
+		}
+	})
+}
+
+// close termina la sesión: pide disconnect con terminateDebuggee, cierra la conexión, espera al adaptador
+// y mata lo que quede (el adaptador, su grupo de procesos y el programa depurado)
+func (s *debugSession) close(reason string) {
+	s.closeOnce.Do(func() {
+		fmt.Printf("[BACK] Closing debug session %s: %s\n", s.ID, reason)
+		debugSessionsMu.Lock()
+		delete(debugSessions, s.ID)
+		var children []*debugSession
+		for _, child := range debugSessions {
+			if child.ParentID == s.ID {
+				children = append(children, child)
+			}
+		}
+		debugSessionsMu.Unlock()
+		for _, child := range children {
+			child.close("parent session ended")
+		}
+
+		s.mu.Lock()
+		sendDisconnect := !s.disconnectSent && s.status == "running"
+		s.status = "terminated"
+		s.mu.Unlock()
+		if sendDisconnect {
+			done := make(chan struct{})
+			if s.request("disconnect", map[string]interface{}{"terminateDebuggee": true}, func(lspRaw) { close(done) }) == nil {
+				select {
+				case <-done:
+				case <-time.After(debugStopTimeout):
+				}
+			}
+		}
+		s.conn.Close()
+
+		if s.cmd != nil {
+			select {
+			case <-s.exited:
+			case <-time.After(debugStopTimeout):
+				signalProcessGroup(s.cmd.Process.Pid, syscall.SIGKILL)
+			}
+		}
+		s.mu.Lock()
+		pid, exited := s.debuggeePid, s.debuggeeExited
+		client := s.client
+		s.mu.Unlock()
+		if pid > 0 && !exited && s.ParentID == "" {
+			if p, err := os.FindProcess(pid); err == nil && p.Signal(syscall.Signal(0)) == nil {
+				if signalProcessGroup(pid, syscall.SIGKILL) != nil {
+					p.Kill()
+				}
+			}
+		}
+		if client != nil {
+			client.close()
+		}
+	})
+}
+
+// stopDebugSessions cierra todas las sesiones (al cerrar el backend)
+func stopDebugSessions() {
+	debugSessionsMu.Lock()
+	var sessions []*debugSession
+	for _, s := range debugSessions {
+		if s.ParentID == "" {
+			sessions = append(sessions, s)
+		}
+	}
+	debugSessionsMu.Unlock()
+	var wg sync.WaitGroup
+	for _, s := range sessions {
+		wg.Add(1)
+		go func(s *debugSession) {
+			defer wg.Done()
+			s.close("backend shutting down")
+		}(s)
+	}
+	wg.Wait()
+}
+
+type DebugSessionInfo struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name,omitempty"`
+	Type        string    `json:"type"`
+	Request     string    `json:"request,omitempty"`
+	ParentID    string    `json:"parentId,omitempty"`
+	Status      string    `json:"status"`
+	Adapter     string    `json:"adapter,omitempty"`
+	Pid         int       `json:"pid,omitempty"`
+	DebuggeePid int       `json:"debuggeePid,omitempty"`
+	Attached    bool      `json:"attached"`
+	Started     time.Time `json:"started"`
+}
+
+func (s *debugSession) info() DebugSessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := DebugSessionInfo{
+		ID:          s.ID,
+		Name:        s.Name,
+		Type:        s.Type,
+		Request:     s.Request,
+		ParentID:    s.ParentID,
+		Status:      s.status,
+		Adapter:     s.command,
+		DebuggeePid: s.debuggeePid,
+		Attached:    s.client != nil,
+		Started:     s.started,
+	}
+	if s.cmd != nil {
+		info.Pid = s.cmd.Process.Pid
+	}
+	return info
+}
+
+type DebugAdapterInfo struct {
+	Type      string `json:"type"`
+	Available bool   `json:"available"`
+	Command   string `json:"command,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+type DebugRequest struct {
+	Operation      string                 `json:"operation"` // "start" o "stop"
+	Name           string                 `json:"name,omitempty"`
+	Configuration  map[string]interface{} `json:"configuration,omitempty"`
+	File           string                 `json:"file,omitempty"`
+	ParentID       string                 `json:"parentId,omitempty"`
+	ID             string                 `json:"id,omitempty"`
+	ProjectBaseDir string                 `json:"projectBaseDir,omitempty"`
+	// Source y ConfirmToken funcionan como en /terminal: lo que se lanza pasa por la política de comandos
+	Source       string `json:"source,omitempty"`
+	ConfirmToken string `json:"confirmToken,omitempty"`
+}
+
+type DebugResponse struct {
+	Success        bool                     `json:"success"`
+	Message        string                   `json:"message"`
+	Configurations []map[string]interface{} `json:"configurations,omitempty"`
+	ConfigSource   string                   `json:"configSource,omitempty"` // "launch.json" o "default"
+	Adapters       []DebugAdapterInfo       `json:"adapters,omitempty"`
+	Sessions       []DebugSessionInfo       `json:"sessions,omitempty"`
+	Session        *DebugSessionInfo        `json:"session,omitempty"`
+	// Denied/ConfirmationToken se devuelven cuando la política del workspace no deja lanzar la configuración
+	Denied            bool   `json:"denied,omitempty"`
+	ConfirmationToken string `json:"confirmationToken,omitempty"`
+}
+
+func listDebugAdapters(root string) []DebugAdapterInfo {
+	env := buildCommandEnv(root, nil)
+	var adapters []DebugAdapterInfo
+	for _, typ := range []string{"go", "python", "node"} {
+		info := DebugAdapterInfo{Type: typ}
+		var err error
+		if typ == "python" {
+			info.Command, err = debugPythonInterpreter(root, nil, env)
+		} else {
+			info.Command, _, _, err = debugAdapterCommand(root, typ, nil, env)
+		}
+		if err != nil {
+			info.Message = err.Error()
+		} else {
+			info.Available = true
+		}
+		adapters = append(adapters, info)
+	}
+	return adapters
+}
+
+func listDebugSessions(root string) []DebugSessionInfo {
+	debugSessionsMu.Lock()
+	var sessions []*debugSession
+	for _, s := range debugSessions {
+		if s.Root == root {
+			sessions = append(sessions, s)
+		}
+	}
+	debugSessionsMu.Unlock()
+	list := make([]DebugSessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, s.info())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+// debugHandler lista configuraciones, adaptadores y sesiones (GET) y arranca o detiene sesiones (POST)
+func debugHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var resp DebugResponse
+	switch r.Method {
+	case "GET":
+		root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+		configs, source, err := loadLaunchConfigurations(root)
+		if err != nil {
+			resp = DebugResponse{Success: false, Message: err.Error()}
+			break
+		}
+		resp = DebugResponse{
+			Success:        true,
+			Message:        "OK",
+			Configurations: configs,
+			ConfigSource:   source,
+			Adapters:       listDebugAdapters(root),
+			Sessions:       listDebugSessions(root),
+		}
+	case "POST":
+		var req DebugRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		root := workspaceRoot(req.ProjectBaseDir)
+		switch req.Operation {
+		case "start":
+			var parent *debugSession
+			if req.ParentID != "" {
+				if parent = getDebugSession(req.ParentID); parent == nil {
+					resp = DebugResponse{Success: false, Message: "Parent session not found: " + req.ParentID}
+					break
+				}
+			}
+			config := req.Configuration
+			if config == nil {
+				configs, _, err := loadLaunchConfigurations(root)
+				if err != nil {
+					resp = DebugResponse{Success: false, Message: err.Error()}
+					break
+				}
+				for _, c := range configs {
+					if c["name"] == req.Name {
+						config = c
+						break
+					}
+				}
+				if config == nil {
+					resp = DebugResponse{Success: false, Message: "Launch configuration not found: " + req.Name}
+					break
+				}
+			}
+			config = resolveLaunchConfiguration(root, req.File, config, buildCommandEnv(root, nil))
+			if err := checkEnvOverrides(debugConfigEnv(config)); err != nil {
+				resp = DebugResponse{Success: false, Message: err.Error()}
+				break
+			}
+			typ := normalizeDebugType(fmt.Sprint(config["type"]))
+			if parent != nil {
+				typ = parent.Type
+			}
+			launch := TerminalRequest{Command: debugLaunchCommand(typ, config), WorkingDir: fmt.Sprint(config["cwd"]), Source: requestSource(r, req.Source), ConfirmToken: req.ConfirmToken}
+			auth := authorizeCommand(root, "debug", launch)
+			if !auth.Allowed {
+				resp = DebugResponse{Success: false, Message: auth.Message, Denied: auth.Denied, ConfirmationToken: auth.ConfirmationToken}
+				break
+			}
+			s, err := startDebugSession(root, config, parent, launch.Source, auth.policy)
+			if err != nil {
+				resp = DebugResponse{Success: false, Message: err.Error()}
+				break
+			}
+			recordAudit(auth.root, AuditEntry{Source: launch.Source, Channel: "debug", Command: launch.Command, WorkingDir: launch.WorkingDir, Decision: auth.decision(), Rule: auth.Rule})
+			info := s.info()
+			resp = DebugResponse{Success: true, Message: "Debug session started", Session: &info}
+		case "stop":
+			s := getDebugSession(req.ID)
+			if s == nil {
+				resp = DebugResponse{Success: false, Message: "Debug session not found: " + req.ID}
+				break
+			}
+			s.close("stopped by the user")
+			resp = DebugResponse{Success: true, Message: "Debug session stopped"}
+		default:
+			resp = DebugResponse{Success: false, Message: "Unknown debug operation: " + req.Operation}
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type BreakpointsRequest struct {
+	Operation      string             `json:"operation"` // "set" o "clear"
+	Path           string             `json:"path,omitempty"`
+	Breakpoints    []SourceBreakpoint `json:"breakpoints,omitempty"`
+	ProjectBaseDir string             `json:"projectBaseDir,omitempty"`
+}
+
+type BreakpointsResponse struct {
+	Success     bool                          `json:"success"`
+	Message     string                        `json:"message"`
+	Breakpoints map[string][]SourceBreakpoint `json:"breakpoints,omitempty"`
+}
+
+// breakpointsHandler lee y cambia los breakpoints guardados; los cambios se aplican a las sesiones en curso
+func breakpointsHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var resp BreakpointsResponse
+	switch r.Method {
+	case "GET":
+		root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+		resp = BreakpointsResponse{Success: true, Message: "OK", Breakpoints: loadBreakpoints(root)}
+	case "POST":
+		// Los breakpoints se guardan en .airide y llegan a las sesiones abiertas: solo los cambia la interfaz
+		if !isUIRequest(r) {
+			http.Error(w, debugUserOnlyMessage, http.StatusForbidden)
+			return
+		}
+		var req BreakpointsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		root := workspaceRoot(req.ProjectBaseDir)
+		var changed []string
+		switch req.Operation {
+		case "set":
+			if req.Path == "" {
+				resp = BreakpointsResponse{Success: false, Message: "No path provided"}
+				break
+			}
+			changed = []string{resolveWorkspacePath(req.Path, req.ProjectBaseDir)}
+		case "clear":
+			if req.Path != "" {
+				changed = []string{resolveWorkspacePath(req.Path, req.ProjectBaseDir)}
+			} else {
+				for rel := range loadBreakpoints(root) {
+					changed = append(changed, filepath.Join(root, filepath.FromSlash(rel)))
+				}
+			}
+			req.Breakpoints = nil
+		default:
+			resp = BreakpointsResponse{Success: false, Message: "Unknown breakpoints operation: " + req.Operation}
+		}
+		if changed == nil {
+			if req.Operation == "clear" {
+				resp = BreakpointsResponse{Success: true, Message: "Breakpoints cleared"}
+			}
+			break
+		}
+		var err error
+		if req.Operation == "clear" && req.Path == "" {
+			err = saveBreakpoints(root, "", nil, true)
+		} else {
+			err = saveBreakpoints(root, changed[0], req.Breakpoints, false)
+		}
+		if err != nil {
+			resp = BreakpointsResponse{Success: false, Message: "Error saving breakpoints: " + err.Error()}
+			break
+		}
+		debugSessionsMu.Lock()
+		var sessions []*debugSession
+		for _, s := range debugSessions {
+			if s.Root == root {
+				sessions = append(sessions, s)
+			}
+		}
+		debugSessionsMu.Unlock()
+		for _, s := range sessions {
+			for _, path := range changed {
+				s.pushBreakpoints(path, req.Breakpoints)
+			}
+		}
+		message := "Breakpoints saved"
+		if req.Operation == "clear" {
+			message = "Breakpoints cleared"
+		}
+		resp = BreakpointsResponse{Success: true, Message: message, Breakpoints: loadBreakpoints(root)}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// debugWebSocketHandler conecta el editor a una sesión (?id=...). Cada mensaje de texto es un mensaje DAP
+// completo, sin cabeceras Content-Length. Al desconectarse el editor la sesión termina.
+func debugWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	// Por aquí se puede evaluar código arbitrario en el programa depurado, igual que en una terminal interactiva
+	if !isUIRequest(r) {
+		http.Error(w, debugUserOnlyMessage, http.StatusForbidden)
+		return
+	}
+	s := getDebugSession(r.URL.Query().Get("id"))
+	if s == nil {
+		http.Error(w, "Debug session not found", http.StatusNotFound)
+		return
+	}
+	c := &debugClient{send: make(chan []byte, debugClientQueue), done: make(chan struct{})}
+	if err := s.attach(c); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	defer s.close("editor disconnected")
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Println("[BACK] WebSocket upgrade failed:", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(lspMaxMessage)
+
+	go func() {
+		defer c.close()
+		for {
+			kind, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if kind == websocket.TextMessage || kind == websocket.BinaryMessage {
+				s.fromClient(msg)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case msg := <-c.send:
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-c.done:
+			// Vaciar lo que quede (p. ej. terminated) antes de cerrar
+		drain:
+			for {
+				select {
+				case msg := <-c.send:
+					conn.WriteMessage(websocket.TextMessage, msg)
+				default:
+					break drain
+				}
+			}
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
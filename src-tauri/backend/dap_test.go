@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugLaunchCommand(t *testing.T) {
+	tests := []struct {
+		typ    string
+		config map[string]interface{}
+		want   string
+	}{
+		{"go", map[string]interface{}{"request": "launch", "program": "/ws/cmd/api", "args": []interface{}{"-port", "8081"}}, "dlv debug /ws/cmd/api -- -port 8081"},
+		{"go", map[string]interface{}{"request": "launch", "mode": "test", "program": "/ws/pkg", "buildFlags": "-tags=integration"}, "dlv test -tags=integration /ws/pkg"},
+		{"python", map[string]interface{}{"request": "launch", "program": "/ws/app.py", "args": []interface{}{"a b"}}, "python /ws/app.py 'a b'"},
+		{"python", map[string]interface{}{"request": "launch", "python": "/tmp/evil", "module": "pytest"}, "/tmp/evil -m pytest"},
+		{"node", map[string]interface{}{"request": "launch", "runtimeExecutable": "npm", "runtimeArgs": []interface{}{"run", "dev"}, "program": ""}, "npm run dev"},
+		{"node", map[string]interface{}{"request": "launch", "program": "/ws/server.js"}, "node /ws/server.js"},
+		{"go", map[string]interface{}{"request": "attach", "processId": 42.0}, "attach 42"},
+	}
+	for _, tt := range tests {
+		if got := debugLaunchCommand(tt.typ, tt.config); got != tt.want {
+			t.Errorf("debugLaunchCommand(%s, %v) = %q, want %q", tt.typ, tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestDebugLaunchPolicy(t *testing.T) {
+	// Un programa que se lanza con bash -c pasa por las mismas reglas que /terminal
+	config := map[string]interface{}{"request": "launch", "runtimeExecutable": "bash", "runtimeArgs": []interface{}{"-c", "rm -rf ~"}, "program": "x"}
+	if decision, _ := evaluateCommand(CommandPolicySettings{}, debugLaunchCommand("node", config), "ai"); decision != "denied" {
+		t.Errorf("decision = %s, want denied", decision)
+	}
+}
+
+func TestDebugHandlersRequireUI(t *testing.T) {
+	root := t.TempDir()
+	uiToken = "secret"
+	defer func() { uiToken = "" }()
+
+	body := `{"operation":"set","path":"main.go","breakpoints":[{"line":3}],"projectBaseDir":"` + filepath.ToSlash(root) + `"}`
+	w := httptest.NewRecorder()
+	breakpointsHandler(w, httptest.NewRequest("POST", "/api/debug/breakpoints", strings.NewReader(body)))
+	if w.Code != 403 || len(loadBreakpoints(root)) != 0 {
+		t.Fatalf("breakpoints changed without the UI token: status %d", w.Code)
+	}
+	r := httptest.NewRequest("POST", "/api/debug/breakpoints", strings.NewReader(body))
+	r.Header.Set(uiTokenHeader, "secret")
+	w = httptest.NewRecorder()
+	breakpointsHandler(w, r)
+	if w.Code != 200 || len(loadBreakpoints(root)) != 1 {
+		t.Fatalf("UI breakpoints: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	debugWebSocketHandler(w, httptest.NewRequest("GET", "/api/debug/ws?id=x", nil))
+	if w.Code != 403 {
+		t.Fatalf("debug WebSocket without the UI token: status %d", w.Code)
+	}
+}
+
+func TestResolveLaunchConfiguration(t *testing.T) {
+	root := filepath.FromSlash("/ws/proj")
+	env := &commandEnv{vars: map[string]envVar{}}
+	env.set("GREETING", "hi", "process")
+	config := map[string]interface{}{
+		"program": "${file}",
+		"args":    []interface{}{"${fileBasenameNoExtension}", "${env:GREETING}", "${unknown}"},
+		"env":     map[string]interface{}{"DIR": "${fileDirname}", "WS": "${workspaceFolderBasename}"},
+	}
+	got := resolveLaunchConfiguration(root, "src/main.py", config, env)
+	file := filepath.Join(root, "src", "main.py")
+	if got["program"] != file {
+		t.Errorf("program = %v, want %s", got["program"], file)
+	}
+	args := got["args"].([]interface{})
+	if args[0] != "main" || args[1] != "hi" || args[2] != "${unknown}" {
+		t.Errorf("args = %v", args)
+	}
+	vars := got["env"].(map[string]interface{})
+	if vars["DIR"] != filepath.Join(root, "src") || vars["WS"] != "proj" {
+		t.Errorf("env = %v", vars)
+	}
+	if got["cwd"] != root || got["request"] != "launch" {
+		t.Errorf("defaults: cwd = %v, request = %v", got["cwd"], got["request"])
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cantidad de líneas de contexto alrededor de cada cambio en un diff unificado
+const diffContextLines = 3
+
+// Trabajo máximo (líneas × ediciones) de un diff; textos más distintos que eso se informan solo como distintos
+const diffMaxWork = 50_000_000
+
+type diffOp struct {
+	kind byte // ' ' igual, '-' borrada, '+' agregada
+	line string
+}
+
+// differ calcula diffs con el algoritmo de Myers en espacio lineal: busca la "middle snake" avanzando desde
+// el principio y desde el final a la vez, parte el problema en ese punto y sigue con cada mitad
+type differ struct {
+	a, b []string
+	ops  []diffOp
+}
+
+// diffLines calcula la secuencia de edición mínima entre dos listas de líneas. Devuelve false si los textos
+// son tan distintos que el diff costaría más de diffMaxWork.
+func diffLines(a, b []string) ([]diffOp, bool) {
+	d := &differ{a: a, b: b}
+	if !d.compare(0, len(a), 0, len(b)) {
+		return nil, false
+	}
+	return d.ops, true
+}
+
+func (d *differ) emit(kind byte, lines []string) {
+	for _, line := range lines {
+		d.ops = append(d.ops, diffOp{kind, line})
+	}
+}
+
+// compare agrega las operaciones que convierten a[a0:a1] en b[b0:b1]
+func (d *differ) compare(a0, a1, b0, b1 int) bool {
+	// El principio y el final comunes no necesitan búsqueda
+	prefix := a0
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		a0++
+		b0++
+	}
+	d.emit(' ', d.a[prefix:a0])
+	suffix := a1
+	for a1 > a0 && b1 > b0 && d.a[a1-1] == d.b[b1-1] {
+		a1--
+		b1--
+	}
+	switch {
+	case a0 == a1:
+		d.emit('+', d.b[b0:b1])
+	case b0 == b1:
+		d.emit('-', d.a[a0:a1])
+	default:
+		x, y, ok := d.middleSnake(a0, a1, b0, b1)
+		if !ok {
+			return false
+		}
+		if x < 0 {
+			// Sin ninguna línea en común
+			d.emit('-', d.a[a0:a1])
+			d.emit('+', d.b[b0:b1])
+		} else if !d.compare(a0, x, b0, y) || !d.compare(x, a1, y, b1) {
+			return false
+		}
+	}
+	d.emit(' ', d.a[a1:suffix])
+	return true
+}
+
+// middleSnake devuelve un punto (x, y) de un camino de edición mínimo entre a[a0:a1] y b[b0:b1], o x = -1 si
+// no tienen líneas en común. Usa O(n+m) memoria; ok es false si se pasa de diffMaxWork.
+func (d *differ) middleSnake(a0, a1, b0, b1 int) (x, y int, ok bool) {
+	n, m := a1-a0, b1-b0
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// vf guarda el x más lejano de cada diagonal hacia adelante; vb lo mismo hacia atrás, medido desde el final
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+	delta := n - m
+	// Con delta impar los caminos se encuentran durante el paso hacia adelante, con delta par en el de atrás
+	front := delta%2 != 0
+	// Diagonales que ya se salieron de la grilla y no hace falta seguir
+	kfStart, kfEnd, kbStart, kbEnd := 0, 0, 0, 0
+	for step := 0; step < maxD; step++ {
+		if (n+m)*step > diffMaxWork {
+			return 0, 0, false
+		}
+		for k := -step + kfStart; k <= step-kfEnd; k += 2 {
+			i := offset + k
+			var fx int
+			if k == -step || (k != step && vf[i-1] < vf[i+1]) {
+				fx = vf[i+1]
+			} else {
+				fx = vf[i-1] + 1
+			}
+			fy := fx - k
+			for fx < n && fy < m && d.a[a0+fx] == d.b[b0+fy] {
+				fx++
+				fy++
+			}
+			vf[i] = fx
+			switch {
+			case fx > n:
+				kfEnd += 2
+			case fy > m:
+				kfStart += 2
+			case front:
+				j := offset + delta - k
+				if j >= 0 && j < len(vb) && vb[j] != -1 && fx >= n-vb[j] {
+					return a0 + fx, b0 + fy, true
+				}
+			}
+		}
+		for k := -step + kbStart; k <= step-kbEnd; k += 2 {
+			i := offset + k
+			var bx int
+			if k == -step || (k != step && vb[i-1] < vb[i+1]) {
+				bx = vb[i+1]
+			} else {
+				bx = vb[i-1] + 1
+			}
+			by := bx - k
+			for bx < n && by < m && d.a[a1-bx-1] == d.b[b1-by-1] {
+				bx++
+				by++
+			}
+			vb[i] = bx
+			switch {
+			case bx > n:
+				kbEnd += 2
+			case by > m:
+				kbStart += 2
+			case !front:
+				j := offset + delta - k
+				if j >= 0 && j < len(vf) && vf[j] != -1 {
+					fx := vf[j]
+					fy := fx - (j - offset)
+					if fx >= n-bx {
+						return a0 + fx, b0 + fy, true
+					}
+				}
+			}
+		}
+	}
+	return -1, -1, true
+}
+
+// unifiedDiff genera un diff en formato unificado (como `diff -u`) entre dos textos
+func unifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	// Cada línea conserva su salto: así la última línea sin salto final difiere de la misma línea con salto
+	a := splitDiffLines(from)
+	b := splitDiffLines(to)
+	ops, ok := diffLines(a, b)
+	if !ok {
+		return fmt.Sprintf("Files %s and %s differ\n", fromName, toName)
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	// Agrupar los cambios en hunks con diffContextLines de contexto
+	i := 0
+	aLine, bLine := 1, 1
+	for i < len(ops) {
+		if ops[i].kind == ' ' {
+			i++
+			aLine++
+			bLine++
+			continue
+		}
+		start := i - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		hunkA := aLine - (i - start)
+		hunkB := bLine - (i - start)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			// Terminar el hunk si hay más de 2*contexto líneas iguales seguidas
+			run := 0
+			for end+run < len(ops) && ops[end+run].kind == ' ' {
+				run++
+			}
+			if end+run == len(ops) || run > 2*diffContextLines {
+				if run > diffContextLines {
+					run = diffContextLines
+				}
+				end += run
+				break
+			}
+			end += run
+		}
+		countA, countB := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		if countA == 0 {
+			hunkA--
+		}
+		if countB == 0 {
+			hunkB--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", hunkA, countA, hunkB, countB)
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// splitDiffLines parte el texto en líneas que terminan en su "\n", salvo quizás la última
+func splitDiffLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
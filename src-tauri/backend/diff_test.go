@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// lcsLength es la referencia cuadrática: una edición mínima borra y agrega todo lo que no está en la LCS
+func lcsLength(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func checkDiffOps(t *testing.T, a, b []string, ops []diffOp) {
+	t.Helper()
+	var gotA, gotB []string
+	edits := 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			gotA = append(gotA, op.line)
+		}
+		if op.kind != '-' {
+			gotB = append(gotB, op.line)
+		}
+		if op.kind != ' ' {
+			edits++
+		}
+	}
+	if strings.Join(gotA, "\n") != strings.Join(a, "\n") || len(gotA) != len(a) {
+		t.Fatalf("ops do not rebuild a: %q from %q", gotA, a)
+	}
+	if strings.Join(gotB, "\n") != strings.Join(b, "\n") || len(gotB) != len(b) {
+		t.Fatalf("ops do not rebuild b: %q from %q", gotB, b)
+	}
+	if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+		t.Fatalf("diff of %q -> %q has %d edits, minimum is %d", a, b, edits, want)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"both empty", "", ""},
+		{"insert all", "", "a b c"},
+		{"delete all", "a b c", ""},
+		{"equal", "a b c", "a b c"},
+		{"replace middle", "a b c", "a x c"},
+		{"myers paper", "a b c a b b a", "c b a b a c"},
+		{"prepend and append", "b c", "a b c d"},
+		{"repeated lines", "x x x y", "y x x x"},
+	}
+	split := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, " ")
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := split(tt.a), split(tt.b)
+			ops, ok := diffLines(a, b)
+			if !ok {
+				t.Fatal("diff gave up")
+			}
+			checkDiffOps(t, a, b, ops)
+		})
+	}
+
+	// Entradas aleatorias con pocas líneas distintas para forzar muchas coincidencias parciales
+	rng := rand.New(rand.NewSource(1))
+	random := func(size int) []string {
+		lines := make([]string, rng.Intn(size))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(3)))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		size := 12
+		if i%5 == 0 {
+			// Textos más largos para que la búsqueda se parta varias veces
+			size = 200
+		}
+		a, b := random(size), random(size)
+		ops, ok := diffLines(a, b)
+		if !ok {
+			t.Fatal("diff gave up")
+		}
+		checkDiffOps(t, a, b, ops)
+	}
+}
+
+func TestDiffLinesGivesUp(t *testing.T) {
+	// Dos textos grandes sin líneas en común superan el trabajo máximo
+	a := make([]string, 20000)
+	b := make([]string, 20000)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d", i)
+		b[i] = fmt.Sprintf("b%d", i)
+	}
+	if _, ok := diffLines(a, b); ok {
+		t.Fatal("expected diffLines to give up")
+	}
+	if got := unifiedDiff("old", "new", strings.Join(a, "\n"), strings.Join(b, "\n")); got != "Files old and new differ\n" {
+		t.Errorf("unifiedDiff = %q", got[:min(len(got), 80)])
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{"no changes", "a\nb\n", "a\nb\n", ""},
+		{"new file", "", "a\nb\n", "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"deleted file", "a\n", "", "--- old\n+++ new\n@@ -1,1 +0,0 @@\n-a\n"},
+		{
+			"change with context",
+			"1\n2\n3\n4\n5\n6\n7\n8\n",
+			"1\n2\n3\n4\nfive\n6\n7\n8\n",
+			"--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			"separate hunks",
+			"a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n",
+			"A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-b\n+B\n",
+		},
+		{"final newline removed", "x\n", "x", "--- old\n+++ new\n@@ -1,1 +1,1 @@\n-x\n+x\n\\ No newline at end of file\n"},
+		{"final newline added", "a\nx", "a\nx\n", "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-x\n\\ No newline at end of file\n+x\n"},
+		{"change before a line without newline", "a\nb", "A\nb", "--- old\n+++ new\n@@ -1,2 +1,2 @@\n-a\n+A\n b\n\\ No newline at end of file\n"},
+		{
+			"close changes share a hunk",
+			"a\n1\n2\nb\n",
+			"A\n1\n2\nB\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n-b\n+B\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", tt.from, tt.to); got != tt.want {
+				t.Errorf("unifiedDiff =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tiempo durante el cual un token de confirmación (borrado recursivo, comandos peligrosos) sigue siendo válido
+const confirmTokenTTL = 2 * time.Minute
+
+// FileStat es la información detallada que devuelve la operación stat
+type FileStat struct {
+	Name          string    `json:"name"`
+	Path          string    `json:"path"`
+	IsDir         bool      `json:"isDir"`
+	IsSymlink     bool      `json:"isSymlink"`
+	SymlinkTarget string    `json:"symlinkTarget,omitempty"`
+	Size          int64     `json:"size"`
+	Mode          string    `json:"mode"`
+	Permissions   string    `json:"permissions"`
+	Modified      time.Time `json:"modified"`
+}
+
+type confirmToken struct {
+	key     string
+	expires time.Time
+}
+
+var (
+	confirmTokensMu sync.Mutex
+	confirmTokens   = map[string]confirmToken{}
+)
+
+// newConfirmToken emite un token de un solo uso que autoriza la acción identificada por key
+// (la ruta a borrar recursivamente, el comando a ejecutar...)
+func newConfirmToken(key string) string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+	confirmTokensMu.Lock()
+	defer confirmTokensMu.Unlock()
+	for t, dt := range confirmTokens {
+		if time.Now().After(dt.expires) {
+			delete(confirmTokens, t)
+		}
+	}
+	confirmTokens[token] = confirmToken{key: key, expires: time.Now().Add(confirmTokenTTL)}
+	return token
+}
+
+// consumeConfirmToken valida y descarta el token; solo sirve para la misma acción que lo originó
+func consumeConfirmToken(token, key string) bool {
+	confirmTokensMu.Lock()
+	defer confirmTokensMu.Unlock()
+	dt, ok := confirmTokens[token]
+	if !ok {
+		return false
+	}
+	delete(confirmTokens, token)
+	return dt.key == key && time.Now().Before(dt.expires)
+}
+
+// validConfirmToken comprueba el token sin consumirlo (validación previa de un lote)
+func validConfirmToken(token, key string) bool {
+	confirmTokensMu.Lock()
+	defer confirmTokensMu.Unlock()
+	dt, ok := confirmTokens[token]
+	return ok && dt.key == key && time.Now().Before(dt.expires)
+}
+
+// makeDirectory crea el directorio y todos los padres que falten (mkdir -p)
+func makeDirectory(baseDir string, path string) FileResponse {
+	path = resolveWorkspacePath(path, baseDir)
+	fmt.Printf("[BACK] makeDirectory called with path: '%s'\n", path)
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return FileResponse{Success: false, Message: "A file with that name already exists: " + path}
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return FileResponse{Success: false, Message: "Error creating directory: " + err.Error()}
+	}
+	return FileResponse{Success: true, Message: "Directory created successfully"}
+}
+
+// statFile devuelve tamaño, permisos, fecha y destino del symlink sin seguirlo
+func statFile(baseDir string, path string) FileResponse {
+	path = resolveWorkspacePath(path, baseDir)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return FileResponse{Success: false, Message: "Error reading file info: " + err.Error()}
+	}
+	stat := &FileStat{
+		Name:        info.Name(),
+		Path:        path,
+		IsDir:       info.IsDir(),
+		Size:        info.Size(),
+		Mode:        info.Mode().String(),
+		Permissions: fmt.Sprintf("%04o", info.Mode().Perm()),
+		Modified:    info.ModTime(),
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		stat.IsSymlink = true
+		stat.SymlinkTarget, _ = os.Readlink(path)
+		// IsDir refleja a qué apunta el enlace
+		if target, err := os.Stat(path); err == nil {
+			stat.IsDir = target.IsDir()
+		}
+	}
+	return FileResponse{Success: true, Message: "File info read successfully", Stat: stat}
+}
+
+// isSubPath indica si child está dentro de parent (o es el mismo)
+func isSubPath(parent, child string) bool {
+	rel, err := filepath.Rel(parent, child)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// realPath resuelve los symlinks del ancestro existente más cercano de p y le vuelve a agregar la parte que todavía
+// no existe, para comprobar dónde terminaría escribiéndose una ruta nueva
+func realPath(p string) string {
+	p = filepath.Clean(p)
+	var missing []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(append([]string{p}, missing...)...)
+		}
+		missing = append(missing, filepath.Base(p))
+		p = parent
+	}
+}
+
+// copyPath copia archivos o directorios completos conservando permisos y symlinks
+func copyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := copyPath(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return copyRegularFile(src, dst, info.Mode().Perm())
+	}
+}
+
+func copyRegularFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// prepareDestination valida origen/destino de copy y move y, si se pidió sobrescribir, manda el destino a la papelera
+// Devuelve el ID en la papelera del destino reemplazado, si lo hubo.
+func prepareDestination(root, src, dst string, overwrite bool) (string, error) {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return "", fmt.Errorf("source does not exist: %s", src)
+	}
+	if filepath.Clean(src) == filepath.Clean(dst) {
+		return "", fmt.Errorf("source and destination are the same: %s", src)
+	}
+	if info.IsDir() && isSubPath(src, dst) {
+		return "", fmt.Errorf("cannot copy or move a directory into itself")
+	}
+	replacedID := ""
+	if _, err := os.Lstat(dst); err == nil {
+		if !overwrite {
+			return "", fmt.Errorf("destination already exists: %s", dst)
+		}
+		// El destino reemplazado va a la papelera para poder recuperarlo
+		if replacedID, err = moveToTrash(root, dst); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		restoreReplaced(root, replacedID)
+		return "", err
+	}
+	return replacedID, nil
+}
+
+// restoreReplaced devuelve desde la papelera el destino que se iba a sobrescribir cuando la operación falla
+func restoreReplaced(root, replacedID string) {
+	if replacedID == "" {
+		return
+	}
+	if _, err := restoreFromTrash(root, replacedID, false); err != nil {
+		fmt.Printf("[BACK] Could not restore overwritten file %s from trash: %v\n", replacedID, err)
+	}
+}
+
+// copyFileOrDir implementa la operación copy (recursiva para directorios)
+func copyFileOrDir(baseDir string, srcPath, dstPath string, overwrite bool) FileResponse {
+	src := resolveWorkspacePath(srcPath, baseDir)
+	dst := resolveWorkspacePath(dstPath, baseDir)
+	fmt.Printf("[BACK] copyFileOrDir called with src: '%s', dst: '%s'\n", src, dst)
+	replacedID, err := prepareDestination(workspaceRoot(baseDir), src, dst, overwrite)
+	if err != nil {
+		return FileResponse{Success: false, Message: "Error copying: " + err.Error()}
+	}
+	if err := copyPath(src, dst); err != nil {
+		// No dejar una copia a medias y recuperar lo que había en el destino
+		os.RemoveAll(dst)
+		restoreReplaced(workspaceRoot(baseDir), replacedID)
+		return FileResponse{Success: false, Message: "Error copying: " + err.Error()}
+	}
+	recordUndo(workspaceRoot(baseDir), undoEntry{Operation: "copy", Path: src, NewPath: dst, ReplacedTrashID: replacedID})
+	return FileResponse{Success: true, Message: "Copied successfully"}
+}
+
+// moveFileOrDir implementa la operación move; entre dispositivos distintos copia y luego borra el origen
+func moveFileOrDir(baseDir string, srcPath, dstPath string, overwrite bool) FileResponse {
+	src := resolveWorkspacePath(srcPath, baseDir)
+	dst := resolveWorkspacePath(dstPath, baseDir)
+	fmt.Printf("[BACK] moveFileOrDir called with src: '%s', dst: '%s'\n", src, dst)
+	replacedID, err := prepareDestination(workspaceRoot(baseDir), src, dst, overwrite)
+	if err != nil {
+		return FileResponse{Success: false, Message: "Error moving: " + err.Error()}
+	}
+	if err := movePath(src, dst); err != nil {
+		restoreReplaced(workspaceRoot(baseDir), replacedID)
+		return FileResponse{Success: false, Message: "Error moving: " + err.Error()}
+	}
+	recordUndo(workspaceRoot(baseDir), undoEntry{Operation: "move", Path: src, NewPath: dst, ReplacedTrashID: replacedID})
+	return FileResponse{Success: true, Message: "Moved successfully"}
+}
+
+// renamePath es os.Rename; los tests lo reemplazan para simular un movimiento entre dispositivos
+var renamePath = os.Rename
+
+// movePath renombra src a dst; si están en dispositivos distintos copia y luego borra el origen
+func movePath(src, dst string) error {
+	err := renamePath(src, dst)
+	if err != nil && isCrossDeviceError(err) {
+		if err = copyPath(src, dst); err != nil {
+			os.RemoveAll(dst)
+		} else {
+			err = os.RemoveAll(src)
+		}
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestCopyAndMove(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		src, dst  string
+		overwrite bool
+		wantErr   string
+		// want son los archivos esperados al terminar (ruta relativa -> contenido); "" significa que no existe
+		want map[string]string
+	}{
+		{
+			name: "recursive copy", operation: "copy", src: "src", dst: "copy",
+			want: map[string]string{"src/a.txt": "a", "src/sub/b.txt": "b", "copy/a.txt": "a", "copy/sub/b.txt": "b"},
+		},
+		{
+			name: "move directory", operation: "move", src: "src", dst: "moved/src",
+			want: map[string]string{"src/a.txt": "", "moved/src/a.txt": "a", "moved/src/sub/b.txt": "b"},
+		},
+		{
+			name: "copy into itself", operation: "copy", src: "src", dst: "src/sub/copy",
+			wantErr: "into itself", want: map[string]string{"src/sub/copy/a.txt": ""},
+		},
+		{
+			name: "move into itself", operation: "move", src: "src", dst: "src/inner",
+			wantErr: "into itself", want: map[string]string{"src/a.txt": "a"},
+		},
+		{
+			name: "same file", operation: "move", src: "top.txt", dst: "./top.txt",
+			wantErr: "are the same", want: map[string]string{"top.txt": "top"},
+		},
+		{
+			name: "existing destination", operation: "copy", src: "top.txt", dst: "src/a.txt",
+			wantErr: "already exists", want: map[string]string{"src/a.txt": "a"},
+		},
+		{
+			name: "overwrite", operation: "move", src: "top.txt", dst: "src/a.txt", overwrite: true,
+			want: map[string]string{"top.txt": "", "src/a.txt": "top"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestFile(t, filepath.Join(root, "src", "a.txt"), "a")
+			writeTestFile(t, filepath.Join(root, "src", "sub", "b.txt"), "b")
+			writeTestFile(t, filepath.Join(root, "top.txt"), "top")
+			var resp FileResponse
+			if tt.operation == "copy" {
+				resp = copyFileOrDir(root, tt.src, tt.dst, tt.overwrite)
+			} else {
+				resp = moveFileOrDir(root, tt.src, tt.dst, tt.overwrite)
+			}
+			if tt.wantErr == "" && !resp.Success {
+				t.Fatalf("%s failed: %s", tt.operation, resp.Message)
+			}
+			if tt.wantErr != "" && (resp.Success || !strings.Contains(resp.Message, tt.wantErr)) {
+				t.Fatalf("%s = %+v, want error %q", tt.operation, resp, tt.wantErr)
+			}
+			for rel, want := range tt.want {
+				got, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+				if want == "" {
+					if err == nil {
+						t.Errorf("%s still exists", rel)
+					}
+				} else if string(got) != want {
+					t.Errorf("%s = %q (%v), want %q", rel, got, err, want)
+				}
+			}
+			if tt.overwrite && len(listTrash(root)) != 1 {
+				t.Errorf("overwritten destination not in the trash: %+v", listTrash(root))
+			}
+		})
+	}
+}
+
+func TestMoveAcrossDevices(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the cross-device error is ERROR_NOT_SAME_DEVICE on Windows")
+	}
+	renamed := 0
+	renamePath = func(src, dst string) error {
+		renamed++
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() { renamePath = os.Rename })
+
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "src", "a.txt"), "a")
+	writeTestFile(t, filepath.Join(root, "src", "sub", "b.txt"), "b")
+	if resp := moveFileOrDir(root, "src", "dst", false); !resp.Success {
+		t.Fatal(resp.Message)
+	}
+	if renamed != 1 {
+		t.Fatalf("rename called %d times", renamed)
+	}
+	if _, err := os.Stat(filepath.Join(root, "src")); !os.IsNotExist(err) {
+		t.Error("source left behind after copying across devices")
+	}
+	if readTestFile(t, filepath.Join(root, "dst", "sub", "b.txt")) != "b" {
+		t.Error("directory not copied across devices")
+	}
+
+	// Otros errores del rename no disparan la copia
+	renamePath = func(src, dst string) error {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: syscall.EACCES}
+	}
+	if resp := moveFileOrDir(root, "dst", "again", false); resp.Success {
+		t.Fatal("move succeeded after a permission error")
+	}
+	if _, err := os.Stat(filepath.Join(root, "again")); !os.IsNotExist(err) {
+		t.Error("permission error fell back to copying")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDeviceError indica si un rename falló porque origen y destino están en sistemas de archivos distintos
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
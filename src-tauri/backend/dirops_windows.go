@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ERROR_NOT_SAME_DEVICE: MoveFileEx no puede mover un archivo a otra unidad
+const errorNotSameDevice syscall.Errno = 0x11
+
+// isCrossDeviceError indica si un rename falló porque origen y destino están en unidades distintas
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// textEncodings son los charsets que el backend sabe leer y volver a escribir (además de utf-8).
+// UTF-16 ignora el BOM: se decodifica como U+FEFF, que formatTextForSave conserva o quita igual que el BOM UTF-8.
+var textEncodings = map[string]encoding.Encoding{
+	"utf-16le":     unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be":     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"shift_jis":    japanese.ShiftJIS,
+	"euc-jp":       japanese.EUCJP,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"windows-1252": charmap.Windows1252,
+}
+
+// binaryExtensions son formatos que nunca se abren como texto aunque el inicio del contenido lo parezca.
+// Solo se consultan después de inspeccionar el contenido: extensiones como .ts son a la vez código y video.
+var binaryExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".bmp": true, ".ico": true,
+	".pdf": true, ".zip": true, ".gz": true, ".tgz": true, ".tar": true, ".7z": true, ".rar": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".wasm": true,
+	".mp3": true, ".wav": true, ".ogg": true, ".flac": true, ".mp4": true, ".mov": true, ".avi": true, ".webm": true,
+	".exe": true, ".dll": true, ".so": true, ".dylib": true, ".class": true, ".jar": true,
+}
+
+// normalizeCharset unifica los alias más comunes de los nombres de charset
+func normalizeCharset(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "", "utf8", "utf-8":
+		return "utf-8"
+	case "utf16le", "utf-16":
+		return "utf-16le"
+	case "utf16be":
+		return "utf-16be"
+	case "sjis", "shift-jis", "shiftjis", "cp932":
+		return "shift_jis"
+	case "latin1", "latin-1", "iso8859-1":
+		return "iso-8859-1"
+	case "cp1252":
+		return "windows-1252"
+	}
+	return name
+}
+
+// detectCharset deduce la codificación del contenido: BOM UTF-16, UTF-8 válido, Shift-JIS o Latin-1
+func detectCharset(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	}
+	if le, be := utf16NulPattern(content); le {
+		return "utf-16le"
+	} else if be {
+		return "utf-16be"
+	}
+	if utf8.Valid(content) {
+		return "utf-8"
+	}
+	if looksLikeShiftJIS(content) {
+		return "shift_jis"
+	}
+	// Los bytes 0x80-0x9F son de control en ISO-8859-1 pero imprimibles en windows-1252
+	for _, b := range content {
+		if b >= 0x80 && b <= 0x9F {
+			return "windows-1252"
+		}
+	}
+	return "iso-8859-1"
+}
+
+// utf16NulPattern detecta texto UTF-16 sin BOM: texto ASCII codificado deja un NUL en cada par de bytes
+func utf16NulPattern(content []byte) (le, be bool) {
+	sample := content
+	if len(sample) > binarySniffLen {
+		sample = sample[:binarySniffLen]
+	}
+	if len(sample) < 4 || len(sample)%2 != 0 {
+		return false, false
+	}
+	evenNul, oddNul := 0, 0
+	for i := 0; i < len(sample); i += 2 {
+		if sample[i] == 0 {
+			evenNul++
+		}
+		if sample[i+1] == 0 {
+			oddNul++
+		}
+	}
+	pairs := len(sample) / 2
+	// Más del 90% de los pares con NUL en una sola mitad y ninguno en la otra
+	return oddNul*10 > pairs*9 && evenNul == 0, evenNul*10 > pairs*9 && oddNul == 0
+}
+
+// looksLikeShiftJIS comprueba que todas las secuencias multibyte sean pares lead/trail válidos de Shift-JIS
+func looksLikeShiftJIS(content []byte) bool {
+	doubleBytes := 0
+	for i := 0; i < len(content); i++ {
+		b := content[i]
+		switch {
+		case b < 0x80 || (b >= 0xA1 && b <= 0xDF):
+			// ASCII o katakana de medio ancho
+		case (b >= 0x81 && b <= 0x9F) || (b >= 0xE0 && b <= 0xFC):
+			if i+1 >= len(content) {
+				return false
+			}
+			t := content[i+1]
+			if t < 0x40 || t == 0x7F || t > 0xFC {
+				return false
+			}
+			doubleBytes++
+			i++
+		default:
+			return false
+		}
+	}
+	return doubleBytes > 0
+}
+
+// decodeText convierte el contenido en el charset dado a UTF-8 para el editor
+func decodeText(content []byte, charset string) (string, error) {
+	charset = normalizeCharset(charset)
+	if charset == "utf-8" {
+		return string(content), nil
+	}
+	enc, ok := textEncodings[charset]
+	if !ok {
+		return "", fmt.Errorf("unsupported encoding: %s", charset)
+	}
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// encodeText convierte el texto UTF-8 del editor de vuelta al charset original del archivo
+func encodeText(text string, charset string) ([]byte, error) {
+	charset = normalizeCharset(charset)
+	if charset == "utf-8" {
+		return []byte(text), nil
+	}
+	enc, ok := textEncodings[charset]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding: %s", charset)
+	}
+	encoded, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("content cannot be represented in %s: %v", charset, err)
+	}
+	return encoded, nil
+}
+
+// encodeForSave prepara el contenido del editor para guardarlo en path: lo vuelve a codificar en el charset del
+// archivo en disco (o el que indique el editor) con su formato de texto. previous es el contenido que había en disco,
+// nil si el archivo no existía.
+func encodeForSave(path, content, encoding string, settings FileSettings) (data, previous []byte, charset string, err error) {
+	charset = normalizeCharset(encoding)
+	var original []byte
+	if existing, readErr := os.ReadFile(path); readErr == nil {
+		previous = existing
+		original = existing
+		if encoding == "" {
+			charset = detectCharset(existing)
+		}
+		if decoded, err := decodeText(existing, charset); err == nil {
+			original = []byte(decoded)
+		}
+	}
+	text := string(formatTextForSave(content, original, settings))
+	// Solo UTF-8 y UTF-16 tienen BOM; en el resto de charsets U+FEFF ni siquiera se puede codificar
+	if !strings.HasPrefix(charset, "utf-") {
+		text = strings.TrimPrefix(text, string(utf8BOM))
+	}
+	data, err = encodeText(text, charset)
+	return data, previous, charset, err
+}
+
+// detectMimeType usa la extensión y, si no alcanza, el contenido del archivo
+func detectMimeType(path string, content []byte) string {
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); t != "" {
+		return t
+	}
+	return http.DetectContentType(content)
+}
+
+// isBinaryFile decide si el archivo debe transportarse como base64 en lugar de texto.
+// Manda el contenido (bytes NUL o la firma que reconoce http.DetectContentType); la extensión solo desempata.
+func isBinaryFile(path, charset string, content []byte) bool {
+	if strings.HasPrefix(charset, "utf-16") {
+		return false
+	}
+	if isBinaryContent(content) {
+		return true
+	}
+	if !strings.HasPrefix(http.DetectContentType(content), "text/") {
+		return true
+	}
+	return binaryExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// rawFileHandler sirve los bytes de un archivo tal cual (vista previa de imágenes, PDFs, descargas)
+func rawFileHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := resolveWorkspacePath(r.URL.Query().Get("path"), r.URL.Query().Get("projectBaseDir"))
+	fmt.Printf("[BACK] /api/raw serving '%s'\n", path)
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "Error opening file: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "Not a regular file", http.StatusBadRequest)
+		return
+	}
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	w.Header().Set("Content-Type", detectMimeType(path, head[:n]))
+	// Evita que un SVG o HTML servido desde aquí ejecute scripts en el origen del backend
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
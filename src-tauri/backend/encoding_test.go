@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsBinaryFile(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	tests := []struct {
+		name    string
+		path    string
+		charset string
+		content []byte
+		want    bool
+	}{
+		{"typescript source", "src/app.ts", "utf-8", []byte("export const x: number = 1;\n"), false},
+		{"mpeg transport stream", "clip.ts", "utf-8", []byte{0x47, 0x40, 0x00, 0x10, 0x00, 0x00}, true},
+		{"png", "logo.png", "utf-8", png, true},
+		{"png without extension", "logo", "utf-8", png, true},
+		{"pdf signature", "doc.bin", "iso-8859-1", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"), true},
+		{"control bytes", "data.txt", "utf-8", []byte("abc\x01\x02\x03"), true},
+		{"svg", "icon.svg", "utf-8", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), false},
+		{"shift_jis text", "notes.txt", "shift_jis", []byte("\x93\xfa\x96\x7b\x8c\xea\n"), false},
+		{"utf-16 text", "notes.txt", "utf-16le", []byte("\xff\xfeh\x00i\x00"), false},
+		{"empty file", "empty.go", "utf-8", nil, false},
+		{"text-looking binary extension", "font.woff2", "utf-8", []byte("wOF2"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBinaryFile(tt.path, tt.charset, tt.content); got != tt.want {
+				t.Errorf("isBinaryFile(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeForSaveKeepsUTF16BOM(t *testing.T) {
+	tests := []struct {
+		name     string
+		original []byte
+		want     []byte
+	}{
+		{"le with bom", []byte("\xff\xfea\x00\r\x00\n\x00"), []byte("\xff\xfeb\x00\r\x00\n\x00")},
+		{"be with bom", []byte("\xfe\xff\x00a\x00\n"), []byte("\xfe\xff\x00b\x00\n")},
+		{"le without bom", []byte("a\x00b\x00\n\x00"), []byte("b\x00\n\x00")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.txt")
+			if err := os.WriteFile(path, tt.original, 0644); err != nil {
+				t.Fatal(err)
+			}
+			data, _, _, err := encodeForSave(path, "b\n", "", FileSettings{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, tt.want) {
+				t.Errorf("encodeForSave = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
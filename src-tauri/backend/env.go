@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Archivos de variables del workspace, en orden: los posteriores pisan a los anteriores
+var dotEnvFiles = []string{".env", ".env.local"}
+
+// Variables que hacen que la shell o el enlazador ejecuten código antes que el comando (BASH_ENV,
+// LD_PRELOAD, funciones exportadas...). No se aceptan del .env, de las peticiones ni de las sesiones.
+var loaderEnvPatterns = []string{"BASH_ENV", "ENV", "PROMPT_COMMAND", "SHELLOPTS", "BASHOPTS", "PS4", "BASH_FUNC_*", "LD_*", "DYLD_*"}
+
+func isLoaderEnv(name string) bool {
+	_, ok := matchCommandPatterns(loaderEnvPatterns, []string{strings.ToUpper(name)})
+	return ok
+}
+
+// isProtectedEnv indica si la variable no se puede fijar desde el .env ni desde una petición;
+// PATH tampoco, porque cambiaría qué programa ejecuta un comando que la política permitió
+func isProtectedEnv(name string) bool {
+	return isLoaderEnv(name) || strings.EqualFold(name, "PATH")
+}
+
+// checkEnvOverrides rechaza las variables de una petición o de una tarea que el comando no puede recibir
+func checkEnvOverrides(env map[string]string) error {
+	for name := range env {
+		if isProtectedEnv(name) {
+			return fmt.Errorf("environment variable %s cannot be set for a command", name)
+		}
+	}
+	return nil
+}
+
+// Tiempo máximo para leer el entorno de la shell de login del usuario
+const loginShellEnvTimeout = 5 * time.Second
+
+// envVar es una variable del entorno efectivo junto con la capa de la que salió
+type envVar struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // "process", "login-shell", ".env", ".env.local", "session", "toolchain" u "override"
+}
+
+// commandEnv es el entorno que recibe un comando; en Windows los nombres no distinguen mayúsculas
+type commandEnv struct {
+	vars map[string]envVar
+	// pathSources recuerda de qué capa salió cada directorio del PATH
+	pathSources map[string]string
+}
+
+func envKey(name string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToUpper(name)
+	}
+	return name
+}
+
+func (e *commandEnv) set(name, value, source string) {
+	e.vars[envKey(name)] = envVar{Name: name, Value: value, Source: source}
+}
+
+func (e *commandEnv) get(name string) string {
+	return e.vars[envKey(name)].Value
+}
+
+func (e *commandEnv) unset(name string) {
+	delete(e.vars, envKey(name))
+}
+
+// withoutWorkspaceTools quita del PATH los directorios de herramientas que están dentro del workspace
+// (node_modules/.bin, el virtualenv) y el VIRTUAL_ENV que apunta a ellos; se usa para los comandos de la IA
+func (e *commandEnv) withoutWorkspaceTools(root string) *commandEnv {
+	if root == "" {
+		return e
+	}
+	if venv := e.get("VIRTUAL_ENV"); venv != "" && isSubPath(root, venv) {
+		e.unset("VIRTUAL_ENV")
+	}
+	pathVar := e.vars[envKey("PATH")]
+	var kept []string
+	for _, dir := range filepath.SplitList(pathVar.Value) {
+		if e.pathSources[dir] == "toolchain" && isSubPath(root, dir) {
+			delete(e.pathSources, dir)
+			continue
+		}
+		kept = append(kept, dir)
+	}
+	if pathVar.Name != "" {
+		e.set(pathVar.Name, strings.Join(kept, string(os.PathListSeparator)), pathVar.Source)
+	}
+	return e
+}
+
+// list devuelve el entorno como KEY=VALUE ordenado, listo para exec.Cmd.Env
+func (e *commandEnv) list() []string {
+	res := make([]string, 0, len(e.vars))
+	for _, v := range e.vars {
+		res = append(res, v.Name+"="+v.Value)
+	}
+	sort.Strings(res)
+	return res
+}
+
+var (
+	loginEnvMu     sync.Mutex
+	loginEnvCache  map[string]string
+	loginEnvLoaded bool
+)
+
+// loginShellEnv lee una vez el entorno de la shell de login del usuario (~/.profile, ~/.bashrc, ~/.zshrc...),
+// que es el que ve en su terminal aunque el backend se haya lanzado desde el escritorio
+func loginShellEnv(refresh bool) map[string]string {
+	loginEnvMu.Lock()
+	defer loginEnvMu.Unlock()
+	if loginEnvLoaded && !refresh {
+		return loginEnvCache
+	}
+	loginEnvLoaded = true
+	loginEnvCache = nil
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/bash"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), loginShellEnvTimeout)
+	defer cancel()
+	// El marcador separa el entorno de lo que impriman los scripts de inicio
+	const marker = "__AIRIDE_ENV__"
+	cmd := exec.CommandContext(ctx, shell, "-l", "-i", "-c", "printf '\\n"+marker+"\\n'; env -0")
+	cmd.Stdin = nil
+	out, err := cmd.Output()
+	i := bytes.Index(out, []byte(marker+"\n"))
+	if i < 0 {
+		fmt.Printf("[BACK] Could not read login shell environment from %s: %v\n", shell, err)
+		return nil
+	}
+	env := map[string]string{}
+	for _, kv := range bytes.Split(out[i+len(marker)+1:], []byte{0}) {
+		if k, v, ok := strings.Cut(string(kv), "="); ok && k != "" {
+			env[k] = v
+		}
+	}
+	// Variables propias de la shell que no tienen sentido fuera de ella
+	for _, k := range []string{"PWD", "OLDPWD", "SHLVL", "_"} {
+		delete(env, k)
+	}
+	loginEnvCache = env
+	return env
+}
+
+// parseDotEnv lee un archivo .env: KEY=VALUE, "export KEY=VALUE", comentarios y comillas simples o dobles.
+// En valores sin comillas o con comillas dobles se expanden ${VAR} y $VAR con lookup.
+func parseDotEnv(path string, lookup func(string) string) ([][2]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var vars [][2]string
+	local := map[string]string{}
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			if v, ok := local[name]; ok {
+				return v
+			}
+			return lookup(name)
+		})
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, "'"):
+			// Comillas simples: literal hasta la comilla de cierre, sin expansión
+			if end := strings.Index(value[1:], "'"); end >= 0 {
+				value = value[1 : end+1]
+			}
+		case strings.HasPrefix(value, `"`):
+			end := 1
+			for end < len(value) && value[end] != '"' {
+				if value[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(value) {
+				value = value[1:end]
+			}
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value)
+			value = expand(value)
+		default:
+			// Comentario al final de un valor sin comillas
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+			value = expand(value)
+		}
+		local[key] = value
+		vars = append(vars, [2]string{key, value})
+	}
+	return vars, scanner.Err()
+}
+
+// toolchainPaths detecta directorios de herramientas que deben ir al principio del PATH:
+// el virtualenv de Python del proyecto, node_modules/.bin, la versión de Node de nvm, Go y Cargo.
+// Los dos primeros están dentro del workspace, donde la IA puede escribir: withoutWorkspaceTools los quita
+// de sus comandos para que no pueda esconder un "git" o un "npm" propio detrás de un comando permitido.
+func toolchainPaths(root string, env *commandEnv) []string {
+	var dirs []string
+	addIfDir := func(dir string) {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	home, _ := os.UserHomeDir()
+
+	// Virtualenv de Python dentro del proyecto
+	binDir, python := "bin", "python"
+	if runtime.GOOS == "windows" {
+		binDir, python = "Scripts", "python.exe"
+	}
+	for _, name := range []string{".venv", "venv", "env"} {
+		venv := filepath.Join(root, name)
+		if _, err := os.Stat(filepath.Join(venv, binDir, python)); err == nil {
+			dirs = append(dirs, filepath.Join(venv, binDir))
+			env.set("VIRTUAL_ENV", venv, "toolchain")
+			env.unset("PYTHONHOME")
+			break
+		}
+	}
+
+	// Binarios locales de npm
+	addIfDir(filepath.Join(root, "node_modules", ".bin"))
+
+	// Node de nvm: la versión de .nvmrc o, si no hay, el alias default
+	nvmDir := env.get("NVM_DIR")
+	if nvmDir == "" && home != "" {
+		nvmDir = filepath.Join(home, ".nvm")
+	}
+	if nvmDir != "" {
+		wanted := ""
+		if data, err := os.ReadFile(filepath.Join(root, ".nvmrc")); err == nil {
+			wanted = strings.TrimSpace(string(data))
+		} else if data, err := os.ReadFile(filepath.Join(nvmDir, "alias", "default")); err == nil {
+			wanted = strings.TrimSpace(string(data))
+		}
+		if version := resolveNvmVersion(nvmDir, wanted); version != "" {
+			addIfDir(filepath.Join(nvmDir, "versions", "node", version, "bin"))
+		}
+	}
+
+	// Go: binarios instalados con go install y la instalación estándar si no está en el PATH
+	if gopath := env.get("GOPATH"); gopath != "" {
+		addIfDir(filepath.Join(strings.Split(gopath, string(os.PathListSeparator))[0], "bin"))
+	} else if home != "" {
+		addIfDir(filepath.Join(home, "go", "bin"))
+	}
+	if runtime.GOOS == "windows" {
+		addIfDir(`C:\Program Files\Go\bin`)
+	} else {
+		addIfDir("/usr/local/go/bin")
+	}
+
+	// Rust
+	if home != "" {
+		addIfDir(filepath.Join(home, ".cargo", "bin"))
+	}
+	return dirs
+}
+
+// resolveNvmVersion busca la versión instalada más alta que coincide con lo pedido ("20", "v18.17", "lts/*"...)
+func resolveNvmVersion(nvmDir, wanted string) string {
+	entries, err := os.ReadDir(filepath.Join(nvmDir, "versions", "node"))
+	if err != nil {
+		return ""
+	}
+	wanted = strings.TrimPrefix(wanted, "v")
+	var best string
+	var bestVer []int
+	for _, e := range entries {
+		name := e.Name()
+		ver := strings.TrimPrefix(name, "v")
+		if wanted != "" && !strings.HasPrefix(wanted, "lts") && wanted != "node" && ver != wanted && !strings.HasPrefix(ver, wanted+".") {
+			continue
+		}
+		parsed := parseVersion(ver)
+		if best == "" || compareVersions(parsed, bestVer) > 0 {
+			best, bestVer = name, parsed
+		}
+	}
+	return best
+}
+
+// parseVersion convierte "1.22.3" (o "go1.22rc1", "v20.1.0") en sus números
+func parseVersion(s string) []int {
+	var res []int
+	n, digits := 0, false
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			n = n*10 + int(c-'0')
+			digits = true
+		case c == '.' && digits:
+			res = append(res, n)
+			n, digits = 0, false
+		default:
+			if digits {
+				res = append(res, n)
+				return res
+			}
+		}
+	}
+	if digits {
+		res = append(res, n)
+	}
+	return res
+}
+
+// compareVersions compara dos versiones numéricas; las partes que faltan cuentan como 0
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// systemFallbackPaths son directorios estándar que se agregan al final del PATH si existen y faltan
+func systemFallbackPaths() []string {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	dirs := []string{"/usr/local/bin", "/usr/bin", "/bin", "/usr/local/sbin", "/usr/sbin", "/sbin"}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".local", "bin"), filepath.Join(home, ".pyenv", "bin"))
+	}
+	return dirs
+}
+
+// buildCommandEnv arma el entorno de un comando en capas, de menor a mayor prioridad:
+// entorno del backend, shell de login del usuario, .env y .env.local del workspace y overrides de la petición.
+// Después antepone al PATH las herramientas detectadas en el proyecto y elimina directorios repetidos.
+func buildCommandEnv(root string, overrides map[string]string) *commandEnv {
+	return buildSessionEnv(root, nil, overrides)
+}
+
+// buildSessionEnv agrega entre el .env y los overrides lo que cambió una sesión de terminal;
+// la sesión puede cambiar PATH pero tampoco las variables de carga de código
+func buildSessionEnv(root string, session, overrides map[string]string) *commandEnv {
+	env := &commandEnv{vars: map[string]envVar{}, pathSources: map[string]string{}}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			env.set(k, v, "process")
+		}
+	}
+	for k, v := range loginShellEnv(false) {
+		env.set(k, v, "login-shell")
+	}
+	if root != "" {
+		for _, name := range dotEnvFiles {
+			vars, err := parseDotEnv(filepath.Join(root, name), env.get)
+			if err != nil {
+				continue
+			}
+			for _, kv := range vars {
+				if isProtectedEnv(kv[0]) {
+					fmt.Printf("[BACK] Ignoring %s from %s\n", kv[0], name)
+					continue
+				}
+				env.set(kv[0], kv[1], name)
+			}
+		}
+	}
+	for k, v := range session {
+		if !isLoaderEnv(k) {
+			env.set(k, v, "session")
+		}
+	}
+	for k, v := range overrides {
+		if isProtectedEnv(k) {
+			fmt.Printf("[BACK] Ignoring environment override %s\n", k)
+			continue
+		}
+		env.set(k, v, "override")
+	}
+
+	pathVar := env.vars[envKey("PATH")]
+	if pathVar.Name == "" {
+		pathVar.Name = "PATH"
+	}
+	var dirs []string
+	var sources []string
+	if root != "" {
+		for _, dir := range toolchainPaths(root, env) {
+			dirs = append(dirs, dir)
+			sources = append(sources, "toolchain")
+		}
+	}
+	for _, dir := range filepath.SplitList(pathVar.Value) {
+		dirs = append(dirs, dir)
+		sources = append(sources, pathVar.Source)
+	}
+	for _, dir := range systemFallbackPaths() {
+		if _, err := os.Stat(dir); err == nil {
+			dirs = append(dirs, dir)
+			sources = append(sources, "system")
+		}
+	}
+	// Quitar duplicados conservando la primera aparición, que es la que gana al buscar un ejecutable
+	seen := map[string]bool{}
+	var unique []string
+	for i, dir := range dirs {
+		key := filepath.Clean(dir)
+		if runtime.GOOS == "windows" {
+			key = strings.ToLower(key)
+		}
+		if dir == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, dir)
+		env.pathSources[dir] = sources[i]
+	}
+	env.set(pathVar.Name, strings.Join(unique, string(os.PathListSeparator)), pathVar.Source)
+	return env
+}
+
+// maskedEnvValue oculta el valor de las variables que parecen secretos (API keys, tokens, contraseñas...)
+func maskedEnvValue(name, value string) string {
+	if _, secret := matchCommandPatterns(defaultScrubEnvPatterns, []string{strings.ToUpper(name)}); secret {
+		return "********"
+	}
+	return value
+}
+
+// envHandler muestra el entorno efectivo con el que se ejecutan los comandos del workspace.
+// Los valores que parecen secretos se ocultan salvo con ?reveal=true desde la interfaz; ?refresh=true vuelve a leer
+// la shell de login.
+func envHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("refresh") == "true" {
+		loginShellEnv(true)
+	}
+	root := workspaceRoot(q.Get("projectBaseDir"))
+	env := buildCommandEnv(root, nil)
+	// Solo la interfaz puede ver los secretos: la IA no recibe esas variables en sus comandos
+	reveal := q.Get("reveal") == "true" && isUIRequest(r)
+	var vars []envVar
+	for _, v := range env.vars {
+		if !reveal {
+			v.Value = maskedEnvValue(v.Name, v.Value)
+		}
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	type pathEntry struct {
+		Dir    string `json:"dir"`
+		Source string `json:"source"`
+	}
+	var path []pathEntry
+	for _, dir := range filepath.SplitList(env.get("PATH")) {
+		path = append(path, pathEntry{Dir: dir, Source: env.pathSources[dir]})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "root": root, "variables": vars, "path": path})
+}
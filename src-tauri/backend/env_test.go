@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	content := `# comentario
+PLAIN=value
+export EXPORTED=1
+SPACED = padded  
+COMMENTED=abc # trailing comment
+HASH=a#b
+SINGLE='literal $PLAIN # not a comment'
+DOUBLE="line1\nline2 \"quoted\""
+EXPANDED=${PLAIN}-$HOME_DIR
+EXPANDED_QUOTED="$PLAIN/x"
+EMPTY=
+not a valid line
+BAD KEY=x
+`
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(name string) string {
+		if name == "HOME_DIR" {
+			return "/home/me"
+		}
+		return ""
+	}
+	vars, err := parseDotEnv(path, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{
+		{"PLAIN", "value"},
+		{"EXPORTED", "1"},
+		{"SPACED", "padded"},
+		{"COMMENTED", "abc"},
+		{"HASH", "a#b"},
+		{"SINGLE", "literal $PLAIN # not a comment"},
+		{"DOUBLE", "line1\nline2 \"quoted\""},
+		{"EXPANDED", "value-/home/me"},
+		{"EXPANDED_QUOTED", "value/x"},
+		{"EMPTY", ""},
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("parseDotEnv =\n%q\nwant\n%q", vars, want)
+	}
+}
+
+func TestCheckEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"NODE_ENV", true},
+		{"GOFLAGS", true},
+		{"LD_LIBRARY_PATH_HINT", false},
+		{"BASH_ENV", false},
+		{"ENV", false},
+		{"ENVIRONMENT", true},
+		{"LD_PRELOAD", false},
+		{"ld_preload", false},
+		{"DYLD_INSERT_LIBRARIES", false},
+		{"PROMPT_COMMAND", false},
+		{"SHELLOPTS", false},
+		{"BASH_FUNC_ls%%", false},
+		{"PATH", false},
+		{"Path", false},
+	}
+	for _, tt := range tests {
+		err := checkEnvOverrides(map[string]string{tt.name: "x"})
+		if (err == nil) != tt.ok {
+			t.Errorf("checkEnvOverrides(%s) error = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestBuildCommandEnvIgnoresLoaderVariables(t *testing.T) {
+	root := t.TempDir()
+	dotEnv := "BASH_ENV=./x.sh\nLD_PRELOAD=./x.so\nPATH=./bin\nAPP_MODE=dev\n"
+	if err := os.WriteFile(filepath.Join(root, ".env"), []byte(dotEnv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := buildSessionEnv(root, map[string]string{"PROMPT_COMMAND": "./x.sh", "SESSION_VAR": "1"}, map[string]string{"BASH_ENV": "./y.sh", "NODE_ENV": "test"})
+	for _, name := range []string{"BASH_ENV", "LD_PRELOAD", "PROMPT_COMMAND"} {
+		if v := env.get(name); v != os.Getenv(name) {
+			t.Errorf("%s = %q, want it ignored", name, v)
+		}
+	}
+	if env.get("APP_MODE") != "dev" || env.get("NODE_ENV") != "test" || env.get("SESSION_VAR") != "1" {
+		t.Errorf("regular variables missing: APP_MODE=%q NODE_ENV=%q SESSION_VAR=%q", env.get("APP_MODE"), env.get("NODE_ENV"), env.get("SESSION_VAR"))
+	}
+	for _, dir := range filepath.SplitList(env.get("PATH")) {
+		if dir == "./bin" {
+			t.Error("PATH from .env was applied")
+		}
+	}
+}
+
+func TestWithoutWorkspaceTools(t *testing.T) {
+	root := t.TempDir()
+	nodeBin := filepath.Join(root, "node_modules", ".bin")
+	if err := os.MkdirAll(nodeBin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	env := buildCommandEnv(root, nil)
+	if dirs := filepath.SplitList(env.get("PATH")); len(dirs) == 0 || dirs[0] != nodeBin {
+		t.Fatalf("PATH = %q, want node_modules/.bin first", env.get("PATH"))
+	}
+	env.withoutWorkspaceTools(root)
+	for _, dir := range filepath.SplitList(env.get("PATH")) {
+		if isSubPath(root, dir) {
+			t.Errorf("workspace directory %q still in PATH", dir)
+		}
+	}
+	if env.get("PATH") == "" {
+		t.Error("PATH emptied")
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Final de stdout y stderr que se envía al modelo; los errores suelen estar al final
+	explainOutputTail = 8 * 1024
+	// Archivos citados en la salida que se adjuntan como contexto, y cuánto de cada uno
+	maxExplainFiles      = 5
+	maxExplainFileSize   = 256 * 1024
+	explainContextLines  = 20
+	explainFileHeadLines = 80
+)
+
+// Archivos que nunca se envían al modelo aunque aparezcan en la salida
+var explainSecretFilePattern = regexp.MustCompile(`(?i)(^|/)(\.env(\..*)?|id_rsa.*|id_ed25519.*|.*\.pem|.*\.key|.*\.p12|\.npmrc|\.netrc)$`)
+
+// Asignaciones NOMBRE=valor (o --nombre=valor) en la salida, para ocultar el valor si el nombre parece un secreto
+var secretAssignmentPattern = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_-]*)(\s*=\s*)("[^"\n]*"|'[^'\n]*'|[^\s"']+)`)
+
+// Los valores más cortos no se buscan en la salida: ocultarían texto cualquiera
+const minMaskedSecretLength = 6
+
+var fencedBlockPattern = regexp.MustCompile("(?s)```([A-Za-z0-9_-]*)[^\\n]*\\n(.*?)```")
+
+// ExplainRequest es una ejecución fallida tal como la devuelve /terminal (output, error, exitCode)
+// junto con el proveedor y modelo configurados en el chat
+type ExplainRequest struct {
+	Command        string   `json:"command"`
+	ExitCode       int      `json:"exitCode"`
+	Output         string   `json:"output,omitempty"`
+	Error          string   `json:"error,omitempty"`
+	WorkingDir     string   `json:"workingDir,omitempty"`
+	ProjectBaseDir string   `json:"projectBaseDir,omitempty"`
+	Files          []string `json:"files,omitempty"` // archivos extra a adjuntar
+	Provider       string   `json:"provider"`
+	Model          string   `json:"model,omitempty"`
+	ApiKey         string   `json:"api_key,omitempty"`
+}
+
+type ExplainResponse struct {
+	Success          bool         `json:"success"`
+	Message          string       `json:"message,omitempty"`
+	Explanation      string       `json:"explanation,omitempty"`
+	SuggestedDiff    string       `json:"suggestedDiff,omitempty"`
+	SuggestedCommand string       `json:"suggestedCommand,omitempty"`
+	Response         string       `json:"response,omitempty"` // respuesta completa del modelo
+	Files            []string     `json:"files,omitempty"`    // archivos enviados como contexto
+	Problems         []Diagnostic `json:"problems,omitempty"`
+}
+
+// tailText se queda con los últimos n bytes de s sin cortar un carácter UTF-8
+func tailText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[len(s)-n:]
+	for len(s) > 0 && !utf8.RuneStart(s[0]) {
+		s = s[1:]
+	}
+	return "...\n" + s
+}
+
+// maskSecrets oculta en el texto los valores de las variables del entorno del workspace que parecen secretos
+// (los mismos patrones que scrubEnv) y las asignaciones a nombres de ese tipo que aparezcan en la salida
+func maskSecrets(text string, env []string, policy CommandPolicySettings) string {
+	patterns := append(append([]string{}, defaultScrubEnvPatterns...), policy.ScrubEnv...)
+	isSecret := func(name string) bool {
+		_, secret := matchCommandPatterns(patterns, []string{strings.ToUpper(strings.ReplaceAll(name, "-", "_"))})
+		return secret
+	}
+	var values []string
+	for _, kv := range env {
+		name, value, ok := strings.Cut(kv, "=")
+		if ok && len(value) >= minMaskedSecretLength && isSecret(name) {
+			values = append(values, value)
+		}
+	}
+	// Primero los más largos, por si un secreto contiene a otro
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		text = strings.ReplaceAll(text, v, "********")
+	}
+	return secretAssignmentPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := secretAssignmentPattern.FindStringSubmatch(m)
+		if !isSecret(parts[1]) || parts[3] == "********" {
+			return m
+		}
+		return parts[1] + parts[2] + "********"
+	})
+}
+
+// explainFileRefs reúne los archivos citados en la salida (por los problem matchers y todos los frames de
+// un traceback de Python) con las líneas mencionadas, más los que pida el cliente
+func explainFileRefs(root, cwd string, req ExplainRequest, diags []Diagnostic) ([]string, map[string][]int) {
+	var order []string
+	lines := map[string][]int{}
+	add := func(file string, line int) {
+		abs := file
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(root, abs)
+		}
+		abs = filepath.Clean(abs)
+		if !isSubPath(root, abs) || explainSecretFilePattern.MatchString(filepath.ToSlash(abs)) {
+			return
+		}
+		if _, ok := lines[abs]; !ok {
+			order = append(order, abs)
+			lines[abs] = nil
+		}
+		if line > 0 {
+			lines[abs] = append(lines[abs], line)
+		}
+	}
+	for _, f := range req.Files {
+		add(resolveWorkspacePath(f, root), 0)
+	}
+	for _, d := range diags {
+		add(d.File, d.Line)
+	}
+	ctx := problemContext{root: root, cwd: cwd}
+	for _, line := range strings.Split(req.Output+"\n"+req.Error, "\n") {
+		if m := pythonFramePattern.FindStringSubmatch(line); m != nil {
+			add(ctx.file(m[1]), atoi(m[2]))
+		}
+	}
+	if len(order) > maxExplainFiles {
+		order = order[:maxExplainFiles]
+	}
+	return order, lines
+}
+
+// explainFileExcerpt devuelve las líneas numeradas alrededor de las citadas, o el principio del archivo
+func explainFileExcerpt(path string, refs []int) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() > maxExplainFileSize {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || isBinaryContent(data) {
+		return "", false
+	}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	show := make([]bool, len(lines))
+	if len(refs) == 0 {
+		for i := 0; i < len(lines) && i < explainFileHeadLines; i++ {
+			show[i] = true
+		}
+	}
+	for _, ref := range refs {
+		for i := ref - 1 - explainContextLines; i <= ref-1+explainContextLines; i++ {
+			if i >= 0 && i < len(lines) {
+				show[i] = true
+			}
+		}
+	}
+	var b strings.Builder
+	prev := -1
+	for i, ok := range show {
+		if !ok {
+			continue
+		}
+		if prev >= 0 && i != prev+1 {
+			b.WriteString("...\n")
+		}
+		fmt.Fprintf(&b, "%5d| %s\n", i+1, lines[i])
+		prev = i
+	}
+	return b.String(), true
+}
+
+func buildExplainPrompt(req ExplainRequest, root, cwd string, files []string, refs map[string][]int) (string, []string) {
+	var b strings.Builder
+	b.WriteString("A command failed in the user's project. Explain the cause and how to fix it.\n\n")
+	fmt.Fprintf(&b, "Command: %s\n", req.Command)
+	if rel, err := filepath.Rel(root, cwd); err == nil && isSubPath(root, cwd) {
+		fmt.Fprintf(&b, "Working directory (relative to the project root): %s\n", filepath.ToSlash(rel))
+	}
+	fmt.Fprintf(&b, "Exit code: %d\nOperating system: %s\n\n", req.ExitCode, runtime.GOOS)
+	if strings.TrimSpace(req.Output) != "" {
+		fmt.Fprintf(&b, "Output (stdout):\n```\n%s\n```\n\n", tailText(req.Output, explainOutputTail))
+	}
+	if strings.TrimSpace(req.Error) != "" {
+		fmt.Fprintf(&b, "Errors (stderr):\n```\n%s\n```\n\n", tailText(req.Error, explainOutputTail))
+	}
+	var sent []string
+	for _, path := range files {
+		sort.Ints(refs[path])
+		excerpt, ok := explainFileExcerpt(path, refs[path])
+		if !ok {
+			continue
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		sent = append(sent, rel)
+		fmt.Fprintf(&b, "File %s:\n```\n%s```\n\n", rel, excerpt)
+	}
+	b.WriteString("Answer with a short explanation of the cause, then the fix. ")
+	b.WriteString("If files must change, include a unified diff in a ```diff block with paths relative to the project root (--- a/path, +++ b/path). ")
+	b.WriteString("If a command must be run, include it in a ```sh block. Omit whichever does not apply.\n")
+	return b.String(), sent
+}
+
+// parseExplanation separa de la respuesta del modelo el primer bloque diff y el primer bloque de comandos
+func parseExplanation(response string) (explanation, diff, command string) {
+	explanation = response
+	for _, m := range fencedBlockPattern.FindAllStringSubmatch(response, -1) {
+		lang, body := strings.ToLower(m[1]), strings.TrimRight(m[2], "\n")
+		switch {
+		case diff == "" && (lang == "diff" || lang == "patch" || (lang == "" && strings.HasPrefix(body, "--- "))):
+			diff = body + "\n"
+		case command == "" && (lang == "sh" || lang == "bash" || lang == "shell" || lang == "console" || lang == "powershell" || lang == "ps1" || lang == "cmd"):
+			command = strings.TrimSpace(body)
+		default:
+			continue
+		}
+		explanation = strings.Replace(explanation, m[0], "", 1)
+	}
+	return strings.TrimSpace(explanation), diff, command
+}
+
+// explainHandler pide al modelo configurado un diagnóstico de un comando fallido con los archivos que cita su salida
+func explainHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ExplainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Command) == "" {
+		http.Error(w, "No command provided", http.StatusBadRequest)
+		return
+	}
+	root := workspaceRoot(req.ProjectBaseDir)
+	cwd := root
+	if req.WorkingDir != "" {
+		cwd = resolveWorkspacePath(req.WorkingDir, req.ProjectBaseDir)
+	}
+	// La salida puede traer claves impresas por el comando (env, set -x, mensajes de error)
+	env := buildCommandEnv(root, nil).list()
+	policy := loadWorkspaceSettings(root).Commands
+	req.Command = maskSecrets(req.Command, env, policy)
+	req.Output = maskSecrets(req.Output, env, policy)
+	req.Error = maskSecrets(req.Error, env, policy)
+	diags, _ := matchProblems(root, cwd, req.Command, req.Output+"\n"+req.Error, true)
+	files, refs := explainFileRefs(root, cwd, req, diags)
+	prompt, sent := buildExplainPrompt(req, root, cwd, files, refs)
+	fmt.Printf("[BACK] Explaining failed command '%s' with %s (%d files)\n", req.Command, req.Provider, len(sent))
+
+	response, status, err := chatCompletion(req.Provider, req.ApiKey, req.Model, prompt)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	explanation, diff, command := parseExplanation(response)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ExplainResponse{
+		Success:          true,
+		Explanation:      explanation,
+		SuggestedDiff:    diff,
+		SuggestedCommand: command,
+		Response:         response,
+		Files:            sent,
+		Problems:         diags,
+	})
+}
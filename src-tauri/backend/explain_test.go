@@ -0,0 +1,140 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseExplanation(t *testing.T) {
+	tests := []struct {
+		name                  string
+		response              string
+		wantExplanation       string
+		wantDiff, wantCommand string
+	}{
+		{
+			name:            "diff and command",
+			response:        "The import is missing.\n\n```diff\n--- a/main.go\n+++ b/main.go\n@@ -1 +1,2 @@\n package main\n+import \"fmt\"\n```\n\nThen run:\n```sh\ngo build ./...\n```\n",
+			wantExplanation: "The import is missing.\n\n\n\nThen run:",
+			wantDiff:        "--- a/main.go\n+++ b/main.go\n@@ -1 +1,2 @@\n package main\n+import \"fmt\"\n",
+			wantCommand:     "go build ./...",
+		},
+		{
+			name:            "unlabelled diff",
+			response:        "Fix:\n```\n--- a/x.txt\n+++ b/x.txt\n```",
+			wantExplanation: "Fix:",
+			wantDiff:        "--- a/x.txt\n+++ b/x.txt\n",
+		},
+		{
+			name:            "other blocks stay in the explanation",
+			response:        "Output was:\n```text\npanic: boom\n```\nRun it with:\n```powershell\nnpm ci\n```\n```bash\nnpm test\n```",
+			wantExplanation: "Output was:\n```text\npanic: boom\n```\nRun it with:\n\n```bash\nnpm test\n```",
+			wantCommand:     "npm ci",
+		},
+		{
+			name:            "plain text",
+			response:        "  Nothing to fix.  ",
+			wantExplanation: "Nothing to fix.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation, diff, command := parseExplanation(tt.response)
+			if explanation != tt.wantExplanation {
+				t.Errorf("explanation = %q, want %q", explanation, tt.wantExplanation)
+			}
+			if diff != tt.wantDiff {
+				t.Errorf("diff = %q, want %q", diff, tt.wantDiff)
+			}
+			if command != tt.wantCommand {
+				t.Errorf("command = %q, want %q", command, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestExplainFileRefs(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	req := ExplainRequest{
+		Files: []string{"src/extra.go", ".env", filepath.Join(outside, "notes.txt")},
+		Error: strings.Join([]string{
+			"Traceback (most recent call last):",
+			`  File "app/main.py", line 10, in <module>`,
+			`  File "app/util.py", line 3, in helper`,
+			`  File "` + filepath.Join(outside, "lib.py") + `", line 7, in f`,
+			`  File "../escape.py", line 1, in g`,
+			"ValueError: boom",
+		}, "\n"),
+	}
+	diags := []Diagnostic{
+		{File: "app/main.py", Line: 12},
+		{File: "certs/server.pem", Line: 1},
+		{File: "config/id_rsa", Line: 1},
+	}
+	files, lines := explainFileRefs(root, root, req, diags)
+	abs := func(rel string) string { return filepath.Join(root, filepath.FromSlash(rel)) }
+	want := []string{abs("src/extra.go"), abs("app/main.py"), abs("app/util.py")}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("files = %q, want %q", files, want)
+	}
+	if got := lines[abs("app/main.py")]; !reflect.DeepEqual(got, []int{12, 10}) {
+		t.Errorf("app/main.py lines = %v", got)
+	}
+	if got := lines[abs("src/extra.go")]; len(got) != 0 {
+		t.Errorf("requested file lines = %v", got)
+	}
+
+	// Como mucho maxExplainFiles
+	req = ExplainRequest{}
+	for i := 0; i < maxExplainFiles+3; i++ {
+		req.Files = append(req.Files, filepath.Join("src", string(rune('a'+i))+".go"))
+	}
+	if files, _ := explainFileRefs(root, root, req, nil); len(files) != maxExplainFiles {
+		t.Errorf("%d files, want %d", len(files), maxExplainFiles)
+	}
+}
+
+func TestTailText(t *testing.T) {
+	if got := tailText("short", 10); got != "short" {
+		t.Errorf("tailText(short) = %q", got)
+	}
+	if got := tailText("0123456789", 4); got != "...\n6789" {
+		t.Errorf("tailText = %q", got)
+	}
+	// El corte cae en medio de "é" (2 bytes): se descarta el byte suelto
+	got := tailText("café con leche", 11)
+	if got != "...\n con leche" || !utf8.ValidString(got) {
+		t.Errorf("tailText = %q", got)
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	env := []string{
+		"OPENAI_API_KEY=sk-abcdef123456",
+		"GITHUB_TOKEN=ghp_secretvalue",
+		"DB_PASSWORD=abc", // demasiado corto para buscarlo en la salida
+		"HOME=/home/dev",
+		"INTERNAL_URL=https://intranet.local/x",
+	}
+	policy := CommandPolicySettings{ScrubEnv: []string{"INTERNAL_URL"}}
+	tests := []struct {
+		in, want string
+	}{
+		{"401 Unauthorized: Bearer sk-abcdef123456", "401 Unauthorized: Bearer ********"},
+		{"cloning with ghp_secretvalue@github.com", "cloning with ********@github.com"},
+		{"+ export DB_PASSWORD=abc", "+ export DB_PASSWORD=********"},
+		{`--api-key="xyz 123" --verbose`, "--api-key=******** --verbose"},
+		{"fetch https://intranet.local/x failed", "fetch ******** failed"},
+		{"HOME=/home/dev PORT=3000", "HOME=/home/dev PORT=3000"},
+		{"token expired at main.go:3:2", "token expired at main.go:3:2"},
+	}
+	for _, tt := range tests {
+		if got := maskSecrets(tt.in, env, policy); got != tt.want {
+			t.Errorf("maskSecrets(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
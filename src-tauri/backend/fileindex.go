@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+const (
+	// Cada cuánto se vuelve a escanear un índice que sigue en uso
+	fileIndexRescanInterval = 30 * time.Second
+	// Un índice sin consultas durante este tiempo se descarta
+	fileIndexIdleTimeout = 10 * time.Minute
+	maxIndexedFiles      = 200000
+	defaultQuickOpenMax  = 50
+)
+
+// fileIndex mantiene en memoria las rutas de archivos de un workspace
+type fileIndex struct {
+	root     string
+	mu       sync.RWMutex
+	paths    []string // rutas relativas con '/'
+	lower    []string // las mismas rutas en minúsculas (con foldCase), para el filtrado rápido
+	builtAt  time.Time
+	lastUsed time.Time
+	dirty    bool
+	once     sync.Once
+}
+
+var (
+	fileIndexesMu sync.Mutex
+	fileIndexes   = map[string]*fileIndex{}
+)
+
+// getFileIndex devuelve el índice del workspace, creándolo y escaneándolo la primera vez
+func getFileIndex(root string) *fileIndex {
+	fileIndexesMu.Lock()
+	idx, ok := fileIndexes[root]
+	if !ok {
+		idx = &fileIndex{root: root}
+		fileIndexes[root] = idx
+		go idx.refreshLoop()
+	}
+	fileIndexesMu.Unlock()
+	idx.once.Do(idx.rebuild)
+	idx.mu.Lock()
+	idx.lastUsed = time.Now()
+	needsRebuild := idx.dirty
+	idx.mu.Unlock()
+	if needsRebuild {
+		idx.rebuild()
+	}
+	return idx
+}
+
+// invalidateFileIndex marca como desactualizados los índices que contienen la ruta dada
+func invalidateFileIndex(path string) {
+	fileIndexesMu.Lock()
+	defer fileIndexesMu.Unlock()
+	for root, idx := range fileIndexes {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			idx.mu.Lock()
+			idx.dirty = true
+			idx.mu.Unlock()
+		}
+	}
+}
+
+// rebuild recorre el workspace y reemplaza la lista de rutas
+func (idx *fileIndex) rebuild() {
+	start := time.Now()
+	var paths []string
+	walkWorkspaceFiles(idx.root, walkOptions{}, func(absPath, relPath string, info fs.FileInfo) error {
+		paths = append(paths, relPath)
+		if len(paths) >= maxIndexedFiles {
+			return errStopWalk
+		}
+		return nil
+	})
+	lower := make([]string, len(paths))
+	for i, p := range paths {
+		lower[i] = foldCase(p)
+	}
+	idx.mu.Lock()
+	idx.paths = paths
+	idx.lower = lower
+	idx.builtAt = time.Now()
+	idx.dirty = false
+	idx.mu.Unlock()
+	fmt.Printf("[BACK] File index for %s built: %d files in %v\n", idx.root, len(paths), time.Since(start))
+}
+
+// refreshLoop reescanea periódicamente el workspace mientras el índice se siga usando
+func (idx *fileIndex) refreshLoop() {
+	ticker := time.NewTicker(fileIndexRescanInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		idx.mu.RLock()
+		idle := time.Since(idx.lastUsed) > fileIndexIdleTimeout
+		idx.mu.RUnlock()
+		if idle {
+			fileIndexesMu.Lock()
+			delete(fileIndexes, idx.root)
+			fileIndexesMu.Unlock()
+			fmt.Printf("[BACK] File index for %s dropped after inactivity\n", idx.root)
+			return
+		}
+		idx.rebuild()
+	}
+}
+
+type QuickOpenMatch struct {
+	Path      string `json:"path"`
+	Score     int    `json:"score"`
+	Positions []int  `json:"positions"` // en unidades UTF-16
+}
+
+type QuickOpenResponse struct {
+	Success    bool             `json:"success"`
+	Message    string           `json:"message,omitempty"`
+	Matches    []QuickOpenMatch `json:"matches"`
+	TotalFiles int              `json:"totalFiles"`
+	IndexedAt  time.Time        `json:"indexedAt"`
+}
+
+// Puntuación al estilo fzf
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+	bonusSegmentStart = 10 // después de '/'
+	bonusBoundary     = 8  // después de '_', '-', '.', ' ' o en un cambio camelCase
+	bonusConsecutive  = 5
+	bonusFirstChar    = 2
+	bonusBasename     = 20 // toda la coincidencia cae en el nombre del archivo
+)
+
+// foldCase pasa a minúsculas carácter a carácter, sin cambiar el número de runas
+// (strings.ToLower puede cambiar la longitud en bytes, p. ej. 'Ⱥ' ocupa 2 bytes y 'ⱥ' 3)
+func foldCase(s string) string {
+	return strings.Map(unicode.ToLower, s)
+}
+
+// isSubsequence indica si todos los bytes de query aparecen en orden en s; sirve de filtro rápido
+func isSubsequence(s, query string) bool {
+	qi := 0
+	for i := 0; i < len(s) && qi < len(query); i++ {
+		if s[i] == query[qi] {
+			qi++
+		}
+	}
+	return qi == len(query)
+}
+
+// charBonus calcula el bonus de coincidir en la posición i según el carácter previo
+func charBonus(path []rune, i int) int {
+	if i == 0 {
+		return bonusSegmentStart
+	}
+	prev, cur := path[i-1], path[i]
+	switch {
+	case prev == '/':
+		return bonusSegmentStart
+	case prev == '_' || prev == '-' || prev == '.' || prev == ' ':
+		return bonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return bonusBoundary
+	case !unicode.IsDigit(prev) && unicode.IsDigit(cur):
+		return bonusBoundary
+	}
+	return 0
+}
+
+// fuzzyMatch busca la consulta (ya en minúsculas) como subsecuencia de la ruta (algoritmo v1 de fzf:
+// avance y retroceso) y devuelve la puntuación y las posiciones coincidentes en unidades UTF-16,
+// como los índices de las cadenas de JavaScript; ok es false si no hay coincidencia
+func fuzzyMatch(path string, query []rune) (score int, positions []int, ok bool) {
+	if len(query) == 0 {
+		return 0, nil, true
+	}
+	runes := []rune(path)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	// Avance: encontrar el primer final posible de la subsecuencia
+	qi := 0
+	end := -1
+	for i := 0; i < len(lower); i++ {
+		if lower[i] == query[qi] {
+			qi++
+			if qi == len(query) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	// Retroceso: acortar la ventana buscando el inicio más cercano al final
+	qi = len(query) - 1
+	start := end
+	for i := end; i >= 0; i-- {
+		if lower[i] == query[qi] {
+			qi--
+			if qi < 0 {
+				start = i
+				break
+			}
+		}
+	}
+	// Desplazamiento UTF-16 de cada runa
+	offsets := make([]int, len(runes))
+	units := 0
+	for i, r := range runes {
+		offsets[i] = units
+		units += utf16.RuneLen(r)
+	}
+	// Puntuar la ventana [start, end]
+	positions = make([]int, 0, len(query))
+	qi = 0
+	inGap := false
+	consecutive := 0
+	for i := start; i <= end && qi < len(query); i++ {
+		if lower[i] == query[qi] {
+			s := scoreMatch
+			bonus := charBonus(runes, i)
+			if consecutive > 0 {
+				s += bonusConsecutive
+			}
+			if qi == 0 {
+				bonus *= bonusFirstChar
+			}
+			score += s + bonus
+			positions = append(positions, offsets[i])
+			consecutive++
+			inGap = false
+			qi++
+		} else {
+			if inGap {
+				score += scoreGapExtension
+			} else {
+				score += scoreGapStart
+			}
+			inGap = true
+			consecutive = 0
+		}
+	}
+	lastSlash := -1
+	for i, r := range runes {
+		if r == '/' {
+			lastSlash = i
+		}
+	}
+	if lastSlash < start {
+		score += bonusBasename
+	}
+	return score, positions, true
+}
+
+// search devuelve las mejores coincidencias de la consulta, ordenadas por puntuación
+func (idx *fileIndex) search(query string, limit int) []QuickOpenMatch {
+	query = foldCase(strings.ReplaceAll(strings.TrimSpace(query), "\\", "/"))
+	query = strings.ReplaceAll(query, " ", "")
+	queryRunes := []rune(query)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var matches []QuickOpenMatch
+	for i, p := range idx.paths {
+		if !isSubsequence(idx.lower[i], query) {
+			continue
+		}
+		score, positions, ok := fuzzyMatch(p, queryRunes)
+		if !ok {
+			continue
+		}
+		matches = append(matches, QuickOpenMatch{Path: p, Score: score, Positions: positions})
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].Score != matches[b].Score {
+			return matches[a].Score > matches[b].Score
+		}
+		// A igual puntuación, preferir rutas más cortas
+		return len(matches[a].Path) < len(matches[b].Path)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+func quickOpenHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query().Get("q")
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultQuickOpenMax
+	}
+	root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+	start := time.Now()
+	idx := getFileIndex(root)
+	matches := idx.search(query, limit)
+	idx.mu.RLock()
+	resp := QuickOpenResponse{
+		Success:    true,
+		Matches:    matches,
+		TotalFiles: len(idx.paths),
+		IndexedAt:  idx.builtAt,
+	}
+	idx.mu.RUnlock()
+	fmt.Printf("[BACK] Quick open %q: %d matches in %v\n", query, len(matches), time.Since(start))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
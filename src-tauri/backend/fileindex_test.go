@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		query     string
+		ok        bool
+		positions []int
+	}{
+		{"empty query", "src/main.go", "", true, nil},
+		{"no match", "src/main.go", "xyz", false, nil},
+		{"subsequence", "src/main.go", "mgo", true, []int{4, 9, 10}},
+		{"case insensitive", "src/MainView.tsx", "mv", true, []int{4, 8}},
+		// 'Ⱥ' ocupa 2 bytes y su minúscula 3; antes provocaba un índice fuera de rango
+		{"case folding changes byte length", "dir/Ⱥa", "a", true, []int{5}},
+		{"non-ASCII query", "docs/Ⱥccents.md", "ⱥc", true, []int{5, 6}},
+		{"accents", "lib/café/menú.go", "menú", true, []int{9, 10, 11, 12}},
+		// Los caracteres fuera del BMP ocupan dos unidades UTF-16
+		{"astral characters", "😀/a.go", "a", true, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, positions, ok := fuzzyMatch(tt.path, []rune(foldCase(tt.query)))
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !reflect.DeepEqual(positions, tt.positions) {
+				t.Errorf("positions = %v, want %v", positions, tt.positions)
+			}
+		})
+	}
+}
+
+func TestFuzzyMatchRanking(t *testing.T) {
+	tests := []struct {
+		query, better, worse string
+	}{
+		{"main", "src/main.go", "src/domain/index.go"},
+		{"fi", "src/fileIndex.go", "src/profile.go"},
+		{"mv", "src/MainView.tsx", "src/mover.tsx"},
+		{"ab", "x/ab.go", "ab/x.go"},
+	}
+	for _, tt := range tests {
+		q := []rune(foldCase(tt.query))
+		better, _, ok1 := fuzzyMatch(tt.better, q)
+		worse, _, ok2 := fuzzyMatch(tt.worse, q)
+		if !ok1 || !ok2 {
+			t.Fatalf("%q: expected both paths to match", tt.query)
+		}
+		if better <= worse {
+			t.Errorf("%q: score(%s) = %d, want more than score(%s) = %d", tt.query, tt.better, better, tt.worse, worse)
+		}
+	}
+}
+
+func TestFileIndexSearch(t *testing.T) {
+	paths := []string{"dir/Ⱥa", "src/main.go", "README.md"}
+	idx := &fileIndex{paths: paths, lower: make([]string, len(paths))}
+	for i, p := range paths {
+		idx.lower[i] = foldCase(p)
+	}
+	matches := idx.search("A", 10)
+	if len(matches) != 3 {
+		t.Fatalf("got %d matches, want 3: %+v", len(matches), matches)
+	}
+	if matches := idx.search("ⱥ", 10); len(matches) != 1 || matches[0].Path != "dir/Ⱥa" {
+		t.Errorf("search(ⱥ) = %+v", matches)
+	}
+}
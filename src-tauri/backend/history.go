@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// El historial local guarda cada versión guardada de un archivo en .airide/history:
+// objects/<xx>/<hash> contiene el contenido comprimido (una sola copia por hash) e
+// index/<id>.json la lista de revisiones de cada archivo.
+const (
+	historyDirName          = "history"
+	defaultHistoryMaxAge    = 30 // días
+	defaultHistoryMaxSizeMB = 200
+	historyPruneInterval    = 10 * time.Minute
+	// Archivos más grandes que esto no se guardan en el historial
+	maxHistoryFileSize = 10 * 1024 * 1024
+)
+
+// HistorySettings controla la poda del historial local (en .airide/settings.json)
+type HistorySettings struct {
+	Enabled    *bool `json:"enabled,omitempty"`
+	MaxAgeDays int   `json:"maxAgeDays,omitempty"`
+	MaxSizeMB  int   `json:"maxSizeMB,omitempty"`
+}
+
+type HistoryRevision struct {
+	ID     string    `json:"id"` // hash del contenido
+	Time   time.Time `json:"time"`
+	Size   int64     `json:"size"`
+	Source string    `json:"source,omitempty"` // "save", "external", "restore", "replace"
+}
+
+// historyIndex es la lista de revisiones de un archivo, de la más vieja a la más nueva
+type historyIndex struct {
+	Path      string            `json:"path"`
+	Revisions []HistoryRevision `json:"revisions"`
+}
+
+type HistoryRequest struct {
+	Operation      string `json:"operation"` // "diff" o "restore"
+	Path           string `json:"path"`
+	From           string `json:"from,omitempty"` // ID de revisión o "current"
+	To             string `json:"to,omitempty"`
+	Revision       string `json:"revision,omitempty"`
+	ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+}
+
+type HistoryResponse struct {
+	Success   bool              `json:"success"`
+	Message   string            `json:"message"`
+	Revisions []HistoryRevision `json:"revisions,omitempty"`
+	Diff      string            `json:"diff,omitempty"`
+	Version   string            `json:"version,omitempty"`
+}
+
+var (
+	historyMu        sync.Mutex
+	historyLastPrune = map[string]time.Time{}
+)
+
+func historyDir(root string) string {
+	return filepath.Join(root, workspaceConfigDir, historyDirName)
+}
+
+// historyIndexPath devuelve el índice de revisiones de un archivo, identificado por su ruta relativa al workspace
+func historyIndexPath(root, relPath string) string {
+	sum := sha256.Sum256([]byte(filepath.ToSlash(relPath)))
+	return filepath.Join(historyDir(root), "index", hex.EncodeToString(sum[:12])+".json")
+}
+
+func historyObjectPath(root, id string) string {
+	return filepath.Join(historyDir(root), "objects", id[:2], id)
+}
+
+func historyEnabled(settings HistorySettings) bool {
+	return settings.Enabled == nil || *settings.Enabled
+}
+
+func loadHistoryIndex(root, relPath string) historyIndex {
+	idx := historyIndex{Path: filepath.ToSlash(relPath)}
+	data, err := os.ReadFile(historyIndexPath(root, relPath))
+	if err == nil {
+		json.Unmarshal(data, &idx)
+	}
+	return idx
+}
+
+func saveHistoryIndex(root string, idx historyIndex) error {
+	path := historyIndexPath(root, idx.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(path, data, 0644)
+}
+
+// storeHistoryObject guarda el contenido comprimido si todavía no existe un objeto con ese hash
+func storeHistoryObject(root string, content []byte) (string, error) {
+	id := contentHash(content)
+	path := historyObjectPath(root, id)
+	if _, err := os.Stat(path); err == nil {
+		return id, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(content)
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return id, atomicWriteFile(path, buf.Bytes(), 0644)
+}
+
+// validHistoryID indica si id es un SHA-256 en hexadecimal en minúsculas, como los que genera contentHash;
+// cualquier otra cosa (por ejemplo "../") no puede usarse para armar la ruta del objeto
+func validHistoryID(id string) bool {
+	if len(id) != 64 || strings.ToLower(id) != id {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func loadHistoryObject(root, id string) ([]byte, error) {
+	if !validHistoryID(id) {
+		return nil, fmt.Errorf("invalid revision id: %q", id)
+	}
+	f, err := os.Open(historyObjectPath(root, id))
+	if err != nil {
+		return nil, fmt.Errorf("revision not found: %s", id)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+// recordHistorySnapshot agrega una revisión al historial del archivo si el contenido cambió desde la última
+func recordHistorySnapshot(root, path string, content []byte, source string) {
+	settings := loadWorkspaceSettings(root)
+	if !historyEnabled(settings.History) || len(content) > maxHistoryFileSize {
+		return
+	}
+	// Solo se guarda historial de archivos dentro del workspace
+	if !isSubPath(root, path) {
+		return
+	}
+	relPath, err := filepath.Rel(root, path)
+	if err != nil {
+		return
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	idx := loadHistoryIndex(root, relPath)
+	id := contentHash(content)
+	if n := len(idx.Revisions); n > 0 && idx.Revisions[n-1].ID == id {
+		return
+	}
+	if _, err := storeHistoryObject(root, content); err != nil {
+		fmt.Printf("[BACK] Error storing history snapshot for %s: %v\n", path, err)
+		return
+	}
+	idx.Revisions = append(idx.Revisions, HistoryRevision{ID: id, Time: time.Now(), Size: int64(len(content)), Source: source})
+	if err := saveHistoryIndex(root, idx); err != nil {
+		fmt.Printf("[BACK] Error saving history index for %s: %v\n", path, err)
+		return
+	}
+	if time.Since(historyLastPrune[root]) > historyPruneInterval {
+		historyLastPrune[root] = time.Now()
+		go pruneHistory(root, settings.History)
+	}
+}
+
+// pruneHistory borra revisiones más viejas que MaxAgeDays y, si el total supera MaxSizeMB, las más antiguas;
+// después elimina los objetos que ya no referencia ninguna revisión
+func pruneHistory(root string, settings HistorySettings) {
+	maxAge := settings.MaxAgeDays
+	if maxAge <= 0 {
+		maxAge = defaultHistoryMaxAge
+	}
+	maxSize := int64(settings.MaxSizeMB)
+	if maxSize <= 0 {
+		maxSize = defaultHistoryMaxSizeMB
+	}
+	maxSize *= 1024 * 1024
+	cutoff := time.Now().AddDate(0, 0, -maxAge)
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	indexDir := filepath.Join(historyDir(root), "index")
+	entries, _ := os.ReadDir(indexDir)
+	type revRef struct {
+		index int
+		rev   HistoryRevision
+	}
+	var indexes []historyIndex
+	var all []revRef
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(indexDir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var idx historyIndex
+		if json.Unmarshal(data, &idx) != nil {
+			continue
+		}
+		// La revisión más reciente de cada archivo se conserva siempre
+		var kept []HistoryRevision
+		for i, rev := range idx.Revisions {
+			if rev.Time.After(cutoff) || i == len(idx.Revisions)-1 {
+				kept = append(kept, rev)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		idx.Revisions = kept
+		for _, rev := range kept[:len(kept)-1] {
+			all = append(all, revRef{index: len(indexes), rev: rev})
+		}
+		indexes = append(indexes, idx)
+	}
+
+	// Contar el tamaño por objeto (deduplicado) y descartar las revisiones más viejas hasta entrar en el límite
+	objectSize := func(id string) int64 {
+		if st, err := os.Stat(historyObjectPath(root, id)); err == nil {
+			return st.Size()
+		}
+		return 0
+	}
+	refs := map[string]int{}
+	var total int64
+	for _, idx := range indexes {
+		for _, rev := range idx.Revisions {
+			if refs[rev.ID] == 0 {
+				total += objectSize(rev.ID)
+			}
+			refs[rev.ID]++
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].rev.Time.Before(all[j].rev.Time) })
+	dropped := map[int]map[time.Time]bool{}
+	for _, ref := range all {
+		if total <= maxSize {
+			break
+		}
+		if dropped[ref.index] == nil {
+			dropped[ref.index] = map[time.Time]bool{}
+		}
+		dropped[ref.index][ref.rev.Time] = true
+		refs[ref.rev.ID]--
+		if refs[ref.rev.ID] == 0 {
+			total -= objectSize(ref.rev.ID)
+		}
+	}
+
+	for i, idx := range indexes {
+		if d := dropped[i]; d != nil {
+			var kept []HistoryRevision
+			for _, rev := range idx.Revisions {
+				if !d[rev.Time] {
+					kept = append(kept, rev)
+				}
+			}
+			idx.Revisions = kept
+		}
+		saveHistoryIndex(root, idx)
+	}
+
+	removed := 0
+	filepath.Walk(filepath.Join(historyDir(root), "objects"), func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && refs[info.Name()] == 0 {
+			if os.Remove(p) == nil {
+				removed++
+			}
+		}
+		return nil
+	})
+	fmt.Printf("[BACK] History pruned for %s: %d objects removed\n", root, removed)
+}
+
+// historyContent devuelve el contenido de una revisión o, con "current", el del archivo en disco
+func historyContent(root, path, id string) ([]byte, error) {
+	if id == "" || id == "current" {
+		return os.ReadFile(path)
+	}
+	return loadHistoryObject(root, id)
+}
+
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var resp HistoryResponse
+	switch r.Method {
+	case "GET":
+		baseDir := r.URL.Query().Get("projectBaseDir")
+		root := workspaceRoot(baseDir)
+		path := resolveWorkspacePath(r.URL.Query().Get("path"), baseDir)
+		relPath, _ := filepath.Rel(root, path)
+		historyMu.Lock()
+		idx := loadHistoryIndex(root, relPath)
+		historyMu.Unlock()
+		// Las más recientes primero
+		revisions := make([]HistoryRevision, 0, len(idx.Revisions))
+		for i := len(idx.Revisions) - 1; i >= 0; i-- {
+			revisions = append(revisions, idx.Revisions[i])
+		}
+		resp = HistoryResponse{Success: true, Message: "History listed successfully", Revisions: revisions}
+	case "POST":
+		var req HistoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("[BACK] HistoryRequest: %+v\n", req)
+		root := workspaceRoot(req.ProjectBaseDir)
+		path := resolveWorkspacePath(req.Path, req.ProjectBaseDir)
+		switch req.Operation {
+		case "diff":
+			from, err := historyContent(root, path, req.From)
+			if err != nil {
+				resp = HistoryResponse{Success: false, Message: "Error reading revision: " + err.Error()}
+				break
+			}
+			to, err := historyContent(root, path, req.To)
+			if err != nil {
+				resp = HistoryResponse{Success: false, Message: "Error reading revision: " + err.Error()}
+				break
+			}
+			resp = HistoryResponse{Success: true, Message: "Diff generated", Diff: unifiedDiff(req.From, req.To, string(from), string(to))}
+		case "restore":
+			if !isUIRequest(r) && isWorkspaceConfigPath(path) {
+				resp = HistoryResponse{Success: false, Message: workspaceConfigReadOnly}
+				break
+			}
+			content, err := loadHistoryObject(root, req.Revision)
+			if err != nil {
+				resp = HistoryResponse{Success: false, Message: "Error reading revision: " + err.Error()}
+				break
+			}
+			// Guardar lo que hay en disco antes de pisarlo, para poder volver atrás
+			if current, err := os.ReadFile(path); err == nil {
+				recordHistorySnapshot(root, path, current, "external")
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				resp = HistoryResponse{Success: false, Message: "Error restoring revision: " + err.Error()}
+				break
+			}
+			if err := atomicWriteFile(path, content, 0644); err != nil {
+				resp = HistoryResponse{Success: false, Message: "Error restoring revision: " + err.Error()}
+				break
+			}
+			recordHistorySnapshot(root, path, content, "restore")
+			notifyLanguageServers(lspFileChanged, path)
+			resp = HistoryResponse{Success: true, Message: "Revision restored", Version: contentHash(content)}
+		default:
+			resp = HistoryResponse{Success: false, Message: "Unknown history operation: " + req.Operation}
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidHistoryID(t *testing.T) {
+	tests := []struct {
+		id string
+		ok bool
+	}{
+		{contentHash([]byte("hello")), true},
+		{strings.Repeat("a", 64), true},
+		{strings.Repeat("A", 64), false},
+		{strings.Repeat("a", 63), false},
+		{strings.Repeat("a", 65), false},
+		{strings.Repeat("g", 64), false},
+		{"../../../../../../../../../../../../../../../../../etc/passwd0000", false},
+		{"aa/" + strings.Repeat("a", 61), false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validHistoryID(tt.id); got != tt.ok {
+			t.Errorf("validHistoryID(%q) = %v, want %v", tt.id, got, tt.ok)
+		}
+	}
+}
+
+func TestHistoryObjectRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	content := []byte("package main\n")
+	id, err := storeHistoryObject(root, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadHistoryObject(root, id)
+	if err != nil || string(got) != string(content) {
+		t.Fatalf("loadHistoryObject = %q, %v", got, err)
+	}
+	if _, err := loadHistoryObject(root, "../"+id[3:]); err == nil {
+		t.Error("loadHistoryObject accepted a path traversal id")
+	}
+}
+
+func TestReplaceRecordsHistory(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "main.go"), "foo := 1\n")
+	re, err := compileSearchPattern("foo", false, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := ReplaceRequest{Operation: "apply", Selections: []ReplaceSelection{{Path: "main.go"}}, ProjectBaseDir: root}
+	if resp := applyReplace(req, re, replacementTemplate("bar", false)); !resp.Success {
+		t.Fatal(resp.Message)
+	}
+	idx := loadHistoryIndex(root, "main.go")
+	if len(idx.Revisions) != 2 {
+		t.Fatalf("revisions = %+v, want the original and the replaced content", idx.Revisions)
+	}
+	if idx.Revisions[0].ID != contentHash([]byte("foo := 1\n")) || idx.Revisions[1].ID != contentHash([]byte("bar := 1\n")) || idx.Revisions[1].Source != "replace" {
+		t.Errorf("revisions = %+v", idx.Revisions)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFileNames son los archivos de reglas que se respetan al recorrer el workspace
+var ignoreFileNames = []string{".gitignore", ".airideignore"}
+
+// alwaysIgnoredDirs nunca se recorren, existan o no reglas que los excluyan
+var alwaysIgnoredDirs = map[string]bool{".git": true, ".hg": true, ".svn": true, workspaceConfigDir: true}
+
+// ignoreRule es una línea de un archivo .gitignore ya compilada
+type ignoreRule struct {
+	base    string // directorio (relativo al root, con '/') donde está el archivo de reglas
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreMatcher acumula las reglas de los archivos de ignore encontrados al recorrer el árbol
+type ignoreMatcher struct {
+	root  string
+	rules []ignoreRule
+	seen  map[string]bool
+}
+
+// newIgnoreMatcher crea un matcher vacío para el directorio raíz dado
+func newIgnoreMatcher(root string) *ignoreMatcher {
+	return &ignoreMatcher{root: root, seen: map[string]bool{}}
+}
+
+// loadDir lee los archivos de ignore de un directorio (relativo al root) una sola vez
+func (m *ignoreMatcher) loadDir(relDir string) {
+	if m.seen[relDir] {
+		return
+	}
+	m.seen[relDir] = true
+	for _, name := range ignoreFileNames {
+		f, err := os.Open(filepath.Join(m.root, filepath.FromSlash(relDir), name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreLine(relDir, scanner.Text()); ok {
+				m.rules = append(m.rules, rule)
+			}
+		}
+		f.Close()
+	}
+}
+
+// parseIgnoreLine convierte una línea con sintaxis gitignore en una regla
+func parseIgnoreLine(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, "\\")
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	// Un patrón sin '/' intermedio aplica a cualquier nivel por debajo de base
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if !anchored {
+		line = "**/" + line
+	}
+	re, err := globToRegexp(line)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// ignored indica si una ruta relativa al root (con '/') debe omitirse
+func (m *ignoreMatcher) ignored(relPath string, isDir bool) bool {
+	if isDir && alwaysIgnoredDirs[pathBase(relPath)] {
+		return true
+	}
+	result := false
+	for _, rule := range m.rules {
+		rel := relPath
+		if rule.base != "" {
+			if !strings.HasPrefix(relPath, rule.base+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(relPath, rule.base+"/")
+		}
+		if rule.dirOnly && !isDir {
+			// Una regla "dir/" solo alcanza a un archivo a través de alguno de los directorios que lo contienen
+			i := strings.LastIndex(rel, "/")
+			if i < 0 {
+				continue
+			}
+			rel = rel[:i]
+		}
+		if rule.re.MatchString(rel) {
+			result = !rule.negate
+		}
+	}
+	return result
+}
+
+// pathBase devuelve el último segmento de una ruta con '/'
+func pathBase(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[i+1:]
+	}
+	return p
+}
+
+// globToRegexp compila un glob con soporte para '**', '*', '?' y clases '[...]'
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" coincide con cero o más directorios
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// Un directorio que coincide también excluye todo su contenido
+	b.WriteString("(?:/.*)?$")
+	return regexp.Compile(b.String())
+}
+
+// compileGlobs compila una lista de globs de include/exclude
+func compileGlobs(globs []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, g := range globs {
+		g = strings.TrimSpace(g)
+		if g == "" {
+			continue
+		}
+		if !strings.Contains(g, "/") {
+			g = "**/" + g
+		}
+		re, err := globToRegexp(strings.TrimPrefix(g, "/"))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// matchAnyGlob indica si la ruta coincide con alguno de los globs compilados
+func matchAnyGlob(globs []*regexp.Regexp, relPath string) bool {
+	for _, re := range globs {
+		if re.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
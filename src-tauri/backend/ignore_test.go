@@ -0,0 +1,87 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, ".gitignore"), "# comentario\n*.log\n!keep.log\n/build\ndist/\ndocs/*.pdf\nsrc/**/gen\n\\#notes\n\\!bang\ntmp[0-9]\nfile[!a].txt\ntrailing   \n")
+	writeTestFile(t, filepath.Join(root, ".airideignore"), "secret.txt\n")
+	writeTestFile(t, filepath.Join(root, "sub", ".gitignore"), "*.tmp\n/local\n")
+	m := newIgnoreMatcher(root)
+	m.loadDir("")
+	m.loadDir("sub")
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"a/b/app.log", false, true},
+		{"keep.log", false, false},
+		{"a/keep.log", false, false},
+		{"build", true, true},
+		{"build/out.js", false, true},
+		{"a/build", true, false},
+		{"dist", true, true},
+		{"a/dist", true, true},
+		{"dist", false, false},
+		{"dist/bundle.js", false, true},
+		{"docs/manual.pdf", false, true},
+		{"docs/a/manual.pdf", false, false},
+		{"a/docs/manual.pdf", false, false},
+		{"src/gen", true, true},
+		{"src/a/b/gen", true, true},
+		{"src/a/gen/x.go", false, true},
+		{"lib/gen", true, false},
+		{"#notes", false, true},
+		{"!bang", false, true},
+		{"tmp1", true, true},
+		{"tmpx", true, false},
+		{"fileb.txt", false, true},
+		{"filea.txt", false, false},
+		{"trailing", false, true},
+		{"secret.txt", false, true},
+		{"sub/x.tmp", false, true},
+		{"x.tmp", false, false},
+		{"sub/local", true, true},
+		{"sub/a/local", true, false},
+		{"local", true, false},
+		{".git", true, true},
+		{"a/node_modules.log/x", false, true},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestCompileGlobs(t *testing.T) {
+	globs, err := compileGlobs([]string{"*.go", "web/**/*.ts", " ", "/docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"main.go", true},
+		{"a/b/main.go", true},
+		{"main.gox", false},
+		{"web/app.ts", true},
+		{"web/src/app.ts", true},
+		{"src/web/app.ts", false},
+		{"docs/readme.md", true},
+		{"a/docs/readme.md", false},
+	}
+	for _, tt := range tests {
+		if got := matchAnyGlob(globs, tt.path); got != tt.want {
+			t.Errorf("matchAnyGlob(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
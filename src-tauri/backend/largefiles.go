@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxReadSizeMB = 50
+	// Tamaño máximo de un fragmento devuelto por readRange / readLines
+	maxChunkSize        = 4 * 1024 * 1024
+	defaultChunkSize    = 256 * 1024
+	defaultLineCount    = 1000
+	maxLineCount        = 50000
+	lineCheckpointEvery = 1000
+	defaultTailLines    = 100
+	tailPollInterval    = 500 * time.Millisecond
+	// Las líneas más largas se recortan en readLines y en tail (archivos minificados, logs sin saltos)
+	maxLineLength = 64 * 1024
+	// Cantidad de archivos cuyo índice de líneas se mantiene en memoria
+	maxLineIndexes = 64
+)
+
+// maxReadSize devuelve el límite en bytes para abrir un archivo completo en el editor
+func maxReadSize(settings WorkspaceSettings) int64 {
+	mb := settings.Files.MaxReadSizeMB
+	if mb <= 0 {
+		mb = defaultMaxReadSizeMB
+	}
+	return int64(mb) * 1024 * 1024
+}
+
+// lineIndex guarda el offset de cada lineCheckpointEvery líneas para no releer el archivo desde el inicio
+type lineIndex struct {
+	size        int64
+	modTime     time.Time
+	checkpoints []int64 // checkpoints[k] = offset donde empieza la línea k*lineCheckpointEvery
+	used        time.Time
+}
+
+var (
+	lineIndexMu    sync.Mutex
+	lineIndexCache = map[string]*lineIndex{}
+)
+
+// lineOffset busca el offset donde empieza la línea (base 0) usando y ampliando el índice de checkpoints
+func lineOffset(path string, f *os.File, info os.FileInfo, line int) (int64, error) {
+	lineIndexMu.Lock()
+	idx, ok := lineIndexCache[path]
+	// Si el archivo se truncó o reescribió el índice ya no sirve; si solo creció se puede seguir usando
+	if !ok || info.Size() < idx.size || (info.Size() == idx.size && !info.ModTime().Equal(idx.modTime)) {
+		if !ok && len(lineIndexCache) >= maxLineIndexes {
+			evictLineIndex()
+		}
+		idx = &lineIndex{checkpoints: []int64{0}}
+		lineIndexCache[path] = idx
+	}
+	idx.used = time.Now()
+	idx.size = info.Size()
+	idx.modTime = info.ModTime()
+	k := line / lineCheckpointEvery
+	if k >= len(idx.checkpoints) {
+		k = len(idx.checkpoints) - 1
+	}
+	offset := idx.checkpoints[k]
+	current := k * lineCheckpointEvery
+	lineIndexMu.Unlock()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReaderSize(f, 64*1024)
+	for current < line {
+		chunk, err := reader.ReadSlice('\n')
+		offset += int64(len(chunk))
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF {
+				return offset, nil
+			}
+			return 0, err
+		}
+		current++
+		if current%lineCheckpointEvery == 0 {
+			lineIndexMu.Lock()
+			if current/lineCheckpointEvery == len(idx.checkpoints) {
+				idx.checkpoints = append(idx.checkpoints, offset)
+			}
+			lineIndexMu.Unlock()
+		}
+	}
+	return offset, nil
+}
+
+// evictLineIndex descarta el índice usado hace más tiempo; se llama con lineIndexMu tomado
+func evictLineIndex() {
+	oldest := ""
+	for path, idx := range lineIndexCache {
+		if oldest == "" || idx.used.Before(lineIndexCache[oldest].used) {
+			oldest = path
+		}
+	}
+	delete(lineIndexCache, oldest)
+}
+
+// readBoundedLine lee una línea (con su '\n') guardando como mucho max bytes; el resto se lee y se descarta.
+// Devuelve también cuántos bytes avanzó en el archivo y si la línea se recortó.
+func readBoundedLine(reader *bufio.Reader, max int) (line []byte, consumed int64, truncated bool, err error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		consumed += int64(len(chunk))
+		if !truncated {
+			if room := max - len(line); len(chunk) > room {
+				line = append(line, trimToRuneBoundary(chunk[:room])...)
+				truncated = true
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		// La línea recortada conserva su salto para que el editor siga contando bien las líneas
+		if truncated && len(chunk) > 0 && chunk[len(chunk)-1] == '\n' {
+			line = append(line, '\n')
+		}
+		return line, consumed, truncated, err
+	}
+}
+
+// chunkResponse arma la respuesta de un fragmento, en base64 si el contenido es binario
+func chunkResponse(chunk []byte, offset, size int64) FileResponse {
+	resp := FileResponse{
+		Success:    true,
+		Message:    "File chunk read successfully",
+		Size:       size,
+		Offset:     offset,
+		NextOffset: offset + int64(len(chunk)),
+		EOF:        offset+int64(len(chunk)) >= size,
+	}
+	if isBinaryContent(chunk) {
+		resp.Content = base64.StdEncoding.EncodeToString(chunk)
+		resp.ContentEncoding = "base64"
+		resp.Binary = true
+	} else {
+		resp.Content = string(chunk)
+	}
+	return resp
+}
+
+// trimToRuneBoundary evita cortar un carácter UTF-8 multibyte al final del fragmento
+func trimToRuneBoundary(chunk []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(chunk); i++ {
+		b := chunk[len(chunk)-i]
+		if b < 0x80 {
+			return chunk
+		}
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(chunk[len(chunk)-i:]) {
+				return chunk[:len(chunk)-i]
+			}
+			return chunk
+		}
+	}
+	return chunk
+}
+
+// readFileRange lee length bytes a partir de offset
+func readFileRange(baseDir string, path string, offset, length int64) FileResponse {
+	path = resolveWorkspacePath(path, baseDir)
+	fmt.Printf("[BACK] readFileRange called with path: '%s', offset: %d, length: %d\n", path, offset, length)
+	f, err := os.Open(path)
+	if err != nil {
+		return FileResponse{Success: false, Message: "Error opening file: " + err.Error()}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return FileResponse{Success: false, Message: "Error reading file: " + err.Error()}
+	}
+	if length <= 0 {
+		length = defaultChunkSize
+	}
+	if length > maxChunkSize {
+		length = maxChunkSize
+	}
+	if offset < 0 {
+		// Un offset negativo se cuenta desde el final del archivo
+		offset = info.Size() + offset
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	if offset > info.Size() {
+		offset = info.Size()
+	}
+	chunk := make([]byte, length)
+	n, err := f.ReadAt(chunk, offset)
+	if err != nil && err != io.EOF {
+		return FileResponse{Success: false, Message: "Error reading file: " + err.Error()}
+	}
+	chunk = chunk[:n]
+	if offset+int64(n) < info.Size() {
+		chunk = trimToRuneBoundary(chunk)
+	}
+	return chunkResponse(chunk, offset, info.Size())
+}
+
+// readFileLines lee lineCount líneas a partir de startLine (base 1)
+func readFileLines(baseDir string, path string, startLine, lineCount int) FileResponse {
+	path = resolveWorkspacePath(path, baseDir)
+	fmt.Printf("[BACK] readFileLines called with path: '%s', startLine: %d, lineCount: %d\n", path, startLine, lineCount)
+	if startLine < 1 {
+		startLine = 1
+	}
+	if lineCount <= 0 {
+		lineCount = defaultLineCount
+	}
+	if lineCount > maxLineCount {
+		lineCount = maxLineCount
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return FileResponse{Success: false, Message: "Error opening file: " + err.Error()}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return FileResponse{Success: false, Message: "Error reading file: " + err.Error()}
+	}
+	offset, err := lineOffset(path, f, info, startLine-1)
+	if err != nil {
+		return FileResponse{Success: false, Message: "Error reading file: " + err.Error()}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return FileResponse{Success: false, Message: "Error reading file: " + err.Error()}
+	}
+	reader := bufio.NewReaderSize(f, 64*1024)
+	var buf bytes.Buffer
+	lines := 0
+	next := offset
+	truncated := false
+	for lines < lineCount && buf.Len() < maxChunkSize {
+		line, consumed, cut, err := readBoundedLine(reader, maxLineLength)
+		buf.Write(line)
+		next += consumed
+		truncated = truncated || cut
+		if consumed > 0 {
+			lines++
+		}
+		if err != nil {
+			break
+		}
+	}
+	resp := chunkResponse(buf.Bytes(), offset, info.Size())
+	// Con líneas recortadas se avanzó en el archivo más de lo que se devuelve
+	resp.NextOffset = next
+	resp.EOF = next >= info.Size()
+	resp.StartLine = startLine
+	resp.LineCount = lines
+	resp.TruncatedLines = truncated
+	return resp
+}
+
+// tailHandler envía por SSE las últimas líneas de un archivo y luego cada línea que se le agregue
+func tailHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := resolveWorkspacePath(r.URL.Query().Get("path"), r.URL.Query().Get("projectBaseDir"))
+	lines, err := strconv.Atoi(r.URL.Query().Get("lines"))
+	if err != nil || lines <= 0 {
+		lines = defaultTailLines
+	}
+	follow := r.URL.Query().Get("follow") != "false"
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "Error opening file: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	// f puede reabrirse si el archivo se rota, por eso se cierra el valor vigente al salir
+	defer func() { f.Close() }()
+	fmt.Printf("[BACK] /api/tail following '%s' (last %d lines)\n", path, lines)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	send := func(event string, data interface{}) {
+		payload, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		flusher.Flush()
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		send("error", map[string]string{"message": err.Error()})
+		return
+	}
+	offset := tailStartOffset(f, info.Size(), lines)
+	var pending []byte
+	// skipping indica que se está descartando el resto de una línea que ya se envió recortada
+	skipping := false
+	emitFrom := func() error {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxChunkSize))
+		if err != nil {
+			return err
+		}
+		offset += int64(len(data))
+		pending = append(pending, data...)
+		if skipping {
+			i := bytes.IndexByte(pending, '\n')
+			if i < 0 {
+				pending = nil
+				return nil
+			}
+			pending, skipping = pending[i+1:], false
+		}
+		// Solo se envían líneas completas; el resto queda pendiente hasta que llegue el '\n'
+		if i := bytes.LastIndexByte(pending, '\n'); i >= 0 {
+			send("lines", map[string]interface{}{"lines": splitLines(string(pending[:i])), "offset": offset})
+			pending = append([]byte{}, pending[i+1:]...)
+		}
+		// Una línea sin '\n' no puede crecer sin límite: se envía recortada y se descarta hasta el próximo salto
+		if len(pending) > maxLineLength {
+			line := string(trimToRuneBoundary(pending[:maxLineLength]))
+			send("lines", map[string]interface{}{"lines": []string{line}, "offset": offset, "truncated": true})
+			pending, skipping = nil, true
+		}
+		return nil
+	}
+	if err := emitFrom(); err != nil {
+		send("error", map[string]string{"message": err.Error()})
+		return
+	}
+	if !follow {
+		send("end", map[string]int64{"offset": offset})
+		return
+	}
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				send("error", map[string]string{"message": err.Error()})
+				return
+			}
+			if info.Size() < offset {
+				// Archivo truncado o rotado: volver a empezar desde el inicio
+				send("truncated", map[string]int64{"size": info.Size()})
+				f.Close()
+				if f, err = os.Open(path); err != nil {
+					send("error", map[string]string{"message": err.Error()})
+					return
+				}
+				offset = 0
+				pending, skipping = nil, false
+			}
+			if info.Size() > offset {
+				if err := emitFrom(); err != nil {
+					send("error", map[string]string{"message": err.Error()})
+					return
+				}
+			}
+		}
+	}
+}
+
+// tailStartOffset retrocede desde el final hasta encontrar el inicio de las últimas n líneas
+func tailStartOffset(f *os.File, size int64, n int) int64 {
+	const block = 64 * 1024
+	pos := size
+	newlines := 0
+	buf := make([]byte, block)
+	// Un salto final no cuenta como línea adicional
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err == nil && last[0] == '\n' {
+			newlines = -1
+		}
+	}
+	for pos > 0 {
+		readSize := int64(block)
+		if pos < readSize {
+			readSize = pos
+		}
+		pos -= readSize
+		if _, err := f.ReadAt(buf[:readSize], pos); err != nil && err != io.EOF {
+			return 0
+		}
+		for i := readSize - 1; i >= 0; i-- {
+			if buf[i] == '\n' {
+				newlines++
+				if newlines == n {
+					return pos + i + 1
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// downloadHandler transmite el archivo como descarga, sin cargarlo en memoria y con soporte de rangos
+func downloadHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := resolveWorkspacePath(r.URL.Query().Get("path"), r.URL.Query().Get("projectBaseDir"))
+	fmt.Printf("[BACK] /api/download streaming '%s'\n", path)
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "Error opening file: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "Not a regular file", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
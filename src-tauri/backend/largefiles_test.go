@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFileLinesTruncatesLongLines(t *testing.T) {
+	root := t.TempDir()
+	long := strings.Repeat("x", maxLineLength+100)
+	content := "first\n" + long + "\nthird\n"
+	writeTestFile(t, filepath.Join(root, "min.js"), content)
+
+	resp := readFileLines(root, "min.js", 1, 2)
+	if !resp.Success || !resp.TruncatedLines || resp.LineCount != 2 {
+		t.Fatalf("readFileLines = %+v", resp)
+	}
+	if want := "first\n" + long[:maxLineLength] + "\n"; resp.Content != want {
+		t.Errorf("content has %d bytes, want %d", len(resp.Content), len(want))
+	}
+	// nextOffset apunta al final de la línea completa, no al del texto recortado
+	if want := int64(len("first\n" + long + "\n")); resp.NextOffset != want || resp.EOF {
+		t.Errorf("nextOffset = %d (eof %v), want %d", resp.NextOffset, resp.EOF, want)
+	}
+
+	resp = readFileLines(root, "min.js", 3, 10)
+	if resp.Content != "third\n" || resp.TruncatedLines || !resp.EOF {
+		t.Errorf("third line = %+v", resp)
+	}
+}
+
+func TestLineIndexCacheIsBounded(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < maxLineIndexes+10; i++ {
+		name := fmt.Sprintf("f%d.txt", i)
+		writeTestFile(t, filepath.Join(root, name), "a\nb\n")
+		if resp := readFileLines(root, name, 2, 1); resp.Content != "b\n" {
+			t.Fatalf("%s: content = %q", name, resp.Content)
+		}
+	}
+	lineIndexMu.Lock()
+	n := len(lineIndexCache)
+	lineIndexMu.Unlock()
+	if n > maxLineIndexes {
+		t.Errorf("line index cache has %d entries, max %d", n, maxLineIndexes)
+	}
+}
+
+func TestTailTruncatesLinesWithoutNewline(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app.log")
+	if err := os.WriteFile(path, []byte("ok\n"+strings.Repeat("y", maxLineLength*2)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	tailHandler(w, httptest.NewRequest("GET", "/api/tail?follow=false&path="+filepath.ToSlash(path), nil))
+	body := w.Body.String()
+	if !strings.Contains(body, `"lines":["ok"]`) || !strings.Contains(body, `"truncated":true`) {
+		t.Fatalf("tail events = %.300s", body)
+	}
+	if strings.Contains(body, strings.Repeat("y", maxLineLength+1)) {
+		t.Error("tail sent more than maxLineLength bytes of a single line")
+	}
+}
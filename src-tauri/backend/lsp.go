@@ -0,0 +1,1268 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Un servidor sin editores conectados se detiene pasado este tiempo
+	lspIdleTimeout = 5 * time.Minute
+	// Si se cae más veces que esto dentro de la ventana, se deja de reiniciar
+	lspMaxRestarts   = 5
+	lspRestartWindow = 3 * time.Minute
+	lspStopTimeout   = 2 * time.Second
+	lspStderrLimit   = 64 * 1024
+	lspMaxMessage    = 64 << 20
+	// Mensajes pendientes por editor; un cliente que no los lee se desconecta
+	lspClientQueue = 1024
+)
+
+// Tipos de cambio de workspace/didChangeWatchedFiles
+const (
+	lspFileCreated = 1
+	lspFileChanged = 2
+	lspFileDeleted = 3
+)
+
+// lspServerSpec describe un servidor de lenguaje y los ids de lenguaje de Monaco que atiende
+type lspServerSpec struct {
+	ID        string
+	Languages []string
+	// Alternativas en orden de preferencia: ejecutable y argumentos
+	Commands [][]string
+}
+
+var languageServerSpecs = []lspServerSpec{
+	{ID: "go", Languages: []string{"go"}, Commands: [][]string{{"gopls"}}},
+	{ID: "typescript", Languages: []string{"typescript", "javascript", "typescriptreact", "javascriptreact"}, Commands: [][]string{{"typescript-language-server", "--stdio"}}},
+	{ID: "python", Languages: []string{"python"}, Commands: [][]string{{"pyright-langserver", "--stdio"}, {"basedpyright-langserver", "--stdio"}, {"pylsp"}}},
+	{ID: "rust", Languages: []string{"rust"}, Commands: [][]string{{"rust-analyzer"}}},
+}
+
+func findLanguageServerSpec(language string) *lspServerSpec {
+	language = strings.ToLower(language)
+	for i, spec := range languageServerSpecs {
+		if spec.ID == language {
+			return &languageServerSpecs[i]
+		}
+		for _, l := range spec.Languages {
+			if l == language {
+				return &languageServerSpecs[i]
+			}
+		}
+	}
+	return nil
+}
+
+// resolveLanguageServer busca el primer ejecutable instalado con el PATH del workspace
+// (incluye node_modules/.bin, el venv y GOPATH/bin)
+func resolveLanguageServer(spec *lspServerSpec, pathList string) (string, []string, error) {
+	var names []string
+	for _, command := range spec.Commands {
+		if path, err := lookPathIn(command[0], pathList); err == nil {
+			return path, command[1:], nil
+		}
+		names = append(names, command[0])
+	}
+	return "", nil, errors.New("language server not installed: " + strings.Join(names, ", "))
+}
+
+// fileURI y uriPath convierten entre rutas del sistema y URIs file:// de LSP
+func fileURI(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/")
+	}
+	return filepath.Clean(filepath.FromSlash(p))
+}
+
+// lspRaw es un mensaje JSON-RPC sin interpretar, para reenviarlo cambiando solo el id
+type lspRaw map[string]json.RawMessage
+
+func (m lspRaw) method() string {
+	var method string
+	json.Unmarshal(m["method"], &method)
+	return method
+}
+
+func (m lspRaw) id() (json.RawMessage, bool) {
+	id, ok := m["id"]
+	return id, ok && string(id) != "null"
+}
+
+func lspResponse(id json.RawMessage, result interface{}) []byte {
+	out, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "result": result})
+	return out
+}
+
+func lspErrorResponse(id json.RawMessage, message string) []byte {
+	out, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   map[string]interface{}{"code": -32603, "message": message},
+	})
+	return out
+}
+
+// readLSPMessage lee un mensaje con las cabeceras Content-Length de la salida del servidor
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if length >= 0 {
+				break
+			}
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %q", value)
+			}
+		}
+	}
+	if length > lspMaxMessage {
+		return nil, fmt.Errorf("message too large: %d bytes", length)
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+// lspOffset convierte una posición LSP (columna en unidades UTF-16) en un índice de bytes del texto.
+// Una columna más allá del final de la línea apunta al final, antes del "\r\n" o "\n".
+func lspOffset(text string, pos lspPosition) int {
+	off := 0
+	for l := 0; l < pos.Line; l++ {
+		i := strings.IndexByte(text[off:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		off += i + 1
+	}
+	units := 0
+	for i, r := range text[off:] {
+		if units >= pos.Character || r == '\n' || r == '\r' {
+			return off + i
+		}
+		if r >= 0x10000 {
+			units += 2
+		} else {
+			units++
+		}
+	}
+	return len(text)
+}
+
+// lspDocument es el contenido de un documento abierto en el editor, tal como lo conoce el servidor
+type lspDocument struct {
+	path       string
+	languageID string
+	version    int
+	text       string
+	refs       int // editores que lo tienen abierto
+}
+
+// lspClient es un editor conectado por WebSocket
+type lspClient struct {
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+	docs map[string]bool // URIs abiertas por este editor
+}
+
+func (c *lspClient) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+func (c *lspClient) deliver(msg []byte) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	select {
+	case c.send <- msg:
+	default:
+		fmt.Println("[BACK] LSP client is not reading, disconnecting it")
+		c.close()
+	}
+}
+
+// lspPending es una petición reenviada al servidor; la respuesta vuelve al editor con su id original
+type lspPending struct {
+	client   *lspClient
+	id       json.RawMessage
+	method   string
+	callback func(lspRaw) // peticiones propias del backend
+}
+
+// languageServer es un proceso de servidor por workspace y lenguaje, compartido por todos los editores.
+// Los ids de las peticiones se renumeran para que no choquen entre editores.
+type languageServer struct {
+	key  string
+	root string
+	spec *lspServerSpec
+
+	mu               sync.Mutex
+	writeMu          sync.Mutex
+	cmd              *exec.Cmd
+	stdin            io.WriteCloser
+	exited           chan struct{}
+	command          string
+	status           string // "starting", "running", "restarting", "exited", "failed" o "stopped"
+	started          time.Time
+	generation       int
+	crashes          []time.Time
+	restarts         int
+	restartRequested bool
+	stopping         bool
+	clients          map[*lspClient]bool
+	lastClient       *lspClient
+	pending          map[int64]lspPending
+	nextID           int64
+	initParams       json.RawMessage
+	initResult       json.RawMessage
+	initialized      bool
+	// ready se cierra cuando el servidor respondió a initialize; se renueva en cada reinicio
+	ready     chan struct{}
+	docs      map[string]*lspDocument
+	stderr    []byte
+	idleTimer *time.Timer
+}
+
+var (
+	languageServersMu sync.Mutex
+	languageServers   = map[string]*languageServer{}
+)
+
+// attachLanguageServer conecta un editor al servidor del workspace, arrancándolo si hace falta
+func attachLanguageServer(root string, spec *lspServerSpec) (*languageServer, *lspClient, error) {
+	key := root + "\x00" + spec.ID
+	c := &lspClient{send: make(chan []byte, lspClientQueue), done: make(chan struct{}), docs: map[string]bool{}}
+	languageServersMu.Lock()
+	s := languageServers[key]
+	if s == nil {
+		s = &languageServer{
+			key:     key,
+			root:    root,
+			spec:    spec,
+			clients: map[*lspClient]bool{},
+			pending: map[int64]lspPending{},
+			docs:    map[string]*lspDocument{},
+			ready:   make(chan struct{}),
+		}
+		languageServers[key] = s
+	}
+	s.mu.Lock()
+	s.clients[c] = true
+	s.lastClient = c
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+		s.idleTimer = nil
+	}
+	needsStart := false
+	switch s.status {
+	case "", "exited", "failed", "stopped":
+		s.status = "starting"
+		s.crashes = nil
+		needsStart = true
+	}
+	s.mu.Unlock()
+	languageServersMu.Unlock()
+
+	if needsStart {
+		if err := s.spawn(); err != nil {
+			s.mu.Lock()
+			s.status = "failed"
+			s.mu.Unlock()
+			s.detach(c)
+			// Sin otros editores no tiene sentido conservarlo
+			languageServersMu.Lock()
+			s.mu.Lock()
+			if len(s.clients) == 0 && languageServers[key] == s {
+				delete(languageServers, key)
+				if s.idleTimer != nil {
+					s.idleTimer.Stop()
+					s.idleTimer = nil
+				}
+			}
+			s.mu.Unlock()
+			languageServersMu.Unlock()
+			return nil, nil, err
+		}
+	}
+	return s, c, nil
+}
+
+func (s *languageServer) name() string {
+	if s.command != "" {
+		return filepath.Base(s.command)
+	}
+	return s.spec.Commands[0][0]
+}
+
+// spawn arranca el proceso con el entorno del workspace
+func (s *languageServer) spawn() error {
+	env := buildCommandEnv(s.root, nil)
+	path, args, err := resolveLanguageServer(s.spec, env.get("PATH"))
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Dir = s.root
+	cmd.Env = env.list()
+	cmd.Stderr = lspStderrWriter{s}
+	setProcessGroup(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	fmt.Printf("[BACK] Started language server %s (pid %d) for %s\n", path, cmd.Process.Pid, s.root)
+
+	s.mu.Lock()
+	s.generation++
+	gen := s.generation
+	exited := make(chan struct{})
+	s.cmd = cmd
+	s.stdin = stdin
+	s.exited = exited
+	s.command = path
+	s.status = "running"
+	s.started = time.Now()
+	s.mu.Unlock()
+
+	go s.readLoop(bufio.NewReaderSize(stdout, 64*1024), gen)
+	go s.wait(cmd, gen, exited)
+	return nil
+}
+
+// lspStderrWriter guarda el final del stderr del servidor para mostrarlo en /api/lsp
+type lspStderrWriter struct{ s *languageServer }
+
+func (w lspStderrWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	w.s.stderr = append(w.s.stderr, p...)
+	if len(w.s.stderr) > lspStderrLimit {
+		w.s.stderr = append([]byte{}, w.s.stderr[len(w.s.stderr)-lspStderrLimit/2:]...)
+	}
+	return len(p), nil
+}
+
+func (s *languageServer) writeRaw(body []byte) error {
+	s.mu.Lock()
+	stdin := s.stdin
+	s.mu.Unlock()
+	if stdin == nil {
+		return errors.New("language server is not running")
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := fmt.Fprintf(stdin, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := stdin.Write(body)
+	return err
+}
+
+func (s *languageServer) notify(method string, params interface{}) error {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return s.writeRaw(body)
+}
+
+// call envía una petición propia del backend (initialize, shutdown) y entrega la respuesta a callback
+func (s *languageServer) call(method string, params interface{}, callback func(lspRaw)) error {
+	return s.request(lspPending{method: method, callback: callback}, params)
+}
+
+func (s *languageServer) request(p lspPending, params interface{}) error {
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.pending[id] = p
+	s.mu.Unlock()
+	msg := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": p.method}
+	if params != nil {
+		msg["params"] = params
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return s.writeRaw(body)
+}
+
+// broadcast manda un mensaje del servidor a todos los editores conectados
+func (s *languageServer) broadcast(msg []byte) {
+	s.mu.Lock()
+	clients := make([]*lspClient, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+	for _, c := range clients {
+		c.deliver(msg)
+	}
+}
+
+// showMessage avisa a los editores con window/showMessage (1 error, 2 aviso, 3 info)
+func (s *languageServer) showMessage(kind int, message string) {
+	msg, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "window/showMessage",
+		"params":  map[string]interface{}{"type": kind, "message": message},
+	})
+	s.broadcast(msg)
+}
+
+// markReady cierra ready si no estaba cerrado; se llama con mu tomado
+func (s *languageServer) markReady() {
+	select {
+	case <-s.ready:
+	default:
+		close(s.ready)
+	}
+}
+
+// waitReady bloquea los mensajes de un editor mientras el servidor (re)inicia
+func (s *languageServer) waitReady(c *lspClient) bool {
+	for {
+		s.mu.Lock()
+		ready := s.ready
+		s.mu.Unlock()
+		select {
+		case <-ready:
+			s.mu.Lock()
+			current := s.ready == ready
+			s.mu.Unlock()
+			if current {
+				return true
+			}
+		case <-c.done:
+			return false
+		}
+	}
+}
+
+func (s *languageServer) readLoop(r *bufio.Reader, gen int) {
+	for {
+		body, err := readLSPMessage(r)
+		if err != nil {
+			if err != io.EOF {
+				fmt.Printf("[BACK] Error reading from %s: %v\n", s.name(), err)
+			}
+			return
+		}
+		s.fromServer(body, gen)
+	}
+}
+
+// fromServer reparte un mensaje del servidor: respuestas al editor que preguntó, peticiones al último
+// editor conectado y notificaciones (diagnósticos, progreso) a todos
+func (s *languageServer) fromServer(body []byte, gen int) {
+	var msg lspRaw
+	if err := json.Unmarshal(body, &msg); err != nil {
+		fmt.Printf("[BACK] Invalid message from %s: %v\n", s.name(), err)
+		return
+	}
+	method := msg.method()
+	id, hasID := msg.id()
+	s.mu.Lock()
+	if gen != s.generation {
+		s.mu.Unlock()
+		return
+	}
+	switch {
+	case method != "" && hasID:
+		c := s.lastClient
+		s.mu.Unlock()
+		if c == nil {
+			s.writeRaw(lspErrorResponse(id, "No editor attached"))
+			return
+		}
+		c.deliver(body)
+	case method != "":
+		s.mu.Unlock()
+		s.broadcast(body)
+	case hasID:
+		n, err := strconv.ParseInt(string(id), 10, 64)
+		p, ok := s.pending[n]
+		if err != nil || !ok {
+			s.mu.Unlock()
+			return
+		}
+		delete(s.pending, n)
+		s.mu.Unlock()
+		if p.callback != nil {
+			p.callback(msg)
+			return
+		}
+		msg["id"] = p.id
+		out, _ := json.Marshal(msg)
+		p.client.deliver(out)
+	default:
+		s.mu.Unlock()
+	}
+}
+
+// fromClient reenvía un mensaje del editor, resolviendo aquí lo que afecta al servidor compartido
+// (initialize, shutdown/exit y la apertura de documentos)
+func (s *languageServer) fromClient(c *lspClient, body []byte) {
+	var msg lspRaw
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return
+	}
+	method := msg.method()
+	id, hasID := msg.id()
+	if method == "initialize" && hasID {
+		s.initialize(c, msg, id)
+		return
+	}
+	if !s.waitReady(c) {
+		return
+	}
+	switch method {
+	case "":
+		// Respuesta a una petición del servidor
+		s.writeRaw(body)
+		return
+	case "shutdown":
+		// El servidor sigue vivo para los demás editores; se detiene al quedarse sin ellos
+		c.deliver(lspResponse(id, nil))
+		return
+	case "exit":
+		return
+	case "initialized":
+		s.mu.Lock()
+		already := s.initialized
+		s.initialized = true
+		s.mu.Unlock()
+		if already {
+			return
+		}
+	case "$/cancelRequest":
+		var params struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.Unmarshal(msg["params"], &params)
+		s.mu.Lock()
+		proxyID := int64(-1)
+		for n, p := range s.pending {
+			if p.client == c && string(p.id) == string(params.ID) {
+				proxyID = n
+				break
+			}
+		}
+		s.mu.Unlock()
+		if proxyID < 0 {
+			return
+		}
+		s.notify(method, map[string]interface{}{"id": proxyID})
+		return
+	case "textDocument/didOpen", "textDocument/didChange", "textDocument/didClose":
+		if !s.trackDocument(c, method, msg["params"]) {
+			return
+		}
+	}
+	if hasID {
+		s.mu.Lock()
+		s.nextID++
+		n := s.nextID
+		s.pending[n] = lspPending{client: c, id: id, method: method}
+		s.mu.Unlock()
+		msg["id"] = json.RawMessage(strconv.FormatInt(n, 10))
+		body, _ = json.Marshal(msg)
+		if err := s.writeRaw(body); err != nil {
+			s.mu.Lock()
+			delete(s.pending, n)
+			s.mu.Unlock()
+			c.deliver(lspErrorResponse(id, err.Error()))
+		}
+		return
+	}
+	s.writeRaw(body)
+}
+
+// initialize reenvía el initialize del primer editor con la raíz del workspace; los siguientes
+// reciben la respuesta guardada
+func (s *languageServer) initialize(c *lspClient, msg lspRaw, id json.RawMessage) {
+	s.mu.Lock()
+	ready := s.ready
+	if s.initParams != nil {
+		s.mu.Unlock()
+		select {
+		case <-ready:
+		case <-c.done:
+			return
+		}
+		s.mu.Lock()
+		result := s.initResult
+		s.mu.Unlock()
+		if result == nil {
+			c.deliver(lspErrorResponse(id, s.name()+" failed to initialize"))
+			return
+		}
+		c.deliver(lspResponse(id, result))
+		return
+	}
+	params := lspRaw{}
+	json.Unmarshal(msg["params"], &params)
+	rootURI, _ := json.Marshal(fileURI(s.root))
+	rootPath, _ := json.Marshal(s.root)
+	folders, _ := json.Marshal([]map[string]string{{"uri": fileURI(s.root), "name": filepath.Base(s.root)}})
+	params["rootUri"] = rootURI
+	params["rootPath"] = rootPath
+	params["workspaceFolders"] = folders
+	// Si el backend muere, el servidor también termina
+	params["processId"] = json.RawMessage(strconv.Itoa(os.Getpid()))
+	raw, _ := json.Marshal(params)
+	s.initParams = raw
+	s.mu.Unlock()
+
+	// Con client e id, si el proceso se cae antes de responder el editor recibe un error
+	err := s.request(lspPending{client: c, id: id, method: "initialize", callback: func(resp lspRaw) {
+		s.mu.Lock()
+		if e, failed := resp["error"]; failed && string(e) != "null" {
+			s.initParams = nil
+			s.initResult = nil
+			s.markReady()
+			s.ready = make(chan struct{})
+		} else if s.ready == ready {
+			s.initResult = resp["result"]
+			s.markReady()
+		}
+		s.mu.Unlock()
+		resp["id"] = id
+		out, _ := json.Marshal(resp)
+		c.deliver(out)
+	}}, json.RawMessage(raw))
+	if err != nil {
+		s.mu.Lock()
+		s.initParams = nil
+		s.mu.Unlock()
+		c.deliver(lspErrorResponse(id, err.Error()))
+	}
+}
+
+// trackDocument mantiene el texto de los documentos abiertos para reabrirlos tras un reinicio y
+// comparar con las escrituras de /files. Devuelve si hay que reenviar la notificación.
+func (s *languageServer) trackDocument(c *lspClient, method string, raw json.RawMessage) bool {
+	var params struct {
+		TextDocument struct {
+			URI        string `json:"uri"`
+			LanguageID string `json:"languageId"`
+			Version    int    `json:"version"`
+			Text       string `json:"text"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Range *lspRange `json:"range"`
+			Text  string    `json:"text"`
+		} `json:"contentChanges"`
+	}
+	if json.Unmarshal(raw, &params) != nil {
+		return true
+	}
+	uri := params.TextDocument.URI
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc := s.docs[uri]
+	switch method {
+	case "textDocument/didOpen":
+		if c.docs[uri] {
+			return false
+		}
+		c.docs[uri] = true
+		if doc != nil {
+			doc.refs++
+			return false
+		}
+		s.docs[uri] = &lspDocument{
+			path:       uriPath(uri),
+			languageID: params.TextDocument.LanguageID,
+			version:    params.TextDocument.Version,
+			text:       params.TextDocument.Text,
+			refs:       1,
+		}
+	case "textDocument/didChange":
+		if doc == nil {
+			return true
+		}
+		for _, change := range params.ContentChanges {
+			if change.Range == nil {
+				doc.text = change.Text
+				continue
+			}
+			start, end := lspOffset(doc.text, change.Range.Start), lspOffset(doc.text, change.Range.End)
+			if end < start {
+				start, end = end, start
+			}
+			doc.text = doc.text[:start] + change.Text + doc.text[end:]
+		}
+		doc.version = params.TextDocument.Version
+	case "textDocument/didClose":
+		if !c.docs[uri] {
+			return false
+		}
+		delete(c.docs, uri)
+		if doc == nil {
+			return true
+		}
+		if doc.refs--; doc.refs > 0 {
+			return false
+		}
+		delete(s.docs, uri)
+	}
+	return true
+}
+
+// detach desconecta un editor y cierra los documentos que solo tenía él
+func (s *languageServer) detach(c *lspClient) {
+	c.close()
+	languageServersMu.Lock()
+	s.mu.Lock()
+	if !s.clients[c] {
+		s.mu.Unlock()
+		languageServersMu.Unlock()
+		return
+	}
+	delete(s.clients, c)
+	if s.lastClient == c {
+		s.lastClient = nil
+		for other := range s.clients {
+			s.lastClient = other
+		}
+	}
+	var closed []string
+	for uri := range c.docs {
+		if doc := s.docs[uri]; doc != nil {
+			if doc.refs--; doc.refs <= 0 {
+				delete(s.docs, uri)
+				closed = append(closed, uri)
+			}
+		}
+	}
+	if len(s.clients) == 0 && s.idleTimer == nil {
+		s.idleTimer = time.AfterFunc(lspIdleTimeout, func() {
+			languageServersMu.Lock()
+			s.mu.Lock()
+			idle := len(s.clients) == 0
+			s.mu.Unlock()
+			if idle && languageServers[s.key] == s {
+				delete(languageServers, s.key)
+			}
+			languageServersMu.Unlock()
+			if idle {
+				fmt.Printf("[BACK] Stopping idle language server %s for %s\n", s.name(), s.root)
+				s.stop()
+			}
+		})
+	}
+	s.mu.Unlock()
+	languageServersMu.Unlock()
+	for _, uri := range closed {
+		s.notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]string{"uri": uri}})
+	}
+}
+
+// wait espera al proceso y lo reinicia si se cayó con editores conectados
+func (s *languageServer) wait(cmd *exec.Cmd, gen int, exited chan struct{}) {
+	waitErr := cmd.Wait()
+	close(exited)
+	s.mu.Lock()
+	if gen != s.generation {
+		s.mu.Unlock()
+		return
+	}
+	s.stdin = nil
+	pending := s.pending
+	s.pending = map[int64]lspPending{}
+	requested := s.restartRequested
+	s.restartRequested = false
+	s.initialized = false
+	if s.initResult == nil {
+		s.initParams = nil
+	}
+	// Despertar a quien esperaba al proceso anterior; seguirán esperando al nuevo
+	s.markReady()
+	s.ready = make(chan struct{})
+	name := s.name()
+	reason := "exited"
+	if waitErr != nil {
+		reason = waitErr.Error()
+	}
+	giveUp := ""
+	switch {
+	case s.stopping:
+		s.status = "stopped"
+	case requested:
+		s.status = "restarting"
+	case len(s.clients) == 0:
+		s.status = "exited"
+		s.initParams, s.initResult = nil, nil
+	default:
+		now := time.Now()
+		recent := s.crashes[:0]
+		for _, t := range s.crashes {
+			if now.Sub(t) < lspRestartWindow {
+				recent = append(recent, t)
+			}
+		}
+		s.crashes = append(recent, now)
+		if len(s.crashes) > lspMaxRestarts {
+			s.status = "failed"
+			giveUp = fmt.Sprintf("%s crashed %d times in %v; not restarting it (%s)", name, len(s.crashes), lspRestartWindow, reason)
+		} else {
+			s.status = "restarting"
+		}
+	}
+	status := s.status
+	crashes := len(s.crashes)
+	s.mu.Unlock()
+
+	for _, p := range pending {
+		if p.client != nil {
+			p.client.deliver(lspErrorResponse(p.id, name+" exited before answering "+p.method))
+		}
+	}
+	if status != "restarting" {
+		if giveUp != "" {
+			fmt.Println("[BACK] " + giveUp)
+			s.showMessage(1, giveUp)
+		}
+		return
+	}
+	if !requested {
+		fmt.Printf("[BACK] Language server %s for %s exited unexpectedly (%s), restarting\n", name, s.root, reason)
+		s.showMessage(2, fmt.Sprintf("%s exited unexpectedly (%s); restarting", name, reason))
+		time.Sleep(time.Duration(crashes) * time.Second)
+	}
+	s.mu.Lock()
+	s.restarts++
+	s.mu.Unlock()
+	if err := s.spawn(); err != nil {
+		s.mu.Lock()
+		s.status = "failed"
+		s.mu.Unlock()
+		s.showMessage(1, "Could not restart "+name+": "+err.Error())
+		return
+	}
+	s.reinitialize()
+}
+
+// reinitialize repite el initialize del editor y vuelve a abrir los documentos en el proceso nuevo
+func (s *languageServer) reinitialize() {
+	s.mu.Lock()
+	params := s.initParams
+	ready := s.ready
+	s.mu.Unlock()
+	if params == nil {
+		// Todavía ningún editor lo había inicializado
+		return
+	}
+	s.call("initialize", params, func(resp lspRaw) {
+		s.mu.Lock()
+		if e, failed := resp["error"]; !failed || string(e) == "null" {
+			s.initResult = resp["result"]
+		}
+		s.initialized = true
+		var docs []map[string]interface{}
+		for uri, doc := range s.docs {
+			docs = append(docs, map[string]interface{}{
+				"uri":        uri,
+				"languageId": doc.languageID,
+				"version":    doc.version,
+				"text":       doc.text,
+			})
+		}
+		s.mu.Unlock()
+		s.notify("initialized", map[string]interface{}{})
+		for _, doc := range docs {
+			s.notify("textDocument/didOpen", map[string]interface{}{"textDocument": doc})
+		}
+		s.mu.Lock()
+		if s.ready == ready {
+			s.markReady()
+		}
+		s.mu.Unlock()
+		s.showMessage(3, s.name()+" restarted")
+	})
+}
+
+// restart mata el proceso; wait lo vuelve a arrancar sin contarlo como caída
+func (s *languageServer) restart() error {
+	s.mu.Lock()
+	if s.cmd == nil || s.stdin == nil {
+		s.mu.Unlock()
+		return errors.New("language server is not running")
+	}
+	s.restartRequested = true
+	pid := s.cmd.Process.Pid
+	s.mu.Unlock()
+	return signalProcessGroup(pid, syscall.SIGKILL)
+}
+
+// stop pide shutdown/exit al servidor y lo mata si no termina a tiempo
+func (s *languageServer) stop() {
+	s.mu.Lock()
+	s.stopping = true
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+		s.idleTimer = nil
+	}
+	clients := make([]*lspClient, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	running := s.cmd != nil && s.stdin != nil
+	var pid int
+	var exited chan struct{}
+	if running {
+		pid = s.cmd.Process.Pid
+		exited = s.exited
+	} else {
+		s.status = "stopped"
+	}
+	s.mu.Unlock()
+	for _, c := range clients {
+		c.close()
+	}
+	if !running {
+		return
+	}
+	done := make(chan struct{})
+	if s.call("shutdown", nil, func(lspRaw) { close(done) }) == nil {
+		select {
+		case <-done:
+		case <-time.After(lspStopTimeout):
+		}
+		s.notify("exit", nil)
+	}
+	select {
+	case <-exited:
+	case <-time.After(lspStopTimeout):
+		signalProcessGroup(pid, syscall.SIGKILL)
+	}
+}
+
+// stopLanguageServers detiene todos los servidores (al cerrar el backend)
+func stopLanguageServers() {
+	languageServersMu.Lock()
+	servers := make([]*languageServer, 0, len(languageServers))
+	for key, s := range languageServers {
+		servers = append(servers, s)
+		delete(languageServers, key)
+	}
+	languageServersMu.Unlock()
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s *languageServer) {
+			defer wg.Done()
+			s.stop()
+		}(s)
+	}
+	wg.Wait()
+}
+
+// notifyLanguageServers avisa a los servidores de los cambios hechos en disco desde el backend (/files,
+// batch, replace, historial). Si un documento abierto en el editor ya no coincide con el disco, se avisa
+// a los editores con $/airide/documentChangedOnDisk para que lo recarguen y envíen el didChange.
+func notifyLanguageServers(kind int, paths ...string) {
+	languageServersMu.Lock()
+	servers := make([]*languageServer, 0, len(languageServers))
+	for _, s := range languageServers {
+		servers = append(servers, s)
+	}
+	languageServersMu.Unlock()
+	for _, s := range servers {
+		var changes []map[string]interface{}
+		for _, p := range paths {
+			if p != "" && isSubPath(s.root, p) {
+				changes = append(changes, map[string]interface{}{"uri": fileURI(p), "type": kind})
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		s.mu.Lock()
+		active := s.status == "running" && s.initialized
+		type openDoc struct{ uri, path, text string }
+		var affected []openDoc
+		for uri, doc := range s.docs {
+			for _, p := range paths {
+				if p != "" && isSubPath(p, doc.path) {
+					affected = append(affected, openDoc{uri, doc.path, doc.text})
+					break
+				}
+			}
+		}
+		s.mu.Unlock()
+		if !active {
+			continue
+		}
+		s.notify("workspace/didChangeWatchedFiles", map[string]interface{}{"changes": changes})
+		for _, doc := range affected {
+			data, err := os.ReadFile(doc.path)
+			deleted := os.IsNotExist(err)
+			if err == nil && normalizeDocumentText(string(data)) == normalizeDocumentText(doc.text) {
+				continue
+			}
+			if err != nil && !deleted {
+				continue
+			}
+			msg, _ := json.Marshal(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"method":  "$/airide/documentChangedOnDisk",
+				"params":  map[string]interface{}{"uri": doc.uri, "deleted": deleted},
+			})
+			s.broadcast(msg)
+		}
+	}
+}
+
+// normalizeDocumentText ignora las diferencias que introduce el guardado (CRLF y salto final)
+func normalizeDocumentText(text string) string {
+	return strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+type LanguageServerInfo struct {
+	Language      string     `json:"language"`
+	Languages     []string   `json:"languages"`
+	Available     bool       `json:"available"`
+	Command       string     `json:"command,omitempty"`
+	Message       string     `json:"message,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	Pid           int        `json:"pid,omitempty"`
+	Started       *time.Time `json:"started,omitempty"`
+	Clients       int        `json:"clients,omitempty"`
+	OpenDocuments int        `json:"openDocuments,omitempty"`
+	Restarts      int        `json:"restarts,omitempty"`
+	Stderr        string     `json:"stderr,omitempty"`
+}
+
+type LanguageServerRequest struct {
+	Operation      string `json:"operation"` // "restart" o "stop"
+	Language       string `json:"language"`
+	ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+}
+
+type LanguageServerResponse struct {
+	Success bool                 `json:"success"`
+	Message string               `json:"message"`
+	Servers []LanguageServerInfo `json:"servers,omitempty"`
+}
+
+func (s *languageServer) info() LanguageServerInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := LanguageServerInfo{
+		Language:      s.spec.ID,
+		Languages:     s.spec.Languages,
+		Available:     true,
+		Command:       s.command,
+		Status:        s.status,
+		Clients:       len(s.clients),
+		OpenDocuments: len(s.docs),
+		Restarts:      s.restarts,
+		Stderr:        string(trimToRuneBoundary(s.stderr)),
+	}
+	if s.cmd != nil && s.stdin != nil {
+		info.Pid = s.cmd.Process.Pid
+		started := s.started
+		info.Started = &started
+	}
+	return info
+}
+
+// listLanguageServers devuelve cada lenguaje soportado con su ejecutable y el estado del servidor del workspace
+func listLanguageServers(root string) []LanguageServerInfo {
+	pathList := buildCommandEnv(root, nil).get("PATH")
+	var list []LanguageServerInfo
+	for i := range languageServerSpecs {
+		spec := &languageServerSpecs[i]
+		languageServersMu.Lock()
+		s := languageServers[root+"\x00"+spec.ID]
+		languageServersMu.Unlock()
+		if s != nil {
+			list = append(list, s.info())
+			continue
+		}
+		info := LanguageServerInfo{Language: spec.ID, Languages: spec.Languages}
+		if path, _, err := resolveLanguageServer(spec, pathList); err != nil {
+			info.Message = err.Error()
+		} else {
+			info.Available = true
+			info.Command = path
+		}
+		list = append(list, info)
+	}
+	return list
+}
+
+// languageServersHandler lista los servidores (GET) y permite reiniciarlos o detenerlos (POST)
+func languageServersHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var resp LanguageServerResponse
+	switch r.Method {
+	case "GET":
+		root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+		resp = LanguageServerResponse{Success: true, Message: "OK", Servers: listLanguageServers(root)}
+	case "POST":
+		var req LanguageServerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		spec := findLanguageServerSpec(req.Language)
+		if spec == nil {
+			resp = LanguageServerResponse{Success: false, Message: "Unsupported language: " + req.Language}
+			break
+		}
+		root := workspaceRoot(req.ProjectBaseDir)
+		key := root + "\x00" + spec.ID
+		languageServersMu.Lock()
+		s := languageServers[key]
+		if s != nil && req.Operation == "stop" {
+			delete(languageServers, key)
+		}
+		languageServersMu.Unlock()
+		if s == nil {
+			resp = LanguageServerResponse{Success: false, Message: "No language server running for " + spec.ID}
+			break
+		}
+		switch req.Operation {
+		case "restart":
+			if err := s.restart(); err != nil {
+				resp = LanguageServerResponse{Success: false, Message: err.Error()}
+			} else {
+				resp = LanguageServerResponse{Success: true, Message: "Language server restarting"}
+			}
+		case "stop":
+			s.stop()
+			resp = LanguageServerResponse{Success: true, Message: "Language server stopped"}
+		default:
+			resp = LanguageServerResponse{Success: false, Message: "Unknown language server operation: " + req.Operation}
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// languageServerWebSocketHandler conecta Monaco con el servidor del lenguaje (?language=go&projectBaseDir=...).
+// Cada mensaje de texto es un mensaje JSON-RPC completo, sin cabeceras Content-Length.
+func languageServerWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	spec := findLanguageServerSpec(q.Get("language"))
+	if spec == nil {
+		http.Error(w, "Unsupported language: "+q.Get("language"), http.StatusBadRequest)
+		return
+	}
+	root := workspaceRoot(q.Get("projectBaseDir"))
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		http.Error(w, "Workspace not found: "+root, http.StatusNotFound)
+		return
+	}
+	s, c, err := attachLanguageServer(root, spec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer s.detach(c)
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Println("[BACK] WebSocket upgrade failed:", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(lspMaxMessage)
+
+	go func() {
+		defer c.close()
+		for {
+			kind, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if kind == websocket.TextMessage || kind == websocket.BinaryMessage {
+				s.fromClient(c, msg)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case msg := <-c.send:
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-c.done:
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestLspOffset(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		line, col int
+		want      int
+	}{
+		{"start", "abc\ndef", 0, 0, 0},
+		{"middle of first line", "abc\ndef", 0, 2, 2},
+		{"second line", "abc\ndef", 1, 1, 5},
+		{"past end of line", "abc\ndef", 0, 10, 3},
+		{"past end of crlf line", "abc\r\ndef", 0, 10, 3},
+		{"after crlf", "abc\r\ndef", 1, 2, 7},
+		{"past last line", "abc\ndef", 5, 0, 7},
+		{"end of text", "abc", 0, 3, 3},
+		{"empty line", "a\n\nb", 1, 4, 2},
+		{"two-byte runes", "ñañ\nx", 0, 2, 3},
+		{"three-byte runes", "日本語", 0, 2, 6},
+		{"astral rune counts as two units", "😀x", 0, 2, 4},
+		{"after astral rune", "😀x", 0, 3, 5},
+		{"inside surrogate pair rounds up", "😀x", 0, 1, 4},
+		{"astral on second line", "a\n😀😀b", 1, 4, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lspOffset(tt.text, lspPosition{Line: tt.line, Character: tt.col}); got != tt.want {
+				t.Errorf("lspOffset(%q, %d:%d) = %d, want %d", tt.text, tt.line, tt.col, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLspOffsetAppliesIncrementalEdits(t *testing.T) {
+	// Misma aplicación que textDocument/didChange: reemplazar el rango por el texto nuevo
+	edits := []struct {
+		start, end lspPosition
+		text       string
+	}{
+		{lspPosition{0, 2}, lspPosition{0, 2}, "😀"},    // "ab😀c\r\nd"
+		{lspPosition{0, 4}, lspPosition{1, 0}, "-"},    // "ab😀-d"
+		{lspPosition{0, 0}, lspPosition{0, 99}, "x\n"}, // "x\n"
+	}
+	text := "abc\r\nd"
+	want := []string{"ab😀c\r\nd", "ab😀-d", "x\n"}
+	for i, e := range edits {
+		start, end := lspOffset(text, e.start), lspOffset(text, e.end)
+		text = text[:start] + e.text + text[end:]
+		if text != want[i] {
+			t.Fatalf("after edit %d text = %q, want %q", i, text, want[i])
+		}
+	}
+}
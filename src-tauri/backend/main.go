@@ -1,6 +1,9 @@
 package main
 
 import (
+    "context"
+    "errors"
+    "encoding/base64"
     "fmt"
     "io/ioutil"
     "net/http"
@@ -46,15 +49,75 @@ type FileOperation struct {
     Content        string `json:"content,omitempty"`
     NewPath        string `json:"newPath,omitempty"`
     ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+    // Versión que el editor cargó; si el archivo cambió en disco desde entonces la escritura se rechaza
+    ExpectedVersion string `json:"expectedVersion,omitempty"`
+    // Encoding es el charset del archivo (utf-8, utf-16le, shift_jis, iso-8859-1...); vacío = detectar
+    Encoding string `json:"encoding,omitempty"`
+    // ContentEncoding "base64" indica que Content trae bytes binarios codificados
+    ContentEncoding string `json:"contentEncoding,omitempty"`
+    // Lecturas parciales: readRange usa Offset/Length (bytes), readLines usa StartLine/LineCount
+    Offset    int64 `json:"offset,omitempty"`
+    Length    int64 `json:"length,omitempty"`
+    StartLine int   `json:"startLine,omitempty"`
+    LineCount int   `json:"lineCount,omitempty"`
+    // Recursive + ConfirmToken autorizan borrar un directorio con contenido; Overwrite permite pisar el destino de copy/move
+    Recursive    bool   `json:"recursive,omitempty"`
+    ConfirmToken string `json:"confirmToken,omitempty"`
+    Overwrite    bool   `json:"overwrite,omitempty"`
+    // Permanent borra sin pasar por la papelera
+    Permanent bool `json:"permanent,omitempty"`
+}
+
+// workspaceRoot devuelve el directorio base del proyecto: el projectBaseDir enviado por el frontend o, si no hay, el projectRoot
+func workspaceRoot(projectBaseDir string) string {
+    if projectBaseDir != "" {
+        return filepath.Clean(projectBaseDir)
+    }
+    return projectRoot
+}
+
+// resolveWorkspacePath convierte una ruta relativa en absoluta dentro del workspace
+func resolveWorkspacePath(path, projectBaseDir string) string {
+    if filepath.IsAbs(path) {
+        return filepath.Clean(path)
+    }
+    return filepath.Join(workspaceRoot(projectBaseDir), path)
 }
-// Variable global temporal para pasar el projectBaseDir entre handler y función
-var currentFileOpProjectBaseDir = ""
 
 type FileResponse struct {
     Success bool   `json:"success"`
     Message string `json:"message"`
     Content string `json:"content,omitempty"`
     Files   []FileInfo `json:"files,omitempty"`
+    Version  string     `json:"version,omitempty"`
+    Modified *time.Time `json:"modified,omitempty"`
+    // Formato detectado del archivo leído ("lf" o "crlf") y si tenía BOM UTF-8
+    LineEnding string `json:"lineEnding,omitempty"`
+    BOM        bool   `json:"bom,omitempty"`
+    // Encoding es el charset original del archivo; los binarios llegan en base64 con su MimeType
+    Encoding        string `json:"encoding,omitempty"`
+    ContentEncoding string `json:"contentEncoding,omitempty"`
+    MimeType        string `json:"mimeType,omitempty"`
+    Binary          bool   `json:"binary,omitempty"`
+    // Datos de lecturas parciales y del límite de tamaño
+    Size       int64 `json:"size,omitempty"`
+    Offset     int64 `json:"offset,omitempty"`
+    NextOffset int64 `json:"nextOffset,omitempty"`
+    EOF        bool  `json:"eof,omitempty"`
+    StartLine  int   `json:"startLine,omitempty"`
+    LineCount  int   `json:"lineCount,omitempty"`
+    TooLarge   bool  `json:"tooLarge,omitempty"`
+    // TruncatedLines indica que readLines recortó alguna línea más larga que maxLineLength
+    TruncatedLines bool `json:"truncatedLines,omitempty"`
+    // ConfirmationToken se devuelve al intentar borrar un directorio no vacío
+    ConfirmationToken string    `json:"confirmationToken,omitempty"`
+    Stat              *FileStat `json:"stat,omitempty"`
+    // TrashID identifica el elemento borrado en la papelera (ver /api/trash)
+    TrashID string `json:"trashId,omitempty"`
+    // Conflict indica que el archivo cambió en disco; DiskContent/DiskVersion traen lo que hay ahora
+    Conflict    bool   `json:"conflict,omitempty"`
+    DiskContent string `json:"diskContent,omitempty"`
+    DiskVersion string `json:"diskVersion,omitempty"`
 }
 
 type FileInfo struct {
@@ -68,6 +131,17 @@ type FileInfo struct {
 type TerminalRequest struct {
     Command string `json:"command"`
     WorkingDir string `json:"workingDir,omitempty"`
+    // Límites del comando; si no se envían se usan los de .airide/settings.json
+    TimeoutSeconds int    `json:"timeoutSeconds,omitempty"`
+    MaxOutputKB    int    `json:"maxOutputKB,omitempty"`
+    ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+    // Source indica quién lanzó el comando ("user" o "ai") para la política y la auditoría
+    Source       string `json:"source,omitempty"`
+    ConfirmToken string `json:"confirmToken,omitempty"`
+    // Env sobrescribe variables del entorno solo para este comando
+    Env map[string]string `json:"env,omitempty"`
+    // SessionID ejecuta el comando en una sesión de /terminal/sessions, que recuerda directorio y variables
+    SessionID string `json:"sessionId,omitempty"`
 }
 
 type TerminalResponse struct {
@@ -75,6 +149,21 @@ type TerminalResponse struct {
     Output  string `json:"output"`
     Error   string `json:"error,omitempty"`
     Message string `json:"message,omitempty"`
+    ExitCode   int   `json:"exitCode"`
+    DurationMs int64 `json:"durationMs"`
+    // TimedOut indica que el comando se mató por superar el tiempo máximo; Truncated que la salida se recortó
+    TimedOut  bool `json:"timedOut,omitempty"`
+    Truncated bool `json:"truncated,omitempty"`
+    // Denied indica que la política del workspace bloqueó el comando; si hace falta confirmación llega ConfirmationToken
+    Denied               bool   `json:"denied,omitempty"`
+    ConfirmationRequired bool   `json:"confirmationRequired,omitempty"`
+    ConfirmationToken    string `json:"confirmationToken,omitempty"`
+    // Problems son los errores y avisos reconocidos en la salida (ver /api/problems)
+    Problems []Diagnostic `json:"problems,omitempty"`
+    // Cwd es el directorio en el que quedó la sesión después del comando
+    Cwd string `json:"cwd,omitempty"`
+    // SessionStateLost indica que el comando no dejó su estado y la sesión conserva el directorio y entorno anteriores
+    SessionStateLost bool `json:"sessionStateLost,omitempty"`
 }
 
 var openaiClient OpenAIClient
@@ -82,7 +171,7 @@ var openaiClient OpenAIClient
 func enableCORS(w http.ResponseWriter) {
     w.Header().Set("Access-Control-Allow-Origin", "*")
     w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-    w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+    w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+uiTokenHeader)
 }
 
 func handleOptions(w http.ResponseWriter, r *http.Request) {
@@ -109,96 +198,93 @@ func chatHandler(w http.ResponseWriter, r *http.Request) {
     }
     fmt.Printf("[BACK] ChatRequest: %+v\n", req)
     // Selección de modelo por provider y nombre
+    response, status, err := chatCompletion(req.Provider, req.ApiKey, req.Model, req.Message)
+    if err != nil {
+        http.Error(w, err.Error(), status)
+        return
+    }
+    chatResponse := ChatResponse{
+        Response:  response,
+        Timestamp: time.Now(),
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(chatResponse)
+}
+
+// chatCompletion envía un mensaje al proveedor y modelo elegidos en el frontend; si falla devuelve también
+// el código HTTP con el que responder
+func chatCompletion(provider, apiKey, model, message string) (string, int, error) {
     var response string
     var err error
-    switch req.Provider {
+    switch provider {
     case "OpenAI":
-        if req.ApiKey == "" {
-            http.Error(w, "OpenAI API key not provided.", http.StatusUnauthorized)
-            return
+        if apiKey == "" {
+            return "", http.StatusUnauthorized, errors.New("OpenAI API key not provided.")
         }
-        model := req.Model
         if model == "" {
             model = "gpt-3.5-turbo"
         }
-        client := NewOpenAIClient(req.ApiKey, model)
-        messages := []OpenAIMessage{{Role: "user", Content: req.Message}}
+        client := NewOpenAIClient(apiKey, model)
+        messages := []OpenAIMessage{{Role: "user", Content: message}}
         response, err = client.ChatCompletion(messages)
     case "Anthropic":
-        if req.ApiKey == "" {
-            http.Error(w, "Claude API key not provided.", http.StatusUnauthorized)
-            return
+        if apiKey == "" {
+            return "", http.StatusUnauthorized, errors.New("Claude API key not provided.")
         }
-        model := req.Model
         if model == "" {
             model = "claude-sonnet-4-20250514"
         }
         version := "2023-06-01"
-        client := NewClaudeClient(req.ApiKey, model, version)
-        messages := []ClaudeMessage{{Role: "user", Content: req.Message}}
+        client := NewClaudeClient(apiKey, model, version)
+        messages := []ClaudeMessage{{Role: "user", Content: message}}
         response, err = client.ChatCompletion(messages)
     case "DeepSeek":
-        if req.ApiKey == "" {
-            http.Error(w, "DeepSeek API key not provided.", http.StatusUnauthorized)
-            return
+        if apiKey == "" {
+            return "", http.StatusUnauthorized, errors.New("DeepSeek API key not provided.")
         }
-        model := req.Model
         if model == "" {
             model = "deepseek-chat"
         }
-        client := NewDeepSeekClient(req.ApiKey, model)
-        messages := []DeepSeekMessage{{Role: "user", Content: req.Message}}
+        client := NewDeepSeekClient(apiKey, model)
+        messages := []DeepSeekMessage{{Role: "user", Content: message}}
         response, err = client.ChatCompletion(messages)
     case "DeepSeekOpenRoute":
-        if req.ApiKey == "" {
-            http.Error(w, "DeepSeekOpenRoute API key not provided.", http.StatusUnauthorized)
-            return
+        if apiKey == "" {
+            return "", http.StatusUnauthorized, errors.New("DeepSeekOpenRoute API key not provided.")
         }
-        model := req.Model
         if model == "" {
             model = "deepseek/deepseek-chat-v3-0324:free"
         }
-        client := NewDeepSeekOpenRouteClient(req.ApiKey, model)
-        messages := []DeepSeekOpenRouteMessage{{Role: "user", Content: req.Message}}
+        client := NewDeepSeekOpenRouteClient(apiKey, model)
+        messages := []DeepSeekOpenRouteMessage{{Role: "user", Content: message}}
         response, err = client.ChatCompletion(messages)
     case "Qwen3_32BOpenRoute":
-        if req.ApiKey == "" {
-            http.Error(w, "Qwen3_32BOpenRoute API key not provided.", http.StatusUnauthorized)
-            return
+        if apiKey == "" {
+            return "", http.StatusUnauthorized, errors.New("Qwen3_32BOpenRoute API key not provided.")
         }
-        model := req.Model
         if model == "" {
             model = "qwen/qwen3-32b:free"
         }
-        client := NewQwen3_32BOpenRouteClient(req.ApiKey, model)
-        messages := []Qwen3_32BOpenRouteMessage{{Role: "user", Content: req.Message}}
+        client := NewQwen3_32BOpenRouteClient(apiKey, model)
+        messages := []Qwen3_32BOpenRouteMessage{{Role: "user", Content: message}}
         response, err = client.ChatCompletion(messages)
     case "MistralNemoOpenRoute":
-        if req.ApiKey == "" {
-            http.Error(w, "MistralNemoOpenRoute API key not provided.", http.StatusUnauthorized)
-            return
+        if apiKey == "" {
+            return "", http.StatusUnauthorized, errors.New("MistralNemoOpenRoute API key not provided.")
         }
-        model := req.Model
         if model == "" {
             model = "mistralai/mistral-nemo:free"
         }
-        client := NewMistralNemoOpenRouteClient(req.ApiKey, model)
-        messages := []MistralNemoOpenRouteMessage{{Role: "user", Content: req.Message}}
+        client := NewMistralNemoOpenRouteClient(apiKey, model)
+        messages := []MistralNemoOpenRouteMessage{{Role: "user", Content: message}}
         response, err = client.ChatCompletion(messages)
     default:
-        http.Error(w, "Unsupported or missing provider.", http.StatusBadRequest)
-        return
+        return "", http.StatusBadRequest, errors.New("Unsupported or missing provider.")
     }
     if err != nil {
-        http.Error(w, req.Provider+" error: "+err.Error(), http.StatusInternalServerError)
-        return
+        return "", http.StatusInternalServerError, errors.New(provider + " error: " + err.Error())
     }
-    chatResponse := ChatResponse{
-        Response:  response,
-        Timestamp: time.Now(),
-    }
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(chatResponse)
+    return response, http.StatusOK, nil
 }
 
 func generateAIResponse(message, context string) string {
@@ -241,54 +327,109 @@ func fileHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
     fmt.Printf("[BACK] FileOperation: %+v\n", req)
-    // Guardar el projectBaseDir para la operación actual
-    currentFileOpProjectBaseDir = req.ProjectBaseDir
+    // La configuración del workspace (política, ajustes, auditoría) solo la modifica la interfaz
+    switch req.Operation {
+    case "write", "create", "delete", "rename", "mkdir", "copy", "move":
+        if !isUIRequest(r) && (isWorkspaceConfigPath(resolveWorkspacePath(req.Path, req.ProjectBaseDir)) ||
+            (req.NewPath != "" && isWorkspaceConfigPath(resolveWorkspacePath(req.NewPath, req.ProjectBaseDir)))) {
+            w.Header().Set("Content-Type", "application/json")
+            w.WriteHeader(http.StatusForbidden)
+            json.NewEncoder(w).Encode(FileResponse{Success: false, Message: workspaceConfigReadOnly})
+            return
+        }
+    }
     var resp FileResponse
     switch req.Operation {
     case "read":
-        resp = readFile(req.Path)
+        resp = readFile(req.ProjectBaseDir, req.Path, req.Encoding)
+    case "readRange":
+        resp = readFileRange(req.ProjectBaseDir, req.Path, req.Offset, req.Length)
+    case "readLines":
+        resp = readFileLines(req.ProjectBaseDir, req.Path, req.StartLine, req.LineCount)
     case "write":
-        resp = writeFile(req.Path, req.Content)
+        if req.ContentEncoding == "base64" {
+            resp = writeBinaryFile(req.ProjectBaseDir, req.Path, req.Content, req.ExpectedVersion)
+        } else {
+            resp = writeFile(req.ProjectBaseDir, req.Path, req.Content, req.ExpectedVersion, req.Encoding)
+        }
     case "create":
-        resp = createFile(req.Path, req.Content)
+        resp = createFile(req.ProjectBaseDir, req.Path, req.Content)
     case "delete":
-        resp = deleteFile(req.Path)
+        resp = deleteFile(req.ProjectBaseDir, req.Path, req.Recursive, req.ConfirmToken, req.Permanent)
     case "rename":
-        resp = renameFile(req.Path, req.NewPath)
+        resp = renameFile(req.ProjectBaseDir, req.Path, req.NewPath, req.Overwrite)
     case "list":
         resp = listFiles(req.Path)
+    case "mkdir":
+        resp = makeDirectory(req.ProjectBaseDir, req.Path)
+    case "copy":
+        resp = copyFileOrDir(req.ProjectBaseDir, req.Path, req.NewPath, req.Overwrite)
+    case "move":
+        resp = moveFileOrDir(req.ProjectBaseDir, req.Path, req.NewPath, req.Overwrite)
+    case "stat":
+        resp = statFile(req.ProjectBaseDir, req.Path)
     default:
         resp = FileResponse{
             Success: false,
             Message: "Unknown file operation: " + req.Operation,
         }
     }
-    // Limpiar la variable global después de la operación
-    currentFileOpProjectBaseDir = ""
+    // Crear, borrar, copiar o mover deja desactualizado el índice de quick open
+    switch req.Operation {
+    case "create", "delete", "rename", "mkdir", "copy", "move":
+        if resp.Success {
+            invalidateFileIndex(workspaceRoot(req.ProjectBaseDir))
+        }
+    }
+    // Los servidores de lenguaje abiertos también tienen que enterarse de lo que cambió en disco
+    if resp.Success {
+        path := resolveWorkspacePath(req.Path, req.ProjectBaseDir)
+        newPath := resolveWorkspacePath(req.NewPath, req.ProjectBaseDir)
+        switch req.Operation {
+        case "write":
+            notifyLanguageServers(lspFileChanged, path)
+        case "create", "mkdir":
+            notifyLanguageServers(lspFileCreated, path)
+        case "copy":
+            notifyLanguageServers(lspFileCreated, newPath)
+        case "delete":
+            notifyLanguageServers(lspFileDeleted, path)
+        case "rename", "move":
+            notifyLanguageServers(lspFileDeleted, path)
+            notifyLanguageServers(lspFileCreated, newPath)
+        }
+    }
     w.Header().Set("Content-Type", "application/json")
+    if resp.Conflict {
+        w.WriteHeader(http.StatusConflict)
+    }
     json.NewEncoder(w).Encode(resp)
 }
 
-func readFile(path string) FileResponse {
+func readFile(baseDir string, path string, encoding string) FileResponse {
     fmt.Printf("[BACK] readFile called with path: '%s'\n", path)
-    // Si el path es relativo, unirlo al projectBaseDir si está presente, si no al projectRoot
-    if !filepath.IsAbs(path) {
-        if currentFileOpProjectBaseDir != "" {
-            path = filepath.Join(currentFileOpProjectBaseDir, path)
-            fmt.Printf("[BACK] Resolved absolute path: '%s' (projectBaseDir: '%s')\n", path, currentFileOpProjectBaseDir)
-        } else {
-            path = filepath.Join(projectRoot, path)
-            fmt.Printf("[BACK] Resolved absolute path: '%s' (projectRoot: '%s')\n", path, projectRoot)
-        }
-    }
+    // Las rutas relativas se resuelven contra el projectBaseDir o, si no hay, el projectRoot
+    path = resolveWorkspacePath(path, baseDir)
     // Verificar si el archivo existe
-    if _, err := os.Stat(path); os.IsNotExist(err) {
+    info, err := os.Stat(path)
+    if os.IsNotExist(err) {
         fmt.Printf("[BACK] File does not exist: '%s'\n", path)
         return FileResponse{
             Success: false,
             Message: "File does not exist: " + path,
         }
     }
+    // Los archivos enormes no se cargan completos: el editor debe paginarlos con readRange/readLines
+    limit := maxReadSize(loadWorkspaceSettings(workspaceRoot(baseDir)))
+    if err == nil && info.Size() > limit {
+        fmt.Printf("[BACK] File too large to read at once: '%s' (%d bytes)\n", path, info.Size())
+        return FileResponse{
+            Success: false,
+            Message: fmt.Sprintf("File is too large to open (%.1f MB, limit %d MB); use readRange or readLines to page through it", float64(info.Size())/(1024*1024), limit/(1024*1024)),
+            Size: info.Size(),
+            TooLarge: true,
+        }
+    }
     content, err := ioutil.ReadFile(path)
     if err != nil {
         fmt.Printf("[BACK] Error reading file: %v\n", err)
@@ -297,6 +438,43 @@ func readFile(path string) FileResponse {
             Message: "Error reading file: " + err.Error(),
         }
     }
+    version := contentHash(content)
+    charset := normalizeCharset(encoding)
+    if encoding == "" {
+        charset = detectCharset(content)
+    }
+    mimeType := detectMimeType(path, content)
+    // Los binarios (imágenes, PDFs...) viajan en base64 para no corromperse en el JSON
+    if isBinaryFile(path, charset, content) {
+        fmt.Printf("[BACK] Read binary file: '%s' (%s, %d bytes)\n", path, mimeType, len(content))
+        return FileResponse{
+            Success: true,
+            Message: "File read successfully",
+            Content: base64.StdEncoding.EncodeToString(content),
+            ContentEncoding: "base64",
+            MimeType: mimeType,
+            Binary: true,
+            Version: version,
+            Modified: fileModTime(path),
+        }
+    }
+    text, err := decodeText(content, charset)
+    if err != nil {
+        return FileResponse{
+            Success: false,
+            Message: "Error decoding file as " + charset + ": " + err.Error(),
+        }
+    }
+    content = []byte(text)
+    format := detectTextFormat(content)
+    lineEnding := "lf"
+    if format.MixedEOL {
+        lineEnding = "mixed"
+    } else if format.CRLF {
+        lineEnding = "crlf"
+    }
+    // El BOM no se muestra en el editor; se vuelve a agregar al guardar según la configuración
+    content = bytes.TrimPrefix(content, utf8BOM)
     preview := string(content)
     if len(preview) > 80 {
         preview = preview[:80] + "..."
@@ -306,17 +484,22 @@ func readFile(path string) FileResponse {
         Success: true,
         Message: "File read successfully",
         Content: string(content),
+        Version: version,
+        Modified: fileModTime(path),
+        LineEnding: lineEnding,
+        BOM: format.BOM,
+        Encoding: charset,
+        MimeType: mimeType,
     }
 }
 
-func writeFile(path string, content string) FileResponse {
+func writeFile(baseDir string, path string, content string, expectedVersion string, encoding string) FileResponse {
     fmt.Printf("writeFile called with path: %s, content length: %d\n", path, len(content))
     
-    // Si el path es relativo, unirlo al projectRoot
-    if !filepath.IsAbs(path) {
-        path = filepath.Join(projectRoot, path)
-        fmt.Printf("[BACK] Resolved absolute path: '%s' (projectRoot: '%s')\n", path, projectRoot)
-    }
+    // Las rutas relativas se resuelven contra el projectBaseDir o, si no hay, el projectRoot
+    path = resolveWorkspacePath(path, baseDir)
+    // Nadie más puede escribir el archivo entre la comprobación de versión y la escritura
+    defer lockPaths(path)()
     
     // Crear el directorio si no existe
     dir := filepath.Dir(path)
@@ -328,7 +511,25 @@ func writeFile(path string, content string) FileResponse {
         }
     }
     
-    err := ioutil.WriteFile(path, []byte(content), 0644)
+    // Si el editor envió la versión que cargó, comprobar que nadie modificó el archivo desde entonces
+    if expectedVersion != "" {
+        if conflict, ok := checkFileVersion(path, expectedVersion); !ok {
+            return conflict
+        }
+    }
+    
+    // Conservar fin de línea, BOM y salto final del archivo en disco (o normalizarlos según .airide/settings.json)
+    // El archivo se vuelve a guardar en su charset original (o el que indique el editor)
+    settings := loadWorkspaceSettings(workspaceRoot(baseDir))
+    data, previous, charset, err := encodeForSave(path, content, encoding, settings.Files)
+    if err != nil {
+        return FileResponse{
+            Success: false,
+            Message: "Error encoding file: " + err.Error(),
+        }
+    }
+    
+    err = atomicWriteFile(path, data, 0644)
     if err != nil {
         return FileResponse{
             Success: false,
@@ -336,10 +537,108 @@ func writeFile(path string, content string) FileResponse {
         }
     }
     
+    // Historial local: la versión que había en disco (si cambió por fuera) y la recién guardada
+    root := workspaceRoot(baseDir)
+    if previous != nil {
+        recordHistorySnapshot(root, path, previous, "external")
+    }
+    recordHistorySnapshot(root, path, data, "save")
+    
+    resp := FileResponse{
+        Success: true,
+        Message: "File saved successfully",
+        Version: contentHash(data),
+        Encoding: charset,
+    }
+    resp.Modified = fileModTime(path)
+    return resp
+}
+
+// writeBinaryFile guarda bytes recibidos en base64 sin ninguna transformación de texto
+func writeBinaryFile(baseDir string, path string, content string, expectedVersion string) FileResponse {
+    path = resolveWorkspacePath(path, baseDir)
+    fmt.Printf("[BACK] writeBinaryFile called with path: '%s'\n", path)
+    data, err := base64.StdEncoding.DecodeString(content)
+    if err != nil {
+        return FileResponse{
+            Success: false,
+            Message: "Invalid base64 content: " + err.Error(),
+        }
+    }
+    defer lockPaths(path)()
+    if expectedVersion != "" {
+        if conflict, ok := checkFileVersion(path, expectedVersion); !ok {
+            return conflict
+        }
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return FileResponse{
+            Success: false,
+            Message: "Error creating directory: " + err.Error(),
+        }
+    }
+    previous, readErr := ioutil.ReadFile(path)
+    if err := atomicWriteFile(path, data, 0644); err != nil {
+        return FileResponse{
+            Success: false,
+            Message: "Error writing file: " + err.Error(),
+        }
+    }
+    root := workspaceRoot(baseDir)
+    if readErr == nil {
+        recordHistorySnapshot(root, path, previous, "external")
+    }
+    recordHistorySnapshot(root, path, data, "save")
     return FileResponse{
         Success: true,
         Message: "File saved successfully",
+        Version: contentHash(data),
+        Modified: fileModTime(path),
+        Binary: true,
+    }
+}
+
+// fileModTime devuelve la fecha de modificación del archivo, o nil si no se puede leer
+func fileModTime(path string) *time.Time {
+    info, err := os.Stat(path)
+    if err != nil {
+        return nil
+    }
+    modTime := info.ModTime()
+    return &modTime
+}
+
+// checkFileVersion compara la versión esperada con el contenido actual en disco; si difieren devuelve la respuesta de conflicto
+func checkFileVersion(path, expectedVersion string) (FileResponse, bool) {
+    diskContent, err := ioutil.ReadFile(path)
+    if err != nil && !os.IsNotExist(err) {
+        return FileResponse{
+            Success: false,
+            Message: "Error reading file: " + err.Error(),
+        }, false
+    }
+    if os.IsNotExist(err) {
+        fmt.Printf("[BACK] Write conflict: '%s' was deleted on disk\n", path)
+        return FileResponse{
+            Success:  false,
+            Message:  "File was deleted on disk since it was loaded",
+            Conflict: true,
+        }, false
+    }
+    diskVersion := contentHash(diskContent)
+    if diskVersion == expectedVersion {
+        return FileResponse{}, true
     }
+    fmt.Printf("[BACK] Write conflict: '%s' changed on disk (expected %s, found %s)\n", path, expectedVersion, diskVersion)
+    resp := FileResponse{
+        Success:     false,
+        Message:     "File changed on disk since it was loaded",
+        Conflict:    true,
+        DiskContent: string(diskContent),
+        DiskVersion: diskVersion,
+    }
+    resp.Modified = fileModTime(path)
+    return resp, false
 }
 
 func listFiles(dirPath string) FileResponse {
@@ -444,16 +743,23 @@ func listDirectoryContents(dirPath string) FileResponse {
     }
 }
 
-func createFile(path string, content string) FileResponse {
+func createFile(baseDir string, path string, content string) FileResponse {
     fmt.Printf("createFile called with path: %s, content length: %d\n", path, len(content))
     
-    // Si el path es relativo, unirlo al projectRoot
-    if !filepath.IsAbs(path) {
-        path = filepath.Join(projectRoot, path)
-        fmt.Printf("[BACK] Resolved absolute path: '%s' (projectRoot: '%s')\n", path, projectRoot)
+    // Las rutas relativas se resuelven contra el projectBaseDir o, si no hay, el projectRoot
+    path = resolveWorkspacePath(path, baseDir)
+    
+    // Crear los directorios padre que falten
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        fmt.Printf("Error creating directory: %v\n", err)
+        return FileResponse{
+            Success: false,
+            Message: "Error creating directory: " + err.Error(),
+        }
     }
     
-    err := ioutil.WriteFile(path, []byte(content), 0644)
+    settings := loadWorkspaceSettings(workspaceRoot(baseDir))
+    err := atomicWriteFile(path, formatTextForSave(content, nil, settings.Files), 0644)
     if err != nil {
         fmt.Printf("Error creating file: %v\n", err)
         return FileResponse{
@@ -470,13 +776,52 @@ func createFile(path string, content string) FileResponse {
     }
 }
 
-func deleteFile(path string) FileResponse {
-    if !filepath.IsAbs(path) {
-        path = filepath.Join(projectRoot, path)
-        fmt.Printf("[BACK] Resolved absolute path: '%s' (projectRoot: '%s')\n", path, projectRoot)
+func deleteFile(baseDir string, path string, recursive bool, confirmToken string, permanent bool) FileResponse {
+    path = resolveWorkspacePath(path, baseDir)
+    
+    info, err := os.Lstat(path)
+    if err != nil {
+        return FileResponse{
+            Success: false,
+            Message: "Error deleting file: " + err.Error(),
+        }
     }
     
-    err := os.Remove(path)
+    // Un directorio con contenido solo se borra con recursive y el token emitido en el primer intento
+    if info.IsDir() {
+        entries, _ := os.ReadDir(path)
+        if len(entries) > 0 && (!recursive || !consumeConfirmToken(confirmToken, path)) {
+            return FileResponse{
+                Success: false,
+                Message: fmt.Sprintf("Directory is not empty (%d entries); resend with recursive and confirmToken to delete it", len(entries)),
+                ConfirmationToken: newConfirmToken(path),
+            }
+        }
+    }
+    
+    // Por defecto se manda a la papelera del workspace para poder restaurarlo o deshacerlo
+    if !permanent {
+        root := workspaceRoot(baseDir)
+        id, err := moveToTrash(root, path)
+        if err != nil {
+            return FileResponse{
+                Success: false,
+                Message: "Error moving to trash: " + err.Error(),
+            }
+        }
+        recordUndo(root, undoEntry{Operation: "delete", Path: path, TrashID: id})
+        return FileResponse{
+            Success: true,
+            Message: "Moved to trash",
+            TrashID: id,
+        }
+    }
+    
+    if info.IsDir() {
+        err = os.RemoveAll(path)
+    } else {
+        err = os.Remove(path)
+    }
     if err != nil {
         return FileResponse{
             Success: false,
@@ -486,22 +831,16 @@ func deleteFile(path string) FileResponse {
     
     return FileResponse{
         Success: true,
-        Message: "File deleted successfully",
+        Message: "File deleted permanently",
     }
 }
 
-func renameFile(oldPath, newPath string) FileResponse {
+func renameFile(baseDir string, oldPath, newPath string, overwrite bool) FileResponse {
     fmt.Printf("renameFile called with oldPath: %s, newPath: %s\n", oldPath, newPath)
     
-    // Si los paths son relativos, unirlos al projectRoot
-    if !filepath.IsAbs(oldPath) {
-        oldPath = filepath.Join(projectRoot, oldPath)
-        fmt.Printf("[BACK] Resolved old absolute path: '%s' (projectRoot: '%s')\n", oldPath, projectRoot)
-    }
-    if !filepath.IsAbs(newPath) {
-        newPath = filepath.Join(projectRoot, newPath)
-        fmt.Printf("[BACK] Resolved new absolute path: '%s' (projectRoot: '%s')\n", newPath, projectRoot)
-    }
+    // Las rutas relativas se resuelven contra el projectBaseDir o, si no hay, el projectRoot
+    oldPath = resolveWorkspacePath(oldPath, baseDir)
+    newPath = resolveWorkspacePath(newPath, baseDir)
     
     // Verificar si el archivo original existe
     if _, err := os.Stat(oldPath); os.IsNotExist(err) {
@@ -512,9 +851,29 @@ func renameFile(oldPath, newPath string) FileResponse {
         }
     }
     
+    // No pisar un archivo existente salvo que se pida; en ese caso el reemplazado va a la papelera
+    root := workspaceRoot(baseDir)
+    replacedID := ""
+    if _, err := os.Lstat(newPath); err == nil && oldPath != newPath {
+        if !overwrite {
+            return FileResponse{
+                Success: false,
+                Message: "Destination already exists: " + newPath,
+            }
+        }
+        replacedID, err = moveToTrash(root, newPath)
+        if err != nil {
+            return FileResponse{
+                Success: false,
+                Message: "Error moving destination to trash: " + err.Error(),
+            }
+        }
+    }
+    
     err := os.Rename(oldPath, newPath)
     if err != nil {
         fmt.Printf("Error renaming file: %v\n", err)
+        restoreReplaced(root, replacedID)
         return FileResponse{
             Success: false,
             Message: "Error renaming file: " + err.Error(),
@@ -522,6 +881,7 @@ func renameFile(oldPath, newPath string) FileResponse {
     }
     
     fmt.Printf("Successfully renamed file from %s to %s\n", oldPath, newPath)
+    recordUndo(root, undoEntry{Operation: "rename", Path: oldPath, NewPath: newPath, ReplacedTrashID: replacedID})
     
     return FileResponse{
         Success: true,
@@ -551,9 +911,10 @@ func terminalHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
     
+    req.Source = requestSource(r, req.Source)
     fmt.Printf("Terminal request: command='%s', workingDir='%s'\n", req.Command, req.WorkingDir)
     
-    response := executeCommand(req.Command, req.WorkingDir)
+    response := executeCommand(req)
     
     fmt.Printf("Terminal response: success=%v, output length=%d, error length=%d\n", 
         response.Success, len(response.Output), len(response.Error))
@@ -562,7 +923,8 @@ func terminalHandler(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(response)
 }
 
-func executeCommand(command, workingDir string) TerminalResponse {
+func executeCommand(req TerminalRequest) TerminalResponse {
+    command, workingDir := req.Command, req.WorkingDir
     fmt.Printf("executeCommand called with command='%s', workingDir='%s'\n", command, workingDir)
 
     if command == "" {
@@ -573,92 +935,80 @@ func executeCommand(command, workingDir string) TerminalResponse {
         }
     }
 
-    // Determine shell based on OS and command
-    var shell string
-    var args []string
-
-    if runtime.GOOS == "windows" {
-        // Si el comando empieza con 'WSL ', usar bash/WSL
-        if strings.HasPrefix(command, "WSL ") {
-            shell = "/bin/bash"
-            args = []string{"-c", strings.TrimPrefix(command, "WSL ")}
-        } else {
-            // Siempre usar PowerShell en Windows
-            shell = "powershell.exe"
-            args = []string{"-Command", command}
-        }
-    } else {
-        shell = "/bin/bash"
-        args = []string{"-c", command}
+    if err := checkEnvOverrides(req.Env); err != nil {
+        return TerminalResponse{Success: false, Message: err.Error(), ExitCode: -1}
     }
-
-    fmt.Printf("Using shell: %s with args: %v\n", shell, args)
-
-    // Create command
-    cmd := exec.Command(shell, args...)
-
-    // Set working directory if provided
-    if workingDir != "" {
-        cmd.Dir = workingDir
-        fmt.Printf("Set working directory to: %s\n", workingDir)
+    session, err := acquireTerminalSession(req.SessionID)
+    if err != nil {
+        return TerminalResponse{Success: false, Message: err.Error(), ExitCode: -1}
     }
+    defer session.release()
+    req = session.apply(req)
+    workingDir = req.WorkingDir
 
-    // Set environment variables to ensure PATH is correct
-    env := os.Environ()
-
-    // Add common PATH entries for better command discovery
-    if runtime.GOOS == "windows" {
-        // For Windows, add common Python and other tool paths
-        additionalPaths := []string{
-            "C:\\Python39\\Scripts",
-            "C:\\Python39",
-            "C:\\Python310\\Scripts", 
-            "C:\\Python310",
-            "C:\\Python311\\Scripts",
-            "C:\\Python311",
-            "C:\\Users\\%USERNAME%\\AppData\\Local\\Programs\\Python\\Python39\\Scripts",
-            "C:\\Users\\%USERNAME%\\AppData\\Local\\Programs\\Python\\Python39",
-            "C:\\Users\\%USERNAME%\\AppData\\Local\\Programs\\Python\\Python310\\Scripts",
-            "C:\\Users\\%USERNAME%\\AppData\\Local\\Programs\\Python\\Python310",
-        }
-        for _, path := range additionalPaths {
-            env = append(env, "PATH="+os.Getenv("PATH")+";"+path)
-        }
-    } else {
-        // For Linux/WSL, add common paths
-        additionalPaths := []string{
-            "/usr/local/bin",
-            "/usr/bin",
-            "/bin",
-            "/usr/sbin",
-            "/sbin",
-            "/home/"+os.Getenv("USER")+"/.local/bin",
-            "/home/"+os.Getenv("USER")+"/.pyenv/bin",
-        }
-        for _, path := range additionalPaths {
-            env = append(env, "PATH="+os.Getenv("PATH")+":"+path)
+    root := terminalWorkspaceRoot(req)
+    auth := authorizeCommand(root, "terminal", req)
+    if !auth.Allowed {
+        return TerminalResponse{
+            Success: false,
+            Message: auth.Message,
+            ExitCode: -1,
+            Denied:   auth.Denied,
+            ConfirmationRequired: auth.ConfirmationToken != "",
+            ConfirmationToken:    auth.ConfirmationToken,
         }
     }
-    cmd.Env = env
 
-    // Capture output
-    var stdout, stderr bytes.Buffer
-    cmd.Stdout = &stdout
-    cmd.Stderr = &stderr
+    timeout, maxOutput := terminalLimits(req)
+    ctx := context.Background()
+    if timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, timeout)
+        defer cancel()
+    }
+    source := normalizeCommandSource(req.Source)
+    env := scrubEnv(session.commandEnv(root, req.Env, source), auth.policy, source)
+    shellCommand, stateFile := session.wrap(command)
+    cmd := buildShellCommand(ctx, shellCommand, workingDir, env)
+    killGroupOnCancel(cmd)
+
+    // Capture output (sin pasar del máximo configurado)
+    stdout := &cappedBuffer{limit: maxOutput}
+    stderr := &cappedBuffer{limit: maxOutput}
+    cmd.Stdout = stdout
+    cmd.Stderr = stderr
 
     // Execute command
-    fmt.Printf("Executing command...\n")
-    err := cmd.Run()
+    fmt.Printf("Executing command (timeout %v, max output %d bytes)...\n", timeout, maxOutput)
+    start := time.Now()
+    err = cmd.Run()
+    stateSaved := session.update(stateFile, env, normalizeCommandSource(req.Source))
 
     output := stdout.String()
     errorOutput := stderr.String()
+    truncated := stdout.truncated() || stderr.truncated()
+    timedOut := ctx.Err() == context.DeadlineExceeded
+    exitCode := 0
+    if cmd.ProcessState != nil {
+        exitCode = cmd.ProcessState.ExitCode()
+    } else if err != nil {
+        exitCode = -1
+    }
+    duration := time.Since(start).Milliseconds()
+    problems := recordProblems(root, workingDir, command, output+"\n"+errorOutput)
+    recordAudit(auth.root, AuditEntry{Source: req.Source, Channel: "terminal", Command: command, WorkingDir: workingDir, Decision: auth.decision(), Rule: auth.Rule, ExitCode: &exitCode, DurationMs: duration})
+    recordTerminalHistory(root, TerminalHistoryEntry{Command: command, Cwd: workingDir, SessionID: req.SessionID, Source: req.Source, ExitCode: exitCode, DurationMs: duration})
+    cwd := session.currentDir()
 
     fmt.Printf("Command execution completed. Error: %v\n", err)
-    fmt.Printf("Stdout length: %d, Stderr length: %d\n", len(output), len(errorOutput))
+    fmt.Printf("Stdout length: %d, Stderr length: %d, truncated: %v\n", stdout.total, stderr.total, truncated)
 
     if err != nil {
         fmt.Printf("Command failed: %v\n", err)
         errorMsg := "Command execution failed: " + err.Error()
+        if timedOut {
+            errorMsg = fmt.Sprintf("Command timed out after %v and was killed", timeout)
+        }
         if strings.Contains(errorOutput, "command not found") {
             errorMsg += "\n\nCommon solutions:\n"
             if runtime.GOOS == "windows" {
@@ -676,15 +1026,70 @@ func executeCommand(command, workingDir string) TerminalResponse {
             Output:  output,
             Error:   errorOutput,
             Message: errorMsg,
+            ExitCode:   exitCode,
+            DurationMs: duration,
+            TimedOut:   timedOut,
+            Truncated:  truncated,
+            Problems:   problems,
+            Cwd:        cwd,
+            SessionStateLost: !stateSaved,
         }
     }
 
     fmt.Printf("Command succeeded\n")
-    return TerminalResponse{
+    resp := TerminalResponse{
         Success: true,
         Output:  output,
         Error:   errorOutput,
+        ExitCode:   exitCode,
+        DurationMs: duration,
+        Truncated:  truncated,
+        Problems:   problems,
+        Cwd:        cwd,
+        SessionStateLost: !stateSaved,
     }
+    if !stateSaved {
+        resp.Message = sessionStateLostMessage
+    }
+    return resp
+}
+
+// buildShellCommand prepara el comando en la shell del sistema con el directorio y entorno indicados
+func buildShellCommand(ctx context.Context, command, workingDir string, env []string) *exec.Cmd {
+    // Determine shell based on OS and command
+    var shell string
+    var args []string
+
+    if runtime.GOOS == "windows" {
+        // Si el comando empieza con 'WSL ', usar bash/WSL
+        if strings.HasPrefix(command, "WSL ") {
+            shell = "/bin/bash"
+            args = []string{"-c", strings.TrimPrefix(command, "WSL ")}
+        } else {
+            // Siempre usar PowerShell en Windows
+            shell = "powershell.exe"
+            args = []string{"-Command", command}
+        }
+    } else {
+        shell = "/bin/bash"
+        args = []string{"-c", command}
+    }
+
+    fmt.Printf("Using shell: %s with args: %v\n", shell, args)
+
+    // Create command
+    cmd := exec.CommandContext(ctx, shell, args...)
+
+    // Set working directory if provided
+    if workingDir != "" {
+        cmd.Dir = workingDir
+        fmt.Printf("Set working directory to: %s\n", workingDir)
+    }
+
+    // Entorno armado por buildCommandEnv: shell de login, .env del workspace y herramientas del proyecto
+    cmd.Env = env
+
+    return cmd
 }
 
 func main() {
@@ -696,6 +1101,7 @@ func main() {
     // If running from src-tauri/backend, set projectRoot to its parent
     projectRoot = filepath.Dir(wd)
     fmt.Printf("[BACK] Project root set to: %s\n", projectRoot)
+    initUIToken()
     // Inicializar cliente OpenAI si hay API key
     apiKey := os.Getenv("OPENAI_API_KEY")
     if apiKey != "" {
@@ -708,6 +1114,37 @@ func main() {
     http.HandleFunc("/chat", chatHandler)
     http.HandleFunc("/files", fileHandler)
     http.HandleFunc("/terminal", terminalHandler)
+    http.HandleFunc("/terminal/stream", terminalStreamHandler)
+    http.HandleFunc("/terminal/sessions", terminalSessionsHandler)
+    http.HandleFunc("/terminal/history", terminalHistoryHandler)
+    http.HandleFunc("/api/pty", ptyHandler)
+    http.HandleFunc("/api/pty/ws", ptyWebSocketHandler)
+    http.HandleFunc("/api/processes", processesHandler)
+    http.HandleFunc("/api/audit", auditHandler)
+    http.HandleFunc("/api/confirmations", confirmationsHandler)
+    http.HandleFunc("/api/env", envHandler)
+    http.HandleFunc("/api/toolchains", toolchainHandler)
+    http.HandleFunc("/api/tasks", tasksHandler)
+    http.HandleFunc("/api/tasks/run", taskRunHandler)
+    http.HandleFunc("/api/problems", problemsHandler)
+    http.HandleFunc("/api/explain", explainHandler)
+    http.HandleFunc("/api/lsp", languageServersHandler)
+    http.HandleFunc("/api/lsp/ws", languageServerWebSocketHandler)
+    http.HandleFunc("/api/debug", debugHandler)
+    http.HandleFunc("/api/debug/ws", debugWebSocketHandler)
+    http.HandleFunc("/api/debug/breakpoints", breakpointsHandler)
+    http.HandleFunc("/api/search", searchHandler)
+    http.HandleFunc("/api/replace", replaceHandler)
+    http.HandleFunc("/api/quickopen", quickOpenHandler)
+    http.HandleFunc("/api/raw", rawFileHandler)
+    http.HandleFunc("/api/tail", tailHandler)
+    http.HandleFunc("/api/download", downloadHandler)
+    http.HandleFunc("/api/trash", trashHandler)
+    http.HandleFunc("/api/undo", undoHandler)
+    http.HandleFunc("/api/history", historyHandler)
+    http.HandleFunc("/api/batch", batchHandler)
+    http.HandleFunc("/api/export", exportHandler)
+    http.HandleFunc("/api/import", importHandler)
     http.HandleFunc("/", handleOptions)
 
     // Endpoint para listar archivos
@@ -749,57 +1186,46 @@ func main() {
     })
 
     // Add new endpoint to check available commands
-    http.HandleFunc("/api/check-command", func(w http.ResponseWriter, r *http.Request) {
-        if r.Method != "POST" {
-            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-            return
-        }
-
-        var req struct {
-            Command string `json:"command"`
-        }
-
-        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-            http.Error(w, err.Error(), http.StatusBadRequest)
-            return
-        }
-
-        // Check if command exists
-        var shell string
-        var args []string
-
-        if runtime.GOOS == "windows" {
-            if _, err := os.Stat("/proc/version"); err == nil {
-                shell = "/bin/bash"
-                args = []string{"-c", "which " + req.Command + " || where " + req.Command}
-            } else {
-                shell = "powershell.exe"
-                args = []string{"-Command", "Get-Command " + req.Command + " -ErrorAction SilentlyContinue | Select-Object -ExpandProperty Source"}
-            }
-        } else {
-            shell = "/bin/bash"
-            args = []string{"-c", "which " + req.Command}
-        }
-
-        cmd := exec.Command(shell, args...)
-        output, err := cmd.Output()
-
-        response := map[string]interface{}{
-            "command": req.Command,
-            "exists":  err == nil,
-            "path":    strings.TrimSpace(string(output)),
-        }
-
-        w.Header().Set("Content-Type", "application/json")
-        json.NewEncoder(w).Encode(response)
-    })
+    http.HandleFunc("/api/check-command", checkCommandHandler)
 
     fmt.Println("AirIde Backend Server listening on http://localhost:8080")
     fmt.Println("Available endpoints:")
     fmt.Println("  POST /chat - AI chat functionality")
     fmt.Println("  POST /files - File operations")
     fmt.Println("  POST /terminal - Terminal command execution")
+    fmt.Println("  GET /terminal/stream - Streamed command output (WebSocket or SSE)")
+    fmt.Println("  GET/POST /terminal/sessions - Named terminal sessions that keep cwd and env")
+    fmt.Println("  GET/POST /terminal/history - Search or clear the workspace command history")
+    fmt.Println("  GET/POST /api/pty - Interactive terminal sessions (create, input, resize, close)")
+    fmt.Println("  GET /api/pty/ws - WebSocket attached to a terminal session")
+    fmt.Println("  GET/POST /api/processes - Background processes (start, logs, signal, kill)")
+    fmt.Println("  GET/POST /api/audit - Command audit log and policy check")
+    fmt.Println("  GET/POST /api/confirmations - Commands waiting for the user's approval (IDE only)")
+    fmt.Println("  GET /api/env - Effective command environment")
+    fmt.Println("  GET /api/toolchains - Installed toolchains and workspace requirements")
+    fmt.Println("  GET/POST /api/tasks - Discovered project tasks (list, start in background)")
+    fmt.Println("  GET /api/tasks/run - Run a task streaming its output (WebSocket or SSE)")
+    fmt.Println("  GET/POST /api/problems - Diagnostics parsed from build, lint and test output")
+    fmt.Println("  POST /api/explain - Explain a failed command with the configured AI model")
+    fmt.Println("  GET/POST /api/lsp - Language servers per workspace (status, restart, stop)")
+    fmt.Println("  GET /api/lsp/ws - WebSocket relay between the editor and a language server")
+    fmt.Println("  GET/POST /api/debug - Launch configurations and debug sessions (Delve, debugpy, js-debug)")
+    fmt.Println("  GET /api/debug/ws - WebSocket relay between the editor and a debug adapter")
+    fmt.Println("  GET/POST /api/debug/breakpoints - Breakpoints saved in the workspace")
+    fmt.Println("  POST /api/search - Project-wide text search")
+    fmt.Println("  POST /api/replace - Project-wide search and replace")
+    fmt.Println("  GET  /api/quickopen - Fuzzy file finder")
+    fmt.Println("  GET  /api/raw - Raw file bytes (image previews)")
+    fmt.Println("  GET  /api/tail - Follow appended lines of a file (SSE)")
+    fmt.Println("  GET  /api/download - Streaming file download")
+    fmt.Println("  GET/POST /api/trash - List, restore and purge deleted files")
+    fmt.Println("  GET/POST /api/undo - Undo delete/rename/move")
+    fmt.Println("  GET/POST /api/history - Local file history (list, diff, restore)")
+    fmt.Println("  POST /api/batch - Apply several file operations atomically")
+    fmt.Println("  GET /api/export - Download a folder as zip or tar.gz")
+    fmt.Println("  POST /api/import - Extract an uploaded zip or tar.gz into the workspace")
 
+    handleShutdownSignals()
     err = http.ListenAndServe(":8080", nil)
     if err != nil {
         panic(err)
@@ -0,0 +1,953 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	auditLogName     = "audit.log"
+	maxAuditLogSize  = 5 << 20
+	defaultAuditTail = 200
+)
+
+// Reglas que se aplican aunque el workspace no configure nada (se desactivan con disableDefaults).
+// rm recursivo y las descargas que se pasan a un shell se detectan con builtinDenyRules y builtinConfirmRules.
+var (
+	defaultDenyPatterns = []string{
+		":(){ :|:& };:",
+		"mkfs*", "dd * of=/dev/*", "* > /dev/sd*",
+	}
+	defaultConfirmPatterns = []string{
+		"sudo *", "su *",
+		"git push --force*", "git push -f*", "git push * --force*", "git reset --hard*", "git clean *",
+		"chmod -R *", "chown -R *",
+		"shutdown*", "reboot*", "kill -9 *", "killall *",
+		"npm publish*", "cargo publish*", "docker system prune*",
+	}
+	// Nombres de variables de entorno que se consideran secretos
+	defaultScrubEnvPatterns = []string{"*API_KEY*", "*APIKEY*", "*SECRET*", "*TOKEN*", "*PASSWORD*", "*PASSWD*", "*CREDENTIAL*", "*PRIVATE_KEY*"}
+)
+
+// CommandPolicySettings es la política de ejecución de comandos del workspace (en .airide/settings.json).
+// Los patrones son globs donde '*' coincide con cualquier texto, o expresiones regulares con el prefijo "re:".
+type CommandPolicySettings struct {
+	// Allow, si no está vacío, es la lista de comandos permitidos; el resto se rechaza
+	Allow []string `json:"allow,omitempty"`
+	// Deny rechaza siempre los comandos que coinciden
+	Deny []string `json:"deny,omitempty"`
+	// Confirm exige confirmación del usuario antes de ejecutar
+	Confirm []string `json:"confirm,omitempty"`
+	// ConfirmAI exige confirmación para cualquier comando lanzado por la IA
+	ConfirmAI bool `json:"confirmAI,omitempty"`
+	// ScrubEnv agrega patrones de variables de entorno que no se pasan a los comandos
+	ScrubEnv []string `json:"scrubEnv,omitempty"`
+	// ScrubUserCommands quita los secretos también en los comandos del usuario (por defecto solo en los de la IA)
+	ScrubUserCommands bool `json:"scrubUserCommands,omitempty"`
+	// DisableDefaults desactiva las reglas por defecto
+	DisableDefaults bool `json:"disableDefaults,omitempty"`
+}
+
+// commandAuthorization es el resultado de evaluar un comando contra la política
+type commandAuthorization struct {
+	Allowed           bool
+	Denied            bool
+	ConfirmationToken string
+	Rule              string
+	Message           string
+	policy            CommandPolicySettings
+	root              string // workspace cuya política se aplicó; la auditoría va ahí
+}
+
+// AuditEntry es una línea del registro de comandos (.airide/audit.log)
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Source     string    `json:"source"`  // "user" o "ai"
+	Channel    string    `json:"channel"` // "terminal", "stream", "process"...
+	Command    string    `json:"command"`
+	WorkingDir string    `json:"workingDir,omitempty"`
+	Decision   string    `json:"decision"` // "allowed", "confirmed", "denied", "confirmation_required" o "rejected"
+	Rule       string    `json:"rule,omitempty"`
+	ExitCode   *int      `json:"exitCode,omitempty"`
+	DurationMs int64     `json:"durationMs,omitempty"`
+}
+
+var (
+	auditMu         sync.Mutex
+	commandPatterns sync.Map // patrón -> *regexp.Regexp
+)
+
+// normalizeCommandSource solo distingue entre comandos de la IA y del usuario
+func normalizeCommandSource(source string) string {
+	if strings.EqualFold(source, "ai") {
+		return "ai"
+	}
+	return "user"
+}
+
+// compileCommandPattern convierte un patrón de la política en una expresión regular (con caché)
+func compileCommandPattern(pattern string) *regexp.Regexp {
+	if re, ok := commandPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	var expr string
+	if strings.HasPrefix(pattern, "re:") {
+		expr = strings.TrimPrefix(pattern, "re:")
+	} else {
+		parts := strings.Split(strings.Join(strings.Fields(pattern), " "), "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		expr = "^" + strings.Join(parts, ".*") + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		fmt.Printf("[BACK] Invalid command pattern %q: %v\n", pattern, err)
+		re = regexp.MustCompile(`$^`)
+	}
+	commandPatterns.Store(pattern, re)
+	return re
+}
+
+// shellStage es una orden simple de un pipeline: sus palabras tal cual y sin prefijos como sudo o env
+type shellStage struct {
+	raw   []string
+	words []string
+}
+
+// commandAnalysis reúne lo que se compara con la política: los textos para los globs (el comando completo,
+// cada pipeline y cada orden, con y sin prefijos) y los pipelines para las reglas por defecto.
+// Los scripts de bash -c, eval y las sustituciones $(...) se analizan también.
+type commandAnalysis struct {
+	segments  []string
+	pipelines [][]shellStage
+}
+
+const maxCommandNesting = 4
+
+func analyzeCommand(command string) commandAnalysis {
+	var a commandAnalysis
+	a.add(command, 0)
+	return a
+}
+
+func (a *commandAnalysis) add(command string, depth int) {
+	if whole := strings.Join(strings.Fields(command), " "); whole != "" {
+		a.segments = append(a.segments, whole)
+	}
+	pipelines, nested := parseShellCommand(command)
+	for _, pipeline := range pipelines {
+		var stages []shellStage
+		var texts []string
+		for _, raw := range pipeline {
+			stage := shellStage{raw: raw, words: stripCommandPrefixes(raw)}
+			if len(stage.words) == 0 {
+				continue
+			}
+			stages = append(stages, stage)
+			texts = append(texts, strings.Join(raw, " "))
+			a.segments = append(a.segments, strings.Join(raw, " "))
+			if len(stage.words) != len(raw) {
+				a.segments = append(a.segments, strings.Join(stage.words, " "))
+			}
+		}
+		if len(stages) == 0 {
+			continue
+		}
+		if len(stages) > 1 {
+			a.segments = append(a.segments, strings.Join(texts, " | "))
+		}
+		a.pipelines = append(a.pipelines, stages)
+		if depth >= maxCommandNesting {
+			continue
+		}
+		for _, stage := range stages {
+			if script, ok := inlineScript(stage.words); ok {
+				a.add(script, depth+1)
+			}
+		}
+	}
+	if depth < maxCommandNesting {
+		for _, sub := range nested {
+			a.add(sub, depth+1)
+		}
+	}
+}
+
+// parseShellCommand separa el comando en pipelines (por ;, &&, ||, & o saltos de línea) y cada pipeline
+// en órdenes (por | o |&), respetando comillas y escapes. Las redirecciones quedan como palabras aparte
+// y nested devuelve el texto de las sustituciones $(...), `...`, <(...) y >(...).
+func parseShellCommand(command string) (pipelines [][][]string, nested []string) {
+	var (
+		word     strings.Builder
+		inWord   bool
+		words    []string
+		pipeline [][]string
+	)
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endStage := func() {
+		endWord()
+		if len(words) > 0 {
+			pipeline = append(pipeline, words)
+			words = nil
+		}
+	}
+	endPipeline := func() {
+		endStage()
+		if len(pipeline) > 0 {
+			pipelines = append(pipelines, pipeline)
+			pipeline = nil
+		}
+	}
+	rs := []rune(command)
+	// substitution copia hasta el paréntesis que cierra el abierto en i y devuelve dónde termina
+	substitution := func(i int) int {
+		depth := 1
+		j := i + 1
+		for ; j < len(rs) && depth > 0; j++ {
+			switch rs[j] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+		end := j
+		if depth == 0 {
+			end = j - 1
+		}
+		nested = append(nested, string(rs[i+1:end]))
+		return j
+	}
+	for i := 0; i < len(rs); i++ {
+		c := rs[i]
+		next := rune(0)
+		if i+1 < len(rs) {
+			next = rs[i+1]
+		}
+		switch {
+		case c == ' ' || c == '\t':
+			endWord()
+		case c == '\n' || c == ';':
+			endPipeline()
+		case c == '#' && !inWord:
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+			endPipeline()
+		case c == '&' && next == '&':
+			endPipeline()
+			i++
+		case c == '&' && next == '>':
+			endWord()
+			words = append(words, "&>")
+			i++
+		case c == '&':
+			endPipeline()
+		case c == '|' && next == '|':
+			endPipeline()
+			i++
+		case c == '|':
+			endStage()
+			if next == '&' {
+				i++
+			}
+		case (c == '<' || c == '>') && next == '(':
+			start := i
+			i = substitution(i+1) - 1
+			word.WriteString(string(rs[start : i+1]))
+			inWord = true
+		case c == '<' || c == '>':
+			// El número de descriptor (2>) queda como palabra aparte, igual que el operador
+			endWord()
+			op := string(c)
+			for i+1 < len(rs) && (rs[i+1] == '<' || rs[i+1] == '>' || rs[i+1] == '&' || rs[i+1] == '|') {
+				i++
+				op += string(rs[i])
+			}
+			words = append(words, op)
+		case c == '(' || c == ')':
+			endPipeline()
+		case c == '$' && next == '(':
+			start := i
+			i = substitution(i+1) - 1
+			word.WriteString(string(rs[start : i+1]))
+			inWord = true
+		case c == '`':
+			j := i + 1
+			for j < len(rs) && rs[j] != '`' {
+				j++
+			}
+			nested = append(nested, string(rs[i+1:min(j, len(rs))]))
+			word.WriteString(string(rs[i:min(j+1, len(rs))]))
+			inWord = true
+			i = j
+		case c == '\\':
+			inWord = true
+			if next == '\n' {
+				i++
+			} else if next != 0 {
+				word.WriteRune(next)
+				i++
+			}
+		case c == '\'':
+			inWord = true
+			j := i + 1
+			for j < len(rs) && rs[j] != '\'' {
+				j++
+			}
+			word.WriteString(string(rs[i+1 : min(j, len(rs))]))
+			i = j
+		case c == '"':
+			inWord = true
+			j := i + 1
+			for ; j < len(rs) && rs[j] != '"'; j++ {
+				switch {
+				case rs[j] == '\\' && j+1 < len(rs) && strings.ContainsRune("\\$`\"\n", rs[j+1]):
+					j++
+					word.WriteRune(rs[j])
+				case rs[j] == '$' && j+1 < len(rs) && rs[j+1] == '(':
+					start := j
+					j = substitution(j+1) - 1
+					word.WriteString(string(rs[start : j+1]))
+				case rs[j] == '`':
+					k := j + 1
+					for k < len(rs) && rs[k] != '`' {
+						k++
+					}
+					nested = append(nested, string(rs[j+1:min(k, len(rs))]))
+					word.WriteString(string(rs[j:min(k+1, len(rs))]))
+					j = k
+				default:
+					word.WriteRune(rs[j])
+				}
+			}
+			i = j
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	endPipeline()
+	return pipelines, nested
+}
+
+// Palabras que no son la orden en sí: envoltorios como sudo o env y palabras reservadas del shell
+var (
+	commandWrappers = map[string]bool{"sudo": true, "doas": true, "time": true, "nohup": true, "exec": true, "command": true, "env": true, "nice": true, "builtin": true}
+	shellKeywords   = map[string]bool{"{": true, "}": true, "!": true, "if": true, "then": true, "else": true, "elif": true, "while": true, "until": true, "do": true}
+	// Opciones de sudo y env que llevan un argumento separado
+	wrapperOptionArgs  = map[string]bool{"-u": true, "-g": true, "-U": true, "-C": true, "-D": true, "-h": true, "-p": true, "-n": true, "-S": true}
+	commandShells      = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "ash": true, "fish": true, "csh": true, "tcsh": true}
+	commandDownloaders = map[string]bool{"curl": true, "wget": true}
+	envAssignment      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+)
+
+// commandName es el nombre del programa sin su ruta (/usr/bin/rm -> rm)
+func commandName(word string) string {
+	return path.Base(word)
+}
+
+// stripCommandPrefixes quita las asignaciones de variables, sudo, env, time... para llegar a la orden real
+func stripCommandPrefixes(words []string) []string {
+	i := 0
+	for i < len(words) {
+		w := words[i]
+		switch {
+		case shellKeywords[w] || envAssignment.MatchString(w):
+			i++
+		case commandWrappers[commandName(w)]:
+			i++
+			for i < len(words) && strings.HasPrefix(words[i], "-") {
+				if commandName(w) != "nice" && wrapperOptionArgs[words[i]] {
+					i++
+				}
+				i++
+			}
+		default:
+			return words[i:]
+		}
+	}
+	return nil
+}
+
+// inlineScript devuelve el script de "bash -c '...'" o de eval, que se analiza como otro comando
+func inlineScript(words []string) (string, bool) {
+	name := commandName(words[0])
+	if name == "eval" && len(words) > 1 {
+		return strings.Join(words[1:], " "), true
+	}
+	if !commandShells[name] {
+		return "", false
+	}
+	for i := 1; i < len(words); i++ {
+		w := words[i]
+		if !strings.HasPrefix(w, "-") || w == "-" || w == "--" {
+			return "", false
+		}
+		if !strings.HasPrefix(w, "--") && strings.ContainsRune(w, 'c') && i+1 < len(words) {
+			return words[i+1], true
+		}
+	}
+	return "", false
+}
+
+// recursiveRm indica si la orden es un rm recursivo y devuelve sus destinos
+func recursiveRm(words []string) (recursive bool, targets []string, noPreserveRoot bool) {
+	if commandName(words[0]) != "rm" {
+		return false, nil, false
+	}
+	options := true
+	for _, w := range words[1:] {
+		switch {
+		case options && w == "--":
+			options = false
+		case options && (w == "--recursive"):
+			recursive = true
+		case options && w == "--no-preserve-root":
+			noPreserveRoot = true
+		case options && strings.HasPrefix(w, "--"):
+		case options && strings.HasPrefix(w, "-") && len(w) > 1:
+			if strings.ContainsAny(w[1:], "rR") {
+				recursive = true
+			}
+		default:
+			targets = append(targets, w)
+		}
+	}
+	return recursive, targets, noPreserveRoot
+}
+
+// isRootOrHome indica si el destino de un rm es la raíz o el directorio personal (/, /*, ~, $HOME/...)
+func isRootOrHome(target string) bool {
+	t := target
+	for {
+		trimmed := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(t, "*"), "/."), "/")
+		if trimmed == t {
+			break
+		}
+		t = trimmed
+	}
+	switch t {
+	case "", "~", "$HOME", "${HOME}":
+		return true
+	}
+	return false
+}
+
+// builtinCommandRule es una regla por defecto que examina las órdenes de un pipeline en vez de un glob
+type builtinCommandRule struct {
+	name  string
+	match func(stages []shellStage) bool
+}
+
+var (
+	builtinDenyRules = []builtinCommandRule{
+		{"rm -r / or ~", func(stages []shellStage) bool {
+			for _, s := range stages {
+				recursive, targets, noPreserveRoot := recursiveRm(s.words)
+				if recursive && noPreserveRoot {
+					return true
+				}
+				for _, t := range targets {
+					if recursive && isRootOrHome(t) {
+						return true
+					}
+				}
+			}
+			return false
+		}},
+		{"download piped to a shell", func(stages []shellStage) bool {
+			downloaded := false
+			for _, s := range stages {
+				name := commandName(s.words[0])
+				if downloaded && (commandShells[name] || name == "eval" || name == "source" || name == ".") {
+					return true
+				}
+				if commandDownloaders[name] {
+					downloaded = true
+				}
+				// bash -c "$(curl ...)", bash <(curl ...), eval "$(wget ...)"
+				if commandShells[name] || name == "eval" || name == "source" || name == "." {
+					for _, w := range s.words[1:] {
+						if runsDownloader(w) {
+							return true
+						}
+					}
+				}
+			}
+			return false
+		}},
+	}
+	builtinConfirmRules = []builtinCommandRule{
+		{"rm -r", func(stages []shellStage) bool {
+			for _, s := range stages {
+				if recursive, _, _ := recursiveRm(s.words); recursive {
+					return true
+				}
+			}
+			return false
+		}},
+	}
+)
+
+// runsDownloader indica si la palabra contiene una sustitución que ejecuta curl o wget
+func runsDownloader(word string) bool {
+	if !strings.Contains(word, "$(") && !strings.Contains(word, "`") && !strings.Contains(word, "<(") {
+		return false
+	}
+	_, nested := parseShellCommand(word)
+	for _, sub := range nested {
+		for _, pipeline := range analyzeCommand(sub).pipelines {
+			for _, s := range pipeline {
+				if commandDownloaders[commandName(s.words[0])] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// workspaceConfigRule es la regla que impide a los comandos que no son del usuario tocar .airide
+const workspaceConfigRule = "workspace configuration (" + workspaceConfigDir + ") is read-only for the AI"
+
+// touchesWorkspaceConfig indica si alguna palabra del comando (o de sus scripts y sustituciones) nombra .airide
+func touchesWorkspaceConfig(a commandAnalysis) bool {
+	for _, stages := range a.pipelines {
+		for _, s := range stages {
+			for _, w := range append(append([]string{}, s.raw...), s.words...) {
+				if strings.Contains(w, workspaceConfigDir) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func matchBuiltinRules(rules []builtinCommandRule, a commandAnalysis) (string, bool) {
+	for _, rule := range rules {
+		for _, stages := range a.pipelines {
+			if rule.match(stages) {
+				return rule.name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchCommandPatterns devuelve el primer patrón que coincide con algún segmento del comando
+func matchCommandPatterns(patterns []string, segments []string) (string, bool) {
+	for _, pattern := range patterns {
+		re := compileCommandPattern(pattern)
+		for _, seg := range segments {
+			if re.MatchString(seg) {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// evaluateCommand decide si el comando se puede ejecutar: primero deny, luego allow y por último confirm
+func evaluateCommand(policy CommandPolicySettings, command, source string) (decision, rule string) {
+	a := analyzeCommand(command)
+	deny := policy.Deny
+	confirm := policy.Confirm
+	if !policy.DisableDefaults {
+		if rule, ok := matchBuiltinRules(builtinDenyRules, a); ok {
+			return "denied", rule
+		}
+		deny = append(append([]string{}, defaultDenyPatterns...), deny...)
+		confirm = append(append([]string{}, defaultConfirmPatterns...), confirm...)
+	}
+	if rule, ok := matchCommandPatterns(deny, a.segments); ok {
+		return "denied", rule
+	}
+	// La configuración del workspace (política incluida) solo la cambia el usuario
+	if source != "user" && touchesWorkspaceConfig(a) {
+		return "denied", workspaceConfigRule
+	}
+	if len(policy.Allow) > 0 {
+		// Con lista de permitidos, cada orden (de cada pipeline, script o sustitución) tiene que estar permitida
+		for _, stages := range a.pipelines {
+			for _, s := range stages {
+				text := strings.Join(s.raw, " ")
+				if _, ok := matchCommandPatterns(policy.Allow, []string{text, strings.Join(s.words, " ")}); !ok {
+					return "denied", "not in allow list: " + text
+				}
+			}
+		}
+	}
+	if !policy.DisableDefaults {
+		if rule, ok := matchBuiltinRules(builtinConfirmRules, a); ok {
+			return "confirmation_required", rule
+		}
+	}
+	if rule, ok := matchCommandPatterns(confirm, a.segments); ok {
+		return "confirmation_required", rule
+	}
+	if policy.ConfirmAI && source == "ai" {
+		return "confirmation_required", "confirmAI"
+	}
+	return "allowed", ""
+}
+
+// commandPolicyRoot decide qué workspace (política, límites y auditoría) aplica a un comando. Solo la interfaz
+// elige el workspace con projectBaseDir; para el resto manda el directorio del comando: el ancestro más cercano
+// que tenga .airide o, si no hay ninguno, el workspace que abrió el servidor.
+func commandPolicyRoot(root string, req TerminalRequest) string {
+	if normalizeCommandSource(req.Source) == "user" {
+		return root
+	}
+	dir := req.WorkingDir
+	if dir == "" {
+		dir = root
+	} else if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if info, err := os.Stat(filepath.Join(d, workspaceConfigDir)); err == nil && info.IsDir() {
+			return d
+		}
+		if filepath.Dir(d) == d {
+			return projectRoot
+		}
+	}
+}
+
+// authorizeCommand aplica la política del workspace y registra en la auditoría los comandos que no se ejecutan.
+// Un comando que requiere confirmación queda pendiente: se ejecuta al reenviarlo con confirmToken desde la
+// interfaz (con el token de la UI) o después de que el usuario lo apruebe en /api/confirmations.
+// req.Source tiene que venir de requestSource, no del cliente.
+func authorizeCommand(root, channel string, req TerminalRequest) commandAuthorization {
+	root = commandPolicyRoot(root, req)
+	policy := loadWorkspaceSettings(root).Commands
+	source := normalizeCommandSource(req.Source)
+	decision, rule := evaluateCommand(policy, req.Command, source)
+	auth := commandAuthorization{Rule: rule, policy: policy, root: root}
+	switch decision {
+	case "denied":
+		auth.Denied = true
+		auth.Message = "Command blocked by workspace policy (" + rule + ")"
+	case "confirmation_required":
+		if req.ConfirmToken != "" && consumeCommandConfirmation(req.ConfirmToken, root, req.Command, source == "user") {
+			auth.Allowed = true
+			decision = "confirmed"
+		} else {
+			auth.ConfirmationToken = requestCommandConfirmation(root, channel, source, req.Command, req.WorkingDir, rule)
+			if source == "user" {
+				auth.Message = "Command requires confirmation (" + rule + "); resend it with confirmToken to run it"
+			} else {
+				auth.Message = "Command requires confirmation (" + rule + "); the user has to approve it in the IDE before it is resent with confirmToken"
+			}
+		}
+	default:
+		auth.Allowed = true
+	}
+	if !auth.Allowed {
+		fmt.Printf("[BACK] Command not run (%s, %s): %s\n", decision, rule, req.Command)
+		recordAudit(root, AuditEntry{Source: source, Channel: channel, Command: req.Command, WorkingDir: req.WorkingDir, Decision: decision, Rule: rule})
+	}
+	return auth
+}
+
+// Token de la interfaz: solo las peticiones que lo llevan cuentan como del usuario y pueden confirmar comandos.
+// Tauri lo pasa en AIRIDE_UI_TOKEN; si no, se genera uno y se guarda en el directorio de configuración del usuario.
+const uiTokenHeader = "X-Airide-Ui-Token"
+
+var uiToken string
+
+func initUIToken() {
+	uiToken = os.Getenv("AIRIDE_UI_TOKEN")
+	if uiToken != "" {
+		return
+	}
+	uiToken = randomID(32)
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		fmt.Println("[BACK] Cannot save the UI token:", err)
+		return
+	}
+	dir = filepath.Join(dir, "airide")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		fmt.Println("[BACK] Cannot save the UI token:", err)
+		return
+	}
+	path := filepath.Join(dir, "ui-token")
+	if err := os.WriteFile(path, []byte(uiToken), 0600); err != nil {
+		fmt.Println("[BACK] Cannot save the UI token:", err)
+		return
+	}
+	fmt.Printf("[BACK] UI token saved to %s\n", path)
+}
+
+// isUIRequest indica si la petición lleva el token de la interfaz (cabecera, o ?uiToken= en WebSocket y SSE)
+func isUIRequest(r *http.Request) bool {
+	token := r.Header.Get(uiTokenHeader)
+	if token == "" {
+		token = r.URL.Query().Get("uiToken")
+	}
+	return uiToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(uiToken)) == 1
+}
+
+// requestSource decide el origen de un comando: "user" solo si la petición viene de la interfaz,
+// cualquier otra se trata como de la IA aunque diga lo contrario
+func requestSource(r *http.Request, claimed string) string {
+	if isUIRequest(r) && normalizeCommandSource(claimed) == "user" {
+		return "user"
+	}
+	return "ai"
+}
+
+// PendingConfirmation es un comando que espera la aprobación del usuario
+type PendingConfirmation struct {
+	ID         string    `json:"id"`
+	Root       string    `json:"root"`
+	Channel    string    `json:"channel"`
+	Source     string    `json:"source"`
+	Command    string    `json:"command"`
+	WorkingDir string    `json:"workingDir,omitempty"`
+	Rule       string    `json:"rule"`
+	Approved   bool      `json:"approved"`
+	Created    time.Time `json:"created"`
+	expires    time.Time
+}
+
+var (
+	confirmationsMu sync.Mutex
+	confirmations   = map[string]*PendingConfirmation{}
+)
+
+func requestCommandConfirmation(root, channel, source, command, workingDir, rule string) string {
+	confirmationsMu.Lock()
+	defer confirmationsMu.Unlock()
+	for id, c := range confirmations {
+		if time.Now().After(c.expires) {
+			delete(confirmations, id)
+		}
+	}
+	c := &PendingConfirmation{ID: randomID(16), Root: root, Channel: channel, Source: source, Command: command, WorkingDir: workingDir, Rule: rule, Created: time.Now(), expires: time.Now().Add(confirmTokenTTL)}
+	confirmations[c.ID] = c
+	return c.ID
+}
+
+// consumeCommandConfirmation acepta el token si el usuario aprobó el comando o si lo reenvía la interfaz
+func consumeCommandConfirmation(id, root, command string, fromUI bool) bool {
+	confirmationsMu.Lock()
+	defer confirmationsMu.Unlock()
+	c, ok := confirmations[id]
+	if !ok || c.Root != root || c.Command != command || time.Now().After(c.expires) || (!c.Approved && !fromUI) {
+		return false
+	}
+	delete(confirmations, id)
+	return true
+}
+
+// confirmationsHandler lista los comandos pendientes (GET ?projectBaseDir=) y los aprueba o rechaza
+// (POST {"id", "approve"}); solo responde a la interfaz
+func confirmationsHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if !isUIRequest(r) {
+		http.Error(w, "Only the IDE can list or approve confirmations", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case "GET":
+		root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+		confirmationsMu.Lock()
+		pending := []PendingConfirmation{}
+		for _, c := range confirmations {
+			if c.Root == root && time.Now().Before(c.expires) {
+				pending = append(pending, *c)
+			}
+		}
+		confirmationsMu.Unlock()
+		sort.Slice(pending, func(i, j int) bool { return pending[i].Created.Before(pending[j].Created) })
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "confirmations": pending})
+	case "POST":
+		var req struct {
+			ID      string `json:"id"`
+			Approve bool   `json:"approve"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		confirmationsMu.Lock()
+		c, ok := confirmations[req.ID]
+		if ok && time.Now().After(c.expires) {
+			delete(confirmations, req.ID)
+			ok = false
+		}
+		var entry PendingConfirmation
+		if ok {
+			if req.Approve {
+				c.Approved = true
+				c.expires = time.Now().Add(confirmTokenTTL)
+			} else {
+				delete(confirmations, req.ID)
+			}
+			entry = *c
+		}
+		confirmationsMu.Unlock()
+		if !ok {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Confirmation not found or expired: " + req.ID})
+			return
+		}
+		if !req.Approve {
+			recordAudit(entry.Root, AuditEntry{Source: entry.Source, Channel: entry.Channel, Command: entry.Command, WorkingDir: entry.WorkingDir, Decision: "rejected", Rule: entry.Rule})
+		}
+		fmt.Printf("[BACK] Confirmation %s for '%s': approved=%v\n", entry.ID, entry.Command, req.Approve)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "confirmation": entry})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// decision devuelve cómo se registra en la auditoría un comando autorizado
+func (a commandAuthorization) decision() string {
+	if a.Rule != "" {
+		return "confirmed"
+	}
+	return "allowed"
+}
+
+// scrubEnv quita del entorno las variables con secretos según la política
+func scrubEnv(env []string, policy CommandPolicySettings, source string) []string {
+	if source != "ai" && !policy.ScrubUserCommands {
+		return env
+	}
+	patterns := append(append([]string{}, defaultScrubEnvPatterns...), policy.ScrubEnv...)
+	var res []string
+	for _, kv := range env {
+		name := kv
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			name = kv[:i]
+		}
+		if _, secret := matchCommandPatterns(patterns, []string{strings.ToUpper(name)}); secret {
+			continue
+		}
+		res = append(res, kv)
+	}
+	return res
+}
+
+// recordAudit agrega una línea al registro de comandos del workspace
+func recordAudit(root string, entry AuditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Source = normalizeCommandSource(entry.Source)
+	dir := filepath.Join(root, workspaceConfigDir)
+	path := filepath.Join(dir, auditLogName)
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Println("[BACK] Cannot write audit log:", err)
+		return
+	}
+	// Rotar para que el registro no crezca sin límite
+	if info, err := os.Stat(path); err == nil && info.Size() > maxAuditLogSize {
+		os.Rename(path, path+".1")
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		fmt.Println("[BACK] Cannot write audit log:", err)
+		return
+	}
+	defer f.Close()
+	line, _ := json.Marshal(entry)
+	f.Write(append(line, '\n'))
+}
+
+// readAudit devuelve las últimas limit entradas del registro, las más recientes primero
+func readAudit(root string, limit int, source string) []AuditEntry {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.Open(filepath.Join(root, workspaceConfigDir, auditLogName))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var e AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || (source != "" && e.Source != source) {
+			continue
+		}
+		entries = append(entries, e)
+		if len(entries) > 2*limit {
+			entries = entries[len(entries)-limit:]
+		}
+	}
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries
+}
+
+// auditHandler lista el registro de comandos (?limit=200&source=ai) o evalúa un comando sin ejecutarlo (POST)
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case "GET":
+		q := r.URL.Query()
+		limit, err := strconv.Atoi(q.Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = defaultAuditTail
+		}
+		source := q.Get("source")
+		if source != "" {
+			source = normalizeCommandSource(source)
+		}
+		entries := readAudit(workspaceRoot(q.Get("projectBaseDir")), limit, source)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "entries": entries})
+	case "POST":
+		var req TerminalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Source = requestSource(r, req.Source)
+		policy := loadWorkspaceSettings(terminalWorkspaceRoot(req)).Commands
+		decision, rule := evaluateCommand(policy, req.Command, req.Source)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "decision": decision, "rule": rule})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
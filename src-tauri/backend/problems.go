@@ -0,0 +1,532 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Máximo de diagnósticos que se guardan por ejecución
+	maxProblemsPerRun = 1000
+	// Salida que se conserva de un comando en streaming para analizarla al terminar
+	problemOutputLimit = 2 * 1024 * 1024
+	// Archivo de .airide donde se guardan los diagnósticos para que sobrevivan a un reinicio del backend
+	problemsFileName = "problems.json"
+)
+
+// Diagnostic es un error o aviso extraído de la salida de un compilador, linter o test
+type Diagnostic struct {
+	File     string    `json:"file"` // relativo al workspace si está dentro
+	Line     int       `json:"line,omitempty"`
+	Column   int       `json:"column,omitempty"`
+	Severity string    `json:"severity"` // "error", "warning" o "info"
+	Message  string    `json:"message"`
+	Code     string    `json:"code,omitempty"`
+	Source   string    `json:"source"` // matcher que lo produjo: "go", "tsc", "eslint", "cargo", "python" o "pytest"
+	Command  string    `json:"command,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// problemContext resuelve las rutas de la salida, que son relativas al directorio del comando
+type problemContext struct {
+	root    string
+	cwd     string
+	command string
+}
+
+func (c problemContext) file(p string) string {
+	p = strings.TrimSpace(p)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(c.cwd, p)
+	}
+	p = filepath.Clean(p)
+	if rel, err := filepath.Rel(c.root, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return p
+}
+
+// problemMatcher convierte la salida de una herramienta en diagnósticos
+type problemMatcher struct {
+	name string
+	// commands reconoce los comandos de la herramienta
+	commands *regexp.Regexp
+	parse    func(lines []string, ctx problemContext) []Diagnostic
+}
+
+var (
+	ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+	goProblemPattern         = regexp.MustCompile(`^\s*(?:vet: )?((?:[A-Za-z]:)?[^\s:][^:]*\.go):(\d+)(?::(\d+))?:\s+(.+)$`)
+	goTestPackagePattern     = regexp.MustCompile(`^(?:ok|FAIL)\s+(\S+)\s+(?:\d[\d.]*s|\(cached\)|\[)`)
+	goModulePattern          = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?`)
+	tscProblemPattern        = regexp.MustCompile(`^(\S[^(]*)\((\d+),(\d+)\):\s+(error|warning)\s+(TS\d+):\s+(.+)$`)
+	tscPrettyProblemPattern  = regexp.MustCompile(`^(\S.*?):(\d+):(\d+) - (error|warning) (TS\d+): (.+)$`)
+	eslintFilePattern        = regexp.MustCompile(`^(\S.*\.(?:[cm]?[jt]sx?|vue|svelte|json|md))$`)
+	eslintProblemPattern     = regexp.MustCompile(`^\s+(\d+):(\d+)\s+(error|warning)\s+(.+?)(?:\s{2,}(\S+))?$`)
+	eslintCompactPattern     = regexp.MustCompile(`^(.+): line (\d+), col (\d+), (Error|Warning) - (.+?)(?: \((\S+)\))?$`)
+	cargoHeaderPattern       = regexp.MustCompile(`^(error|warning)(?:\[(\w+)\])?: (.+)$`)
+	cargoLocationPattern     = regexp.MustCompile(`^\s*--> (.+):(\d+):(\d+)$`)
+	pythonFramePattern       = regexp.MustCompile(`^\s+File "(.+)", line (\d+)`)
+	pytestLocationPattern    = regexp.MustCompile(`^(\S+\.py):(\d+): (\w+)$`)
+	pytestErrorDetailPattern = regexp.MustCompile(`^E\s+(.*)$`)
+
+	// Lanzadores de scripts cuya herramienta real no se ve en el comando: con ellos se prueban todos los matchers
+	taskRunnerCommandPattern = regexp.MustCompile(`\b(npm|npx|yarn|pnpm|make|just|bun)\b`)
+)
+
+var problemMatchers = []problemMatcher{
+	{name: "go", commands: regexp.MustCompile(`\bgo\s+(build|vet|test|run|install)\b|\bgolangci-lint\b|\bstaticcheck\b`), parse: parseGoProblems},
+	{name: "tsc", commands: regexp.MustCompile(`\btsc\b|\bvue-tsc\b`), parse: parseTscProblems},
+	{name: "eslint", commands: regexp.MustCompile(`\beslint\b`), parse: parseESLintProblems},
+	{name: "cargo", commands: regexp.MustCompile(`\bcargo\b|\brustc\b`), parse: parseCargoProblems},
+	{name: "pytest", commands: regexp.MustCompile(`\bpytest\b|-m\s+pytest\b`), parse: parsePytestProblems},
+	{name: "python", commands: regexp.MustCompile(`\bpython[0-9.]*\b|\.py\b`), parse: parsePythonTraceback},
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// parseGoProblems lee errores de go build/run, avisos de go vet y fallos de go test (archivo:línea: mensaje).
+// Los mensajes de los tests (t.Errorf) solo traen el nombre del archivo, relativo al directorio de su paquete:
+// se guardan hasta la línea "ok"/"FAIL <paquete>" que cierra la salida de ese paquete y se resuelven contra él.
+func parseGoProblems(lines []string, ctx problemContext) []Diagnostic {
+	severity := "error"
+	if strings.Contains(ctx.command, "vet") || strings.Contains(ctx.command, "lint") || strings.Contains(ctx.command, "staticcheck") {
+		severity = "warning"
+	}
+	var res []Diagnostic
+	var pending []int // índices en res de mensajes de test que esperan su paquete
+	for _, line := range lines {
+		if m := goTestPackagePattern.FindStringSubmatch(line); m != nil {
+			if dir := goPackageDir(ctx.cwd, m[1]); dir != "" {
+				for _, i := range pending {
+					res[i].File = ctx.file(filepath.Join(dir, res[i].File))
+				}
+			} else {
+				for _, i := range pending {
+					res[i].File = ctx.file(res[i].File)
+				}
+			}
+			pending = pending[:0]
+			continue
+		}
+		m := goProblemPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		d := Diagnostic{File: ctx.file(m[1]), Line: atoi(m[2]), Column: atoi(m[3]), Severity: severity, Message: m[4], Source: "go"}
+		if strings.TrimLeft(line, " \t") != line && !strings.ContainsAny(m[1], `/\`) {
+			d.File = m[1]
+			pending = append(pending, len(res))
+		}
+		res = append(res, d)
+	}
+	// Salida cortada antes de la línea del paquete: lo más probable es que sea el del directorio del comando
+	for _, i := range pending {
+		res[i].File = ctx.file(res[i].File)
+	}
+	return res
+}
+
+// goPackageDir busca el directorio de un paquete del módulo que contiene a cwd; "" si el paquete es de otro módulo
+func goPackageDir(cwd, pkg string) string {
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			m := goModulePattern.FindSubmatch(data)
+			if m == nil {
+				return ""
+			}
+			module := string(m[1])
+			if pkg == module {
+				return dir
+			}
+			if rest, ok := strings.CutPrefix(pkg, module+"/"); ok {
+				return filepath.Join(dir, filepath.FromSlash(rest))
+			}
+			return ""
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+// parseTscProblems lee el formato clásico archivo(línea,col) y el de --pretty archivo:línea:col - error
+func parseTscProblems(lines []string, ctx problemContext) []Diagnostic {
+	var res []Diagnostic
+	for _, line := range lines {
+		m := tscProblemPattern.FindStringSubmatch(line)
+		if m == nil {
+			m = tscPrettyProblemPattern.FindStringSubmatch(line)
+		}
+		if m == nil {
+			continue
+		}
+		res = append(res, Diagnostic{File: ctx.file(m[1]), Line: atoi(m[2]), Column: atoi(m[3]), Severity: m[4], Code: m[5], Message: m[6], Source: "tsc"})
+	}
+	return res
+}
+
+// parseESLintProblems lee el formato stylish (archivo y debajo línea:col severidad mensaje regla) y el compact
+func parseESLintProblems(lines []string, ctx problemContext) []Diagnostic {
+	var res []Diagnostic
+	file := ""
+	for _, line := range lines {
+		if m := eslintCompactPattern.FindStringSubmatch(line); m != nil {
+			res = append(res, Diagnostic{File: ctx.file(m[1]), Line: atoi(m[2]), Column: atoi(m[3]), Severity: strings.ToLower(m[4]), Message: m[5], Code: m[6], Source: "eslint"})
+			continue
+		}
+		if m := eslintFilePattern.FindStringSubmatch(line); m != nil {
+			file = m[1]
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			file = ""
+			continue
+		}
+		if m := eslintProblemPattern.FindStringSubmatch(line); m != nil && file != "" {
+			res = append(res, Diagnostic{File: ctx.file(file), Line: atoi(m[1]), Column: atoi(m[2]), Severity: m[3], Message: m[4], Code: m[5], Source: "eslint"})
+		}
+	}
+	return res
+}
+
+// parseCargoProblems lee "error[E0425]: mensaje" seguido de la línea "--> archivo:línea:col"
+func parseCargoProblems(lines []string, ctx problemContext) []Diagnostic {
+	var res []Diagnostic
+	for i := 0; i+1 < len(lines); i++ {
+		h := cargoHeaderPattern.FindStringSubmatch(lines[i])
+		if h == nil {
+			continue
+		}
+		loc := cargoLocationPattern.FindStringSubmatch(lines[i+1])
+		if loc == nil {
+			// Resúmenes como "error: could not compile" no apuntan a ningún archivo
+			continue
+		}
+		res = append(res, Diagnostic{File: ctx.file(loc[1]), Line: atoi(loc[2]), Column: atoi(loc[3]), Severity: h[1], Code: h[2], Message: h[3], Source: "cargo"})
+		i++
+	}
+	return res
+}
+
+// parsePythonTraceback marca la excepción en el último frame del traceback que pertenece al proyecto
+func parsePythonTraceback(lines []string, ctx problemContext) []Diagnostic {
+	var res []Diagnostic
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "Traceback (most recent call last):") {
+			continue
+		}
+		var file string
+		var line int
+		j := i + 1
+		for ; j < len(lines); j++ {
+			if m := pythonFramePattern.FindStringSubmatch(lines[j]); m != nil {
+				// Se prefieren los frames del código del usuario frente a librerías instaladas
+				if !strings.HasPrefix(m[1], "<") && !strings.Contains(m[1], "site-packages") && !strings.Contains(m[1], "/lib/python") {
+					file, line = m[1], atoi(m[2])
+				}
+				continue
+			}
+			if lines[j] != "" && lines[j][0] != ' ' && lines[j][0] != '\t' {
+				break
+			}
+		}
+		if j < len(lines) && file != "" {
+			code, message := lines[j], lines[j]
+			if name, rest, ok := strings.Cut(lines[j], ": "); ok && !strings.Contains(name, " ") {
+				code, message = name, rest
+			}
+			res = append(res, Diagnostic{File: ctx.file(file), Line: line, Severity: "error", Code: code, Message: message, Source: "python"})
+		}
+		i = j
+	}
+	return res
+}
+
+// parsePytestProblems lee las ubicaciones "tests/test_x.py:12: AssertionError" con las líneas "E ..." que las preceden
+func parsePytestProblems(lines []string, ctx problemContext) []Diagnostic {
+	var res []Diagnostic
+	var details []string
+	for _, line := range lines {
+		if m := pytestErrorDetailPattern.FindStringSubmatch(line); m != nil {
+			details = append(details, strings.TrimSpace(m[1]))
+			continue
+		}
+		if strings.HasPrefix(line, "___") {
+			details = nil
+			continue
+		}
+		m := pytestLocationPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		message := m[3]
+		if len(details) > 0 {
+			message = strings.Join(details, "\n")
+		}
+		res = append(res, Diagnostic{File: ctx.file(m[1]), Line: atoi(m[2]), Severity: "error", Code: m[3], Message: message, Source: "pytest"})
+		details = nil
+	}
+	return res
+}
+
+// matchProblems aplica a la salida los matchers que reconocen el comando (todos si es un lanzador como npm o make,
+// o si anyTool). El segundo valor es false si el comando no es de ninguna herramienta conocida y no se analizó.
+func matchProblems(root, cwd, command, output string, anyTool bool) ([]Diagnostic, bool) {
+	ctx := problemContext{root: root, cwd: cwd, command: command}
+	lines := strings.Split(ansiEscapePattern.ReplaceAllString(strings.ReplaceAll(output, "\r\n", "\n"), ""), "\n")
+	var selected []problemMatcher
+	for _, m := range problemMatchers {
+		if m.commands.MatchString(command) {
+			selected = append(selected, m)
+		}
+	}
+	if len(selected) == 0 {
+		if !anyTool && !taskRunnerCommandPattern.MatchString(command) {
+			return nil, false
+		}
+		selected = problemMatchers
+	}
+	now := time.Now()
+	var res []Diagnostic
+	seen := map[string]bool{}
+	for _, m := range selected {
+		for _, d := range m.parse(lines, ctx) {
+			key := fmt.Sprintf("%s:%d:%d:%s", d.File, d.Line, d.Column, d.Message)
+			if seen[key] || len(res) >= maxProblemsPerRun {
+				continue
+			}
+			seen[key] = true
+			d.Command, d.Time = command, now
+			res = append(res, d)
+		}
+	}
+	return res, true
+}
+
+var (
+	problemsMu sync.Mutex
+	// Diagnósticos por workspace y por ejecución (directorio + comando); volver a ejecutar reemplaza los anteriores
+	workspaceProblems = map[string]map[string][]Diagnostic{}
+)
+
+// problemRun es una ejecución guardada en .airide/problems.json
+type problemRun struct {
+	Cwd      string       `json:"cwd"`
+	Command  string       `json:"command"`
+	Problems []Diagnostic `json:"problems"`
+}
+
+func problemsPath(root string) string {
+	return filepath.Join(root, workspaceConfigDir, problemsFileName)
+}
+
+// problemRuns devuelve los diagnósticos del workspace, leyéndolos de disco la primera vez. Requiere problemsMu.
+func problemRuns(root string) map[string][]Diagnostic {
+	if runs, ok := workspaceProblems[root]; ok {
+		return runs
+	}
+	runs := map[string][]Diagnostic{}
+	workspaceProblems[root] = runs
+	if root == "" {
+		return runs
+	}
+	data, err := os.ReadFile(problemsPath(root))
+	if err != nil {
+		return runs
+	}
+	var saved []problemRun
+	if err := json.Unmarshal(data, &saved); err != nil {
+		fmt.Printf("[BACK] Ignoring invalid %s: %v\n", problemsPath(root), err)
+		return runs
+	}
+	for _, run := range saved {
+		runs[run.Cwd+"\x00"+run.Command] = run.Problems
+	}
+	return runs
+}
+
+// saveProblems escribe los diagnósticos del workspace en .airide/problems.json. Requiere problemsMu.
+func saveProblems(root string) {
+	if root == "" {
+		return
+	}
+	saved := []problemRun{}
+	for key, diags := range workspaceProblems[root] {
+		cwd, command, _ := strings.Cut(key, "\x00")
+		saved = append(saved, problemRun{Cwd: cwd, Command: command, Problems: diags})
+	}
+	sort.Slice(saved, func(i, j int) bool {
+		if saved[i].Cwd != saved[j].Cwd {
+			return saved[i].Cwd < saved[j].Cwd
+		}
+		return saved[i].Command < saved[j].Command
+	})
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(root, workspaceConfigDir), 0755)
+	}
+	if err == nil {
+		err = atomicWriteFile(problemsPath(root), data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("[BACK] Could not save problems: %v\n", err)
+	}
+}
+
+// recordProblems analiza la salida de un comando y guarda sus diagnósticos, reemplazando los de su ejecución anterior.
+// Los comandos que no son de ninguna herramienta conocida (ls, grep...) no tocan el panel.
+func recordProblems(root, cwd, command, output string) []Diagnostic {
+	if cwd == "" {
+		cwd = root
+	}
+	diags, matched := matchProblems(root, cwd, command, output, false)
+	if !matched {
+		return nil
+	}
+	problemsMu.Lock()
+	defer problemsMu.Unlock()
+	runs := problemRuns(root)
+	key := cwd + "\x00" + strings.TrimSpace(command)
+	if _, ok := runs[key]; !ok && len(diags) == 0 {
+		return diags
+	}
+	if len(diags) == 0 {
+		delete(runs, key)
+	} else {
+		runs[key] = diags
+	}
+	saveProblems(root)
+	return diags
+}
+
+// listProblems devuelve los diagnósticos del workspace ordenados por archivo y posición
+func listProblems(root, file, severity string) []Diagnostic {
+	problemsMu.Lock()
+	var res []Diagnostic
+	for _, diags := range problemRuns(root) {
+		for _, d := range diags {
+			if (file == "" || d.File == file) && (severity == "" || d.Severity == severity) {
+				res = append(res, d)
+			}
+		}
+	}
+	problemsMu.Unlock()
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].File != res[j].File {
+			return res[i].File < res[j].File
+		}
+		if res[i].Line != res[j].Line {
+			return res[i].Line < res[j].Line
+		}
+		return res[i].Column < res[j].Column
+	})
+	return res
+}
+
+// clearProblems borra los diagnósticos del workspace, o solo los de un comando
+func clearProblems(root, command string) int {
+	problemsMu.Lock()
+	defer problemsMu.Unlock()
+	n := 0
+	runs := problemRuns(root)
+	for key, diags := range runs {
+		if command == "" || strings.HasSuffix(key, "\x00"+strings.TrimSpace(command)) {
+			n += len(diags)
+			delete(runs, key)
+		}
+	}
+	if n > 0 {
+		saveProblems(root)
+	}
+	return n
+}
+
+type ProblemsRequest struct {
+	Operation      string `json:"operation"` // "parse" o "clear"
+	Command        string `json:"command,omitempty"`
+	Output         string `json:"output,omitempty"`
+	WorkingDir     string `json:"workingDir,omitempty"`
+	ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+}
+
+type ProblemsResponse struct {
+	Success  bool         `json:"success"`
+	Message  string       `json:"message"`
+	Problems []Diagnostic `json:"problems"`
+	Errors   int          `json:"errors"`
+	Warnings int          `json:"warnings"`
+}
+
+func newProblemsResponse(message string, diags []Diagnostic) ProblemsResponse {
+	resp := ProblemsResponse{Success: true, Message: message, Problems: diags}
+	if resp.Problems == nil {
+		resp.Problems = []Diagnostic{}
+	}
+	for _, d := range diags {
+		switch d.Severity {
+		case "error":
+			resp.Errors++
+		case "warning":
+			resp.Warnings++
+		}
+	}
+	return resp
+}
+
+// problemsHandler expone el panel de problemas: GET lista (?file=, ?severity=), POST parse analiza una salida
+// (por ejemplo de una terminal interactiva) y POST clear los borra
+func problemsHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var resp ProblemsResponse
+	switch r.Method {
+	case "GET":
+		q := r.URL.Query()
+		root := workspaceRoot(q.Get("projectBaseDir"))
+		diags := listProblems(root, q.Get("file"), q.Get("severity"))
+		resp = newProblemsResponse(fmt.Sprintf("%d problems", len(diags)), diags)
+	case "POST":
+		var req ProblemsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		root := workspaceRoot(req.ProjectBaseDir)
+		switch req.Operation {
+		case "parse":
+			cwd := root
+			if req.WorkingDir != "" {
+				cwd = resolveWorkspacePath(req.WorkingDir, req.ProjectBaseDir)
+			}
+			diags := recordProblems(root, cwd, req.Command, req.Output)
+			resp = newProblemsResponse(fmt.Sprintf("Found %d problems", len(diags)), diags)
+		case "clear":
+			n := clearProblems(root, req.Command)
+			resp = newProblemsResponse(fmt.Sprintf("Cleared %d problems", n), nil)
+		default:
+			resp = ProblemsResponse{Success: false, Message: "Unknown problems operation: " + req.Operation, Problems: []Diagnostic{}}
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
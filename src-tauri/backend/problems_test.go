@@ -0,0 +1,180 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchProblems(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/app\n\ngo 1.23\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	type want struct {
+		File     string
+		Line     int
+		Column   int
+		Severity string
+		Code     string
+		Message  string
+	}
+	tests := []struct {
+		name    string
+		cwd     string // relativo a root
+		command string
+		output  string
+		want    []want
+	}{
+		{
+			name:    "go build",
+			command: "go build ./...",
+			output:  "# example.com/app/api\napi/server.go:12:5: undefined: handler\n",
+			want:    []want{{"api/server.go", 12, 5, "error", "", "undefined: handler"}},
+		},
+		{
+			name:    "go vet",
+			command: "go vet ./...",
+			output:  "# example.com/app\nvet: ./main.go:7:2: unreachable code\n",
+			want:    []want{{"main.go", 7, 2, "warning", "", "unreachable code"}},
+		},
+		{
+			name:    "go test resolves files per package",
+			command: "go test ./...",
+			output: "--- FAIL: TestA (0.00s)\n    a_test.go:12: got 1\nFAIL\nFAIL\texample.com/app/internal/a\t0.003s\n" +
+				"ok  \texample.com/app/internal/b\t(cached)\n" +
+				"--- FAIL: TestRoot (0.00s)\n    main_test.go:3: boom\nFAIL\nFAIL\texample.com/app\t0.010s\n",
+			want: []want{
+				{"internal/a/a_test.go", 12, 0, "error", "", "got 1"},
+				{"main_test.go", 3, 0, "error", "", "boom"},
+			},
+		},
+		{
+			name:    "go test from a package directory",
+			cwd:     "internal/a",
+			command: "go test -run TestA",
+			output:  "--- FAIL: TestA (0.00s)\n    a_test.go:12: got 1\nFAIL\nexit status 1\nFAIL\texample.com/app/internal/a\t0.003s\n",
+			want:    []want{{"internal/a/a_test.go", 12, 0, "error", "", "got 1"}},
+		},
+		{
+			name:    "go test with build failure",
+			command: "go test ./...",
+			output:  "# example.com/app/internal/a [example.com/app/internal/a.test]\ninternal/a/a_test.go:5:2: undefined: x\nFAIL\texample.com/app/internal/a [build failed]\n",
+			want:    []want{{"internal/a/a_test.go", 5, 2, "error", "", "undefined: x"}},
+		},
+		{
+			name:    "tsc",
+			command: "npx tsc --noEmit",
+			output:  "src/app.ts(3,7): error TS2322: Type 'string' is not assignable to type 'number'.\nsrc/b.ts:1:1 - warning TS6133: 'x' is declared but its value is never read.\n",
+			want: []want{
+				{"src/app.ts", 3, 7, "error", "TS2322", "Type 'string' is not assignable to type 'number'."},
+				{"src/b.ts", 1, 1, "warning", "TS6133", "'x' is declared but its value is never read."},
+			},
+		},
+		{
+			name:    "eslint stylish",
+			command: "eslint src",
+			output:  "src/a.js\n  1:10  error  'x' is defined but never used  no-unused-vars\n\n",
+			want:    []want{{"src/a.js", 1, 10, "error", "no-unused-vars", "'x' is defined but never used"}},
+		},
+		{
+			name:    "eslint compact",
+			command: "eslint -f compact src",
+			output:  "src/a.js: line 2, col 3, Warning - Unexpected console statement. (no-console)\n",
+			want:    []want{{"src/a.js", 2, 3, "warning", "no-console", "Unexpected console statement."}},
+		},
+		{
+			name:    "cargo",
+			command: "cargo build",
+			output:  "error[E0425]: cannot find value `x` in this scope\n --> src/main.rs:4:13\n\nerror: could not compile `app`\n",
+			want:    []want{{"src/main.rs", 4, 13, "error", "E0425", "cannot find value `x` in this scope"}},
+		},
+		{
+			name:    "python traceback",
+			command: "python app.py",
+			output:  "Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\n    main()\n  File \"/usr/lib/python3.12/json/__init__.py\", line 9, in loads\nValueError: bad json\n",
+			want:    []want{{"app.py", 3, 0, "error", "ValueError", "bad json"}},
+		},
+		{
+			name:    "pytest",
+			command: "pytest",
+			output:  "____ test_sum ____\nE       assert 1 == 2\ntests/test_sum.py:4: AssertionError\n",
+			want:    []want{{"tests/test_sum.py", 4, 0, "error", "AssertionError", "assert 1 == 2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags, ok := matchProblems(root, filepath.Join(root, tt.cwd), tt.command, tt.output, false)
+			if !ok {
+				t.Fatalf("command %q was not recognised", tt.command)
+			}
+			var got []want
+			for _, d := range diags {
+				got = append(got, want{d.File, d.Line, d.Column, d.Severity, d.Code, d.Message})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchProblems =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchProblemsIgnoresUnknownCommands(t *testing.T) {
+	if _, ok := matchProblems(t.TempDir(), "", "ls -la", "main.go:1:1: not a diagnostic", false); ok {
+		t.Error("ls output was analysed")
+	}
+}
+
+func TestGoProblemPatternPaths(t *testing.T) {
+	tests := []struct {
+		line, file string
+	}{
+		{`main.go:3:2: undefined: x`, "main.go"},
+		{`./cmd/app/main.go:3:2: undefined: x`, "./cmd/app/main.go"},
+		{`/home/dev/app/main.go:3:2: undefined: x`, "/home/dev/app/main.go"},
+		{`C:\x\main.go:3:2: undefined: x`, `C:\x\main.go`},
+		{`vet: d:\work\app\main.go:3:2: undefined: x`, `d:\work\app\main.go`},
+		{`    main_test.go:3: got 1, want 2`, "main_test.go"},
+	}
+	for _, tt := range tests {
+		m := goProblemPattern.FindStringSubmatch(tt.line)
+		if m == nil || m[1] != tt.file || m[2] != "3" {
+			t.Errorf("goProblemPattern(%q) = %q, want file %q at line 3", tt.line, m, tt.file)
+		}
+	}
+}
+
+func TestProblemsArePersisted(t *testing.T) {
+	root := t.TempDir()
+	t.Cleanup(func() {
+		problemsMu.Lock()
+		delete(workspaceProblems, root)
+		problemsMu.Unlock()
+	})
+	output := "main.go:3:2: undefined: x\n"
+	if diags := recordProblems(root, root, "go build ./...", output); len(diags) != 1 {
+		t.Fatalf("recordProblems = %+v", diags)
+	}
+	if _, err := os.Stat(filepath.Join(root, workspaceConfigDir, problemsFileName)); err != nil {
+		t.Fatalf("problems not saved: %v", err)
+	}
+
+	// Un backend nuevo los vuelve a leer de .airide
+	problemsMu.Lock()
+	delete(workspaceProblems, root)
+	problemsMu.Unlock()
+	diags := listProblems(root, "", "")
+	if len(diags) != 1 || diags[0].File != "main.go" || diags[0].Line != 3 || diags[0].Command != "go build ./..." {
+		t.Fatalf("problems after restart = %+v", diags)
+	}
+
+	// Volver a ejecutar sin errores los reemplaza también en disco
+	recordProblems(root, root, "go build ./...", "")
+	problemsMu.Lock()
+	delete(workspaceProblems, root)
+	problemsMu.Unlock()
+	if diags := listProblems(root, "", ""); len(diags) != 0 {
+		t.Errorf("fixed problems came back after restart: %+v", diags)
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// Cantidad de salida (stdout+stderr) que se conserva por proceso
+	processLogBufferSize = 1 << 20
+	// Espera entre SIGTERM y SIGKILL al detener un proceso
+	processKillGrace = 5 * time.Second
+	// Tiempo que un proceso terminado sigue listado antes de descartarse
+	processExitedRetention = 30 * time.Minute
+	// Tiempo que siguen corriendo los procesos de un workspace sin que el IDE los consulte: si se cierra la
+	// ventana o se abre otro workspace sin avisar, se detienen solos
+	processLeaseTimeout = 2 * time.Minute
+)
+
+// processSignals son las señales que se pueden enviar desde el frontend
+var processSignals = map[string]syscall.Signal{
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+}
+
+// managedProcess es un comando de larga duración (servidor de desarrollo, watcher...) corriendo en segundo plano
+type managedProcess struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Command  string     `json:"command"`
+	Cwd      string     `json:"cwd"`
+	Root     string     `json:"root"`
+	PID      int        `json:"pid"`
+	Status   string     `json:"status"` // "running", "exited" o "killed"
+	ExitCode *int       `json:"exitCode,omitempty"`
+	Started  time.Time  `json:"started"`
+	Ended    *time.Time `json:"ended,omitempty"`
+	Uptime   string     `json:"uptime"`
+
+	cmd     *exec.Cmd
+	mu      sync.Mutex
+	logs    []byte
+	dropped int64 // bytes descartados del inicio del buffer
+	killed  bool
+	done    chan struct{}
+	// release cierra lo que ata el proceso a la vida del backend (el Job Object en Windows)
+	release func()
+}
+
+type ProcessRequest struct {
+	Operation      string `json:"operation"` // "start", "logs", "signal", "kill", "remove", "cleanup" o "heartbeat"
+	ID             string `json:"id,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Command        string `json:"command,omitempty"`
+	WorkingDir     string `json:"workingDir,omitempty"`
+	Signal         string `json:"signal,omitempty"`
+	Since          int64  `json:"since,omitempty"` // offset desde el que devolver logs
+	ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+	Source         string `json:"source,omitempty"`
+	ConfirmToken   string `json:"confirmToken,omitempty"`
+	// Env sobrescribe variables del entorno solo para este proceso
+	Env map[string]string `json:"env,omitempty"`
+}
+
+type ProcessResponse struct {
+	Success   bool              `json:"success"`
+	Message   string            `json:"message"`
+	Process   *managedProcess   `json:"process,omitempty"`
+	Processes []*managedProcess `json:"processes,omitempty"`
+	Logs      string            `json:"logs,omitempty"`
+	Offset    int64             `json:"offset,omitempty"`
+	Truncated bool              `json:"truncated,omitempty"`
+	// Denied/ConfirmationToken se devuelven cuando la política del workspace no deja arrancar el comando
+	Denied            bool   `json:"denied,omitempty"`
+	ConfirmationToken string `json:"confirmationToken,omitempty"`
+}
+
+// policyError es el rechazo de un comando por la política del workspace
+type policyError struct{ auth commandAuthorization }
+
+func (e *policyError) Error() string { return e.auth.Message }
+
+var (
+	processesMu sync.Mutex
+	processes   = map[string]*managedProcess{}
+	// processLeases guarda por workspace la última vez que el IDE preguntó por sus procesos
+	processLeases   = map[string]time.Time{}
+	processWatchdog sync.Once
+)
+
+// processLogWriter agrega la salida al buffer circular del proceso
+type processLogWriter struct{ p *managedProcess }
+
+func (w processLogWriter) Write(data []byte) (int, error) {
+	w.p.mu.Lock()
+	defer w.p.mu.Unlock()
+	w.p.logs = append(w.p.logs, data...)
+	if over := len(w.p.logs) - processLogBufferSize; over > 0 {
+		w.p.logs = append([]byte{}, w.p.logs[over:]...)
+		w.p.dropped += int64(over)
+	}
+	return len(data), nil
+}
+
+// startManagedProcess lanza el comando en su propio grupo de procesos sin esperar a que termine
+func startManagedProcess(req ProcessRequest) (*managedProcess, error) {
+	if strings.TrimSpace(req.Command) == "" {
+		return nil, errors.New("no command provided")
+	}
+	if err := checkEnvOverrides(req.Env); err != nil {
+		return nil, err
+	}
+	root := workspaceRoot(req.ProjectBaseDir)
+	cwd := root
+	if req.WorkingDir != "" {
+		cwd = resolveWorkspacePath(req.WorkingDir, req.ProjectBaseDir)
+	}
+	name := req.Name
+	if name == "" {
+		name = req.Command
+	}
+	treq := TerminalRequest{Command: req.Command, WorkingDir: cwd, ProjectBaseDir: req.ProjectBaseDir, Source: req.Source, ConfirmToken: req.ConfirmToken}
+	auth := authorizeCommand(root, "process", treq)
+	if !auth.Allowed {
+		return nil, &policyError{auth}
+	}
+	p := &managedProcess{ID: randomID(6), Name: name, Command: req.Command, Cwd: cwd, Root: root, Status: "running", done: make(chan struct{})}
+	source := normalizeCommandSource(req.Source)
+	cmdEnv := buildCommandEnv(root, req.Env)
+	if source != "user" {
+		cmdEnv.withoutWorkspaceTools(root)
+	}
+	env := scrubEnv(cmdEnv.list(), auth.policy, source)
+	cmd := buildShellCommand(context.Background(), req.Command, cwd, env)
+	setProcessGroup(cmd)
+	cmd.Stdout = processLogWriter{p}
+	cmd.Stderr = processLogWriter{p}
+	// Los hijos que hereden stdout no deben bloquear Wait una vez muerto el líder
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p.cmd = cmd
+	p.PID = cmd.Process.Pid
+	p.release = bindProcessLifetime(p.PID)
+	p.Started = time.Now()
+	processesMu.Lock()
+	processes[p.ID] = p
+	// Un proceso nuevo tiene el plazo completo aunque el IDE todavía no haya renovado el workspace
+	if _, ok := processLeases[root]; !ok {
+		processLeases[root] = p.Started
+	}
+	processesMu.Unlock()
+	processWatchdog.Do(func() { go watchProcessLeases() })
+	go p.wait()
+	recordAudit(auth.root, AuditEntry{Source: req.Source, Channel: "process", Command: req.Command, WorkingDir: cwd, Decision: auth.decision(), Rule: auth.Rule})
+	fmt.Printf("[BACK] Started background process %s (pid %d): %s\n", p.ID, p.PID, p.Command)
+	return p, nil
+}
+
+func (p *managedProcess) wait() {
+	err := p.cmd.Wait()
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		code = -1
+	}
+	now := time.Now()
+	p.mu.Lock()
+	p.ExitCode = &code
+	p.Ended = &now
+	p.Status = "exited"
+	if p.killed {
+		p.Status = "killed"
+	}
+	logs := string(p.logs)
+	p.mu.Unlock()
+	p.release()
+	close(p.done)
+	recordProblems(p.Root, p.Cwd, p.Command, logs)
+	fmt.Printf("[BACK] Background process %s %s with code %d\n", p.ID, p.Status, code)
+	time.AfterFunc(processExitedRetention, func() { removeManagedProcess(p.ID) })
+}
+
+func (p *managedProcess) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// signal envía la señal a todo el grupo, así también la reciben los hijos (por ejemplo node bajo npm)
+func (p *managedProcess) signal(sig syscall.Signal) error {
+	if !p.running() {
+		return errors.New("process is not running")
+	}
+	if signalStops(sig) {
+		p.mu.Lock()
+		p.killed = true
+		p.mu.Unlock()
+	}
+	return signalProcessGroup(p.PID, sig)
+}
+
+// kill detiene el grupo con SIGTERM y, si no termina a tiempo, con SIGKILL
+func (p *managedProcess) kill() {
+	if p.signal(syscall.SIGTERM) != nil {
+		return
+	}
+	select {
+	case <-p.done:
+	case <-time.After(processKillGrace):
+		signalProcessGroup(p.PID, syscall.SIGKILL)
+		<-p.done
+	}
+}
+
+// readLogs devuelve la salida a partir de since; el offset es absoluto aunque el buffer haya descartado el inicio
+func (p *managedProcess) readLogs(since int64) (string, int64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	end := p.dropped + int64(len(p.logs))
+	truncated := since < p.dropped
+	if truncated {
+		since = p.dropped
+	}
+	if since > end {
+		since = end
+	}
+	return string(p.logs[since-p.dropped:]), end, truncated
+}
+
+// snapshot copia los campos públicos bajo el lock para poder serializarlos
+func (p *managedProcess) snapshot() *managedProcess {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	end := time.Now()
+	if p.Ended != nil {
+		end = *p.Ended
+	}
+	return &managedProcess{ID: p.ID, Name: p.Name, Command: p.Command, Cwd: p.Cwd, Root: p.Root, PID: p.PID, Status: p.Status, ExitCode: p.ExitCode, Started: p.Started, Ended: p.Ended, Uptime: end.Sub(p.Started).Round(time.Second).String()}
+}
+
+func getManagedProcess(id string) *managedProcess {
+	processesMu.Lock()
+	defer processesMu.Unlock()
+	return processes[id]
+}
+
+func removeManagedProcess(id string) {
+	processesMu.Lock()
+	defer processesMu.Unlock()
+	delete(processes, id)
+}
+
+// workspaceProcesses devuelve los procesos del workspace (o todos si root es vacío), los más antiguos primero
+func workspaceProcesses(root string) []*managedProcess {
+	processesMu.Lock()
+	var list []*managedProcess
+	for _, p := range processes {
+		if root == "" || p.Root == root {
+			list = append(list, p)
+		}
+	}
+	processesMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+// cleanupProcesses detiene y descarta todos los procesos del workspace (o de todos si root es vacío)
+func cleanupProcesses(root string) int {
+	list := workspaceProcesses(root)
+	var wg sync.WaitGroup
+	for _, p := range list {
+		wg.Add(1)
+		go func(p *managedProcess) {
+			defer wg.Done()
+			p.kill()
+			removeManagedProcess(p.ID)
+		}(p)
+	}
+	wg.Wait()
+	return len(list)
+}
+
+// renewProcessLease registra que el IDE sigue usando el workspace
+func renewProcessLease(root string) {
+	processesMu.Lock()
+	processLeases[root] = time.Now()
+	processesMu.Unlock()
+}
+
+// stopAbandonedProcesses detiene los procesos de los workspaces cuyo plazo venció y devuelve cuántos detuvo
+func stopAbandonedProcesses(now time.Time) int {
+	processesMu.Lock()
+	var expired []string
+	for root, seen := range processLeases {
+		if now.Sub(seen) > processLeaseTimeout {
+			expired = append(expired, root)
+			delete(processLeases, root)
+		}
+	}
+	processesMu.Unlock()
+	stopped := 0
+	for _, root := range expired {
+		for _, p := range workspaceProcesses(root) {
+			if p.running() {
+				fmt.Printf("[BACK] Stopping process %s: the IDE no longer uses %s\n", p.ID, root)
+				p.kill()
+				stopped++
+			}
+		}
+	}
+	return stopped
+}
+
+func watchProcessLeases() {
+	for range time.Tick(processLeaseTimeout / 4) {
+		stopAbandonedProcesses(time.Now())
+	}
+}
+
+// handleShutdownSignals detiene los procesos en segundo plano y las terminales al cerrar el backend,
+// para no dejar servidores de desarrollo huérfanos ocupando puertos
+func handleShutdownSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-ch
+		fmt.Printf("[BACK] Received %v, stopping background processes\n", sig)
+		cleanupProcesses("")
+		ptySessionsMu.Lock()
+		var sessions []*ptySession
+		for _, s := range ptySessions {
+			sessions = append(sessions, s)
+		}
+		ptySessionsMu.Unlock()
+		for _, s := range sessions {
+			s.close()
+		}
+		stopLanguageServers()
+		stopDebugSessions()
+		os.Exit(0)
+	}()
+}
+
+func processesHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var resp ProcessResponse
+	switch r.Method {
+	case "GET":
+		root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+		if isUIRequest(r) {
+			renewProcessLease(root)
+		}
+		list := workspaceProcesses(root)
+		for i, p := range list {
+			list[i] = p.snapshot()
+		}
+		resp = ProcessResponse{Success: true, Message: "Processes listed successfully", Processes: list}
+	case "POST":
+		var req ProcessRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Source = requestSource(r, req.Source)
+		if isUIRequest(r) {
+			renewProcessLease(workspaceRoot(req.ProjectBaseDir))
+		}
+		fmt.Printf("[BACK] ProcessRequest: operation=%s id=%s command='%s'\n", req.Operation, req.ID, req.Command)
+		resp = handleProcessRequest(req)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func handleProcessRequest(req ProcessRequest) ProcessResponse {
+	switch req.Operation {
+	case "start":
+		p, err := startManagedProcess(req)
+		var perr *policyError
+		if errors.As(err, &perr) {
+			return ProcessResponse{Success: false, Message: perr.Error(), Denied: perr.auth.Denied, ConfirmationToken: perr.auth.ConfirmationToken}
+		}
+		if err != nil {
+			return ProcessResponse{Success: false, Message: "Error starting process: " + err.Error()}
+		}
+		return ProcessResponse{Success: true, Message: "Process started", Process: p.snapshot()}
+	case "cleanup":
+		n := cleanupProcesses(workspaceRoot(req.ProjectBaseDir))
+		return ProcessResponse{Success: true, Message: fmt.Sprintf("Stopped %d processes", n)}
+	case "heartbeat":
+		// La renovación la hace processesHandler, que es quien sabe si la petición viene del IDE
+		return ProcessResponse{Success: true, Message: "Workspace processes kept alive"}
+	}
+
+	p := getManagedProcess(req.ID)
+	if p == nil {
+		return ProcessResponse{Success: false, Message: "Process not found: " + req.ID}
+	}
+	switch req.Operation {
+	case "logs":
+		logs, offset, truncated := p.readLogs(req.Since)
+		return ProcessResponse{Success: true, Message: "Logs read successfully", Process: p.snapshot(), Logs: logs, Offset: offset, Truncated: truncated}
+	case "signal":
+		sig, ok := processSignals[strings.ToUpper(req.Signal)]
+		if !ok {
+			return ProcessResponse{Success: false, Message: "Unsupported signal: " + req.Signal}
+		}
+		if err := p.signal(sig); err != nil {
+			return ProcessResponse{Success: false, Message: err.Error()}
+		}
+		return ProcessResponse{Success: true, Message: "Signal sent", Process: p.snapshot()}
+	case "kill":
+		p.kill()
+		return ProcessResponse{Success: true, Message: "Process stopped", Process: p.snapshot()}
+	case "remove":
+		if p.running() {
+			return ProcessResponse{Success: false, Message: "Process is still running; kill it first"}
+		}
+		removeManagedProcess(p.ID)
+		return ProcessResponse{Success: true, Message: "Process removed"}
+	}
+	return ProcessResponse{Success: false, Message: "Unknown process operation: " + req.Operation}
+}
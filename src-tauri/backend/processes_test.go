@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startTestProcess arranca un proceso en segundo plano en un workspace propio del test
+func startTestProcess(t *testing.T, command string) *managedProcess {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, workspaceConfigDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	p, err := startManagedProcess(ProcessRequest{Command: command, ProjectBaseDir: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cleanupProcesses(root) })
+	return p
+}
+
+func waitProcess(t *testing.T, p *managedProcess) {
+	t.Helper()
+	select {
+	case <-p.done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("process %s still running", p.ID)
+	}
+}
+
+func TestSignalStatus(t *testing.T) {
+	tests := []struct {
+		signal string
+		want   string
+	}{
+		{"SIGTERM", "killed"},
+		{"SIGKILL", "killed"},
+		// sleep muere con SIGINT, pero en Unix es una señal que el proceso puede atender
+		{"SIGINT", "exited"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.signal, func(t *testing.T) {
+			p := startTestProcess(t, "sleep 30")
+			if resp := handleProcessRequest(ProcessRequest{Operation: "signal", ID: p.ID, Signal: tt.signal}); !resp.Success {
+				t.Fatal(resp.Message)
+			}
+			waitProcess(t, p)
+			if got := p.snapshot().Status; got != tt.want {
+				t.Errorf("status after %s = %q, want %q", tt.signal, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignalStops(t *testing.T) {
+	// En Windows cualquier señal termina el árbol con taskkill /F o el Job Object
+	windows := runtime.GOOS == "windows"
+	tests := []struct {
+		sig  syscall.Signal
+		want bool
+	}{
+		{syscall.SIGKILL, true},
+		{syscall.SIGTERM, true},
+		{syscall.SIGINT, windows},
+		{syscall.SIGHUP, windows},
+	}
+	for _, tt := range tests {
+		if got := signalStops(tt.sig); got != tt.want {
+			t.Errorf("signalStops(%v) = %v, want %v", tt.sig, got, tt.want)
+		}
+	}
+}
+
+func TestAbandonedProcessesAreStopped(t *testing.T) {
+	abandoned := startTestProcess(t, "sleep 30")
+	active := startTestProcess(t, "sleep 30")
+
+	processesMu.Lock()
+	processLeases[abandoned.Root] = time.Now().Add(-processLeaseTimeout - time.Second)
+	processesMu.Unlock()
+	renewProcessLease(active.Root)
+
+	if n := stopAbandonedProcesses(time.Now()); n != 1 {
+		t.Errorf("stopped %d processes, want 1", n)
+	}
+	waitProcess(t, abandoned)
+	if got := abandoned.snapshot().Status; got != "killed" {
+		t.Errorf("abandoned process status = %q", got)
+	}
+	if !active.running() {
+		t.Error("process of a workspace in use was stopped")
+	}
+}
+
+func TestHeartbeatNeedsUIToken(t *testing.T) {
+	uiToken = "secret"
+	defer func() { uiToken = "" }()
+	root := t.TempDir()
+	lease := func() time.Time {
+		processesMu.Lock()
+		defer processesMu.Unlock()
+		return processLeases[root]
+	}
+	t.Cleanup(func() {
+		processesMu.Lock()
+		delete(processLeases, root)
+		processesMu.Unlock()
+	})
+	body := `{"operation":"heartbeat","projectBaseDir":"` + filepath.ToSlash(root) + `"}`
+
+	processesHandler(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/processes", strings.NewReader(body)))
+	if !lease().IsZero() {
+		t.Fatal("heartbeat without the UI token renewed the workspace")
+	}
+	r := httptest.NewRequest("POST", "/api/processes", strings.NewReader(body))
+	r.Header.Set(uiTokenHeader, "secret")
+	w := httptest.NewRecorder()
+	processesHandler(w, r)
+	if lease().IsZero() || !strings.Contains(w.Body.String(), `"success":true`) {
+		t.Errorf("heartbeat from the IDE = %s, lease %v", w.Body.String(), lease())
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup hace que el comando sea líder de su propio grupo, para poder señalizar también a sus hijos
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcessGroup envía la señal a todo el grupo de procesos cuyo líder es pid
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	return syscall.Kill(-pid, sig)
+}
+
+// bindProcessLifetime no hace nada fuera de Windows: el grupo de procesos se detiene en handleShutdownSignals
+func bindProcessLifetime(pid int) func() { return func() {} }
+
+// signalStops indica si la señal detiene el proceso; SIGINT, SIGHUP y SIGQUIT los puede atender y seguir corriendo
+func signalStops(sig syscall.Signal) bool {
+	return sig == syscall.SIGKILL || sig == syscall.SIGTERM
+}
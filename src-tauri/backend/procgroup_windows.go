@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// setProcessGroup crea el proceso en un grupo nuevo de consola
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+var (
+	processJobsMu sync.Mutex
+	processJobs   = map[int]windows.Handle{}
+)
+
+// bindProcessLifetime mete el proceso en un Job Object con KILL_ON_JOB_CLOSE: si el backend muere sin pasar por
+// handleShutdownSignals, Windows cierra el handle y termina todo el árbol. Los hijos que el proceso cree antes
+// de asignarlo al job quedan fuera; taskkill /T sigue cubriéndolos al detenerlo desde el IDE.
+// La función devuelta cierra el job cuando el proceso ya terminó.
+func bindProcessLifetime(pid int) func() {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return func() {}
+	}
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		windows.CloseHandle(job)
+		return func() {}
+	}
+	proc, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return func() {}
+	}
+	defer windows.CloseHandle(proc)
+	if err := windows.AssignProcessToJobObject(job, proc); err != nil {
+		windows.CloseHandle(job)
+		return func() {}
+	}
+	processJobsMu.Lock()
+	processJobs[pid] = job
+	processJobsMu.Unlock()
+	return func() {
+		processJobsMu.Lock()
+		delete(processJobs, pid)
+		processJobsMu.Unlock()
+		windows.CloseHandle(job)
+	}
+}
+
+// signalStops indica si la señal detiene el proceso: en Windows todas lo matan
+func signalStops(sig syscall.Signal) bool { return true }
+
+// signalProcessGroup termina el árbol de procesos; Windows no tiene señales, así que cualquier señal mata
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	processJobsMu.Lock()
+	job, ok := processJobs[pid]
+	processJobsMu.Unlock()
+	if ok && windows.TerminateJobObject(job, 1) == nil {
+		return nil
+	}
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run(); err != nil {
+		p, findErr := os.FindProcess(pid)
+		if findErr != nil {
+			return findErr
+		}
+		return p.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Salida reciente que se reenvía a un cliente que se (re)conecta a la sesión
+	ptyScrollbackSize = 256 * 1024
+	// Tiempo que una sesión terminada sigue listada para que el cliente vea su código de salida
+	ptyExitedRetention = 5 * time.Minute
+	defaultPTYCols     = 80
+	defaultPTYRows     = 24
+)
+
+const ptyUserOnlyMessage = "Interactive terminals are only available to the user; run commands through /terminal"
+
+// ptySession es una shell interactiva corriendo en una pseudo-terminal
+type ptySession struct {
+	ID       string    `json:"id"`
+	Root     string    `json:"root"`
+	Shell    string    `json:"shell"`
+	Cwd      string    `json:"cwd"`
+	PID      int       `json:"pid"`
+	Cols     uint16    `json:"cols"`
+	Rows     uint16    `json:"rows"`
+	Started  time.Time `json:"started"`
+	Exited   bool      `json:"exited"`
+	ExitCode int       `json:"exitCode"`
+
+	master      *os.File
+	cmd         *exec.Cmd
+	mu          sync.Mutex
+	scrollback  []byte
+	subscribers map[chan []byte]struct{}
+	readDone    chan struct{}
+	done        chan struct{}
+}
+
+type PTYRequest struct {
+	Operation      string `json:"operation"` // "create", "input", "resize" o "close"
+	ID             string `json:"id,omitempty"`
+	Shell          string `json:"shell,omitempty"`
+	Cwd            string `json:"cwd,omitempty"`
+	Cols           uint16 `json:"cols,omitempty"`
+	Rows           uint16 `json:"rows,omitempty"`
+	Data           string `json:"data,omitempty"`
+	ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+}
+
+type PTYResponse struct {
+	Success   bool          `json:"success"`
+	Message   string        `json:"message"`
+	Session   *ptySession   `json:"session,omitempty"`
+	Sessions  []*ptySession `json:"sessions,omitempty"`
+	Supported bool          `json:"supported"`
+}
+
+var (
+	ptySessionsMu sync.Mutex
+	ptySessions   = map[string]*ptySession{}
+)
+
+// randomID genera un identificador aleatorio en hexadecimal de n bytes
+func randomID(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// createPTYSession arranca una shell en una pseudo-terminal nueva
+func createPTYSession(req PTYRequest) (*ptySession, error) {
+	shell := req.Shell
+	if shell == "" {
+		shell = os.Getenv("SHELL")
+	}
+	if shell == "" {
+		shell = "/bin/bash"
+	}
+	cols, rows := req.Cols, req.Rows
+	if cols == 0 {
+		cols = defaultPTYCols
+	}
+	if rows == 0 {
+		rows = defaultPTYRows
+	}
+	root := workspaceRoot(req.ProjectBaseDir)
+	cwd := root
+	if req.Cwd != "" {
+		cwd = resolveWorkspacePath(req.Cwd, req.ProjectBaseDir)
+	}
+
+	cmd := exec.Command(shell)
+	cmd.Dir = cwd
+	env := buildCommandEnv(root, nil)
+	env.set("TERM", "xterm-256color", "override")
+	env.set("COLORTERM", "truecolor", "override")
+	cmd.Env = env.list()
+	master, err := startPTY(cmd, cols, rows)
+	if err != nil {
+		return nil, err
+	}
+	s := &ptySession{
+		ID:          randomID(8),
+		Root:        root,
+		Shell:       shell,
+		Cwd:         cwd,
+		PID:         cmd.Process.Pid,
+		Cols:        cols,
+		Rows:        rows,
+		Started:     time.Now(),
+		master:      master,
+		cmd:         cmd,
+		subscribers: map[chan []byte]struct{}{},
+		readDone:    make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	ptySessionsMu.Lock()
+	ptySessions[s.ID] = s
+	ptySessionsMu.Unlock()
+	go s.readLoop()
+	go s.wait()
+	fmt.Printf("[BACK] PTY session %s started: %s (pid %d) in '%s'\n", s.ID, shell, s.PID, cwd)
+	return s, nil
+}
+
+// readLoop copia la salida de la terminal al scrollback y a los clientes conectados
+func (s *ptySession) readLoop() {
+	defer close(s.readDone)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.master.Read(buf)
+		if n > 0 {
+			chunk := append([]byte{}, buf[:n]...)
+			s.mu.Lock()
+			s.scrollback = append(s.scrollback, chunk...)
+			if len(s.scrollback) > ptyScrollbackSize {
+				s.scrollback = append([]byte{}, s.scrollback[len(s.scrollback)-ptyScrollbackSize:]...)
+			}
+			for ch := range s.subscribers {
+				select {
+				case ch <- chunk:
+				default:
+					// Un cliente demasiado lento se desconecta; al reconectar recibe el scrollback
+					delete(s.subscribers, ch)
+					close(ch)
+				}
+			}
+			s.mu.Unlock()
+		}
+		if err != nil {
+			// Al terminar la shell el maestro devuelve EIO
+			return
+		}
+	}
+}
+
+// wait espera a que termine la shell y avisa a los clientes
+func (s *ptySession) wait() {
+	err := s.cmd.Wait()
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	}
+	// Dar tiempo a leer la última salida; un hijo en segundo plano puede mantener la terminal abierta
+	select {
+	case <-s.readDone:
+	case <-time.After(500 * time.Millisecond):
+	}
+	s.mu.Lock()
+	s.Exited = true
+	s.ExitCode = code
+	s.mu.Unlock()
+	close(s.done)
+	s.master.Close()
+	fmt.Printf("[BACK] PTY session %s exited with code %d\n", s.ID, code)
+	time.AfterFunc(ptyExitedRetention, func() { removePTYSession(s.ID) })
+}
+
+// subscribe registra un cliente y devuelve la salida acumulada hasta ahora
+func (s *ptySession) subscribe() (chan []byte, []byte) {
+	ch := make(chan []byte, 256)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers[ch] = struct{}{}
+	return ch, append([]byte{}, s.scrollback...)
+}
+
+func (s *ptySession) unsubscribe(ch chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscribers[ch]; ok {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (s *ptySession) resize(cols, rows uint16) error {
+	if cols == 0 || rows == 0 {
+		return fmt.Errorf("invalid size %dx%d", cols, rows)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Exited {
+		return fmt.Errorf("session has exited")
+	}
+	if err := resizePTY(s.master, cols, rows); err != nil {
+		return err
+	}
+	s.Cols, s.Rows = cols, rows
+	return nil
+}
+
+func (s *ptySession) write(data []byte) error {
+	select {
+	case <-s.done:
+		return fmt.Errorf("session has exited")
+	default:
+	}
+	_, err := s.master.Write(data)
+	return err
+}
+
+// close cuelga la terminal (SIGHUP al grupo de procesos) y, si la shell no sale, la mata
+func (s *ptySession) close() {
+	select {
+	case <-s.done:
+	default:
+		signalProcessGroup(s.PID, syscall.SIGHUP)
+		select {
+		case <-s.done:
+		case <-time.After(2 * time.Second):
+			signalProcessGroup(s.PID, syscall.SIGKILL)
+		}
+	}
+	removePTYSession(s.ID)
+}
+
+func getPTYSession(id string) *ptySession {
+	ptySessionsMu.Lock()
+	defer ptySessionsMu.Unlock()
+	return ptySessions[id]
+}
+
+func removePTYSession(id string) {
+	ptySessionsMu.Lock()
+	defer ptySessionsMu.Unlock()
+	delete(ptySessions, id)
+}
+
+// snapshot copia los campos públicos bajo el lock para poder serializarlos
+func (s *ptySession) snapshot() *ptySession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &ptySession{ID: s.ID, Root: s.Root, Shell: s.Shell, Cwd: s.Cwd, PID: s.PID, Cols: s.Cols, Rows: s.Rows, Started: s.Started, Exited: s.Exited, ExitCode: s.ExitCode}
+}
+
+// listPTYSessions devuelve las sesiones del workspace, las más antiguas primero
+func listPTYSessions(root string) []*ptySession {
+	ptySessionsMu.Lock()
+	var list []*ptySession
+	for _, s := range ptySessions {
+		if s.Root == root {
+			list = append(list, s)
+		}
+	}
+	ptySessionsMu.Unlock()
+	for i, s := range list {
+		list[i] = s.snapshot()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+// ptyHandler gestiona las sesiones: GET las lista y POST las crea, escribe, redimensiona o cierra
+func ptyHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var resp PTYResponse
+	switch r.Method {
+	case "GET":
+		root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+		resp = PTYResponse{Success: true, Message: "Sessions listed successfully", Sessions: listPTYSessions(root)}
+	case "POST":
+		// Lo que se escribe en una terminal interactiva no pasa por la política de comandos,
+		// así que solo la interfaz del usuario puede crear sesiones o escribir en ellas
+		if !isUIRequest(r) {
+			http.Error(w, ptyUserOnlyMessage, http.StatusForbidden)
+			return
+		}
+		var req PTYRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("[BACK] PTYRequest: operation=%s id=%s\n", req.Operation, req.ID)
+		if req.Operation == "create" {
+			s, err := createPTYSession(req)
+			if err != nil {
+				resp = PTYResponse{Success: false, Message: "Error starting terminal: " + err.Error()}
+			} else {
+				resp = PTYResponse{Success: true, Message: "Session created", Session: s.snapshot()}
+			}
+			break
+		}
+		s := getPTYSession(req.ID)
+		if s == nil {
+			resp = PTYResponse{Success: false, Message: "Session not found: " + req.ID}
+			break
+		}
+		var err error
+		switch req.Operation {
+		case "input":
+			err = s.write([]byte(req.Data))
+		case "resize":
+			err = s.resize(req.Cols, req.Rows)
+		case "close":
+			s.close()
+		default:
+			err = fmt.Errorf("unknown operation: %s", req.Operation)
+		}
+		if err != nil {
+			resp = PTYResponse{Success: false, Message: err.Error()}
+		} else {
+			resp = PTYResponse{Success: true, Message: "OK", Session: s.snapshot()}
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp.Supported = ptySupported
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ptyWebSocketHandler conecta un cliente a una sesión (?id=...).
+// La salida llega como mensajes binarios; el cliente envía teclas como mensajes binarios
+// o JSON de texto: {"type":"input","data":"ls\r"} y {"type":"resize","cols":120,"rows":40}.
+func ptyWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	if !isUIRequest(r) {
+		http.Error(w, ptyUserOnlyMessage, http.StatusForbidden)
+		return
+	}
+	s := getPTYSession(r.URL.Query().Get("id"))
+	if s == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Println("[BACK] WebSocket upgrade failed:", err)
+		return
+	}
+	defer conn.Close()
+	ch, scrollback := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	go func() {
+		for {
+			kind, msg, err := conn.ReadMessage()
+			if err != nil {
+				s.unsubscribe(ch)
+				return
+			}
+			if kind == websocket.BinaryMessage {
+				s.write(msg)
+				continue
+			}
+			var ctrl struct {
+				Type string `json:"type"`
+				Data string `json:"data"`
+				Cols uint16 `json:"cols"`
+				Rows uint16 `json:"rows"`
+			}
+			if json.Unmarshal(msg, &ctrl) != nil {
+				continue
+			}
+			switch ctrl.Type {
+			case "input":
+				s.write([]byte(ctrl.Data))
+			case "resize":
+				s.resize(ctrl.Cols, ctrl.Rows)
+			}
+		}
+	}()
+
+	if len(scrollback) > 0 {
+		if err := conn.WriteMessage(websocket.BinaryMessage, scrollback); err != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case chunk, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := conn.WriteMessage(websocket.BinaryMessage, chunk); err != nil {
+				return
+			}
+		case <-s.done:
+			// Vaciar lo que quede antes de avisar la salida
+		drain:
+			for {
+				select {
+				case chunk, ok := <-ch:
+					if !ok {
+						break drain
+					}
+					conn.WriteMessage(websocket.BinaryMessage, chunk)
+				default:
+					break drain
+				}
+			}
+			snap := s.snapshot()
+			conn.WriteJSON(map[string]interface{}{"type": "exit", "exitCode": snap.ExitCode})
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
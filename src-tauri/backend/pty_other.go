@@ -0,0 +1,22 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// ptySupported indica si esta plataforma puede abrir pseudo-terminales
+const ptySupported = false
+
+var errPTYUnsupported = errors.New("PTY sessions are only supported on Linux")
+
+func startPTY(cmd *exec.Cmd, cols, rows uint16) (*os.File, error) {
+	return nil, errPTYUnsupported
+}
+
+func resizePTY(master *os.File, cols, rows uint16) error {
+	return errPTYUnsupported
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Máximo de archivos modificables en una sola operación de reemplazo
+const maxReplaceFiles = 5000
+
+type ReplaceRequest struct {
+	Operation      string             `json:"operation"` // "preview" o "apply"
+	Query          string             `json:"query"`
+	Replacement    string             `json:"replacement"`
+	Regex          bool               `json:"regex,omitempty"`
+	CaseSensitive  bool               `json:"caseSensitive,omitempty"`
+	WholeWord      bool               `json:"wholeWord,omitempty"`
+	Include        []string           `json:"include,omitempty"`
+	Exclude        []string           `json:"exclude,omitempty"`
+	NoIgnore       bool               `json:"noIgnore,omitempty"`
+	Selections     []ReplaceSelection `json:"selections,omitempty"`
+	ProjectBaseDir string             `json:"projectBaseDir,omitempty"`
+}
+
+// ReplaceSelection indica qué coincidencias de un archivo aplicar; Matches vacío aplica todas.
+// Los índices de Matches son los de la vista previa, así que Matches exige el Hash que la vista previa devolvió.
+type ReplaceSelection struct {
+	Path    string `json:"path"`
+	Matches []int  `json:"matches,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+}
+
+type ReplaceChange struct {
+	Index       int    `json:"index"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	Match       string `json:"match"`
+	Replacement string `json:"replacement"`
+	LineText    string `json:"lineText"`
+	NewLineText string `json:"newLineText"`
+}
+
+type ReplaceFilePreview struct {
+	Path    string          `json:"path"`
+	Hash    string          `json:"hash"`
+	Changes []ReplaceChange `json:"changes"`
+}
+
+type ReplaceFileResult struct {
+	Path         string `json:"path"`
+	Replacements int    `json:"replacements"`
+}
+
+type ReplaceResponse struct {
+	Success      bool                 `json:"success"`
+	Message      string               `json:"message"`
+	Files        []ReplaceFilePreview `json:"files,omitempty"`
+	Applied      []ReplaceFileResult  `json:"applied,omitempty"`
+	TotalChanges int                  `json:"totalChanges"`
+	RolledBack   bool                 `json:"rolledBack,omitempty"`
+}
+
+// contentHash identifica una versión concreta del contenido de un archivo
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// replacementTemplate adapta el texto de reemplazo para regexp.Expand; en modo literal '$' no expande grupos
+func replacementTemplate(replacement string, isRegex bool) string {
+	if isRegex {
+		return replacement
+	}
+	return strings.ReplaceAll(replacement, "$", "$$")
+}
+
+// computeReplacements aplica las coincidencias seleccionadas (nil = todas) y describe cada cambio
+func computeReplacements(re *regexp.Regexp, template, content string, selected map[int]bool) (string, []ReplaceChange) {
+	var out strings.Builder
+	var changes []ReplaceChange
+	last := 0
+	line := 1
+	lineScanPos := 0
+	for i, loc := range re.FindAllStringSubmatchIndex(content, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		line += strings.Count(content[lineScanPos:loc[0]], "\n")
+		lineScanPos = loc[0]
+		if selected != nil && !selected[i] {
+			continue
+		}
+		repl := string(re.ExpandString(nil, template, content, loc))
+		lineStart := strings.LastIndexByte(content[:loc[0]], '\n') + 1
+		lineEnd := strings.IndexByte(content[loc[0]:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += loc[0]
+		}
+		lineText := strings.TrimSuffix(content[lineStart:lineEnd], "\r")
+		matchEnd := loc[1]
+		if matchEnd > lineStart+len(lineText) {
+			matchEnd = lineStart + len(lineText)
+		}
+		changes = append(changes, ReplaceChange{
+			Index:       i,
+			Line:        line,
+			Column:      utf8.RuneCountInString(content[lineStart:loc[0]]) + 1,
+			Match:       content[loc[0]:loc[1]],
+			Replacement: repl,
+			LineText:    lineText,
+			NewLineText: content[lineStart:loc[0]] + repl + content[matchEnd:lineStart+len(lineText)],
+		})
+		out.WriteString(content[last:loc[0]])
+		out.WriteString(repl)
+		last = loc[1]
+	}
+	out.WriteString(content[last:])
+	return out.String(), changes
+}
+
+// previewReplace recorre el workspace y devuelve los cambios que produciría el reemplazo
+func previewReplace(req ReplaceRequest, re *regexp.Regexp, template string) ReplaceResponse {
+	include, err := compileGlobs(req.Include)
+	if err != nil {
+		return ReplaceResponse{Success: false, Message: "Invalid include pattern: " + err.Error()}
+	}
+	exclude, err := compileGlobs(req.Exclude)
+	if err != nil {
+		return ReplaceResponse{Success: false, Message: "Invalid exclude pattern: " + err.Error()}
+	}
+	resp := ReplaceResponse{Success: true, Message: "Replace preview generated"}
+	root := workspaceRoot(req.ProjectBaseDir)
+	opts := walkOptions{include: include, exclude: exclude, noIgnore: req.NoIgnore}
+	walkWorkspaceFiles(root, opts, func(absPath, relPath string, info fs.FileInfo) error {
+		if info.Size() > maxSearchFileSize {
+			return nil
+		}
+		content, err := os.ReadFile(absPath)
+		if err != nil || isBinaryContent(content) {
+			return nil
+		}
+		_, changes := computeReplacements(re, template, string(content), nil)
+		if len(changes) == 0 {
+			return nil
+		}
+		resp.Files = append(resp.Files, ReplaceFilePreview{Path: relPath, Hash: contentHash(content), Changes: changes})
+		resp.TotalChanges += len(changes)
+		if len(resp.Files) >= maxReplaceFiles {
+			resp.Message = fmt.Sprintf("Replace preview truncated to %d files", maxReplaceFiles)
+			return errStopWalk
+		}
+		return nil
+	})
+	return resp
+}
+
+// pendingReplace es un archivo ya validado y listo para escribirse
+type pendingReplace struct {
+	path     string
+	relPath  string
+	original []byte
+	updated  string
+	mode     os.FileMode
+	changes  int
+}
+
+// writeReplacedFile escribe cada archivo modificado; los tests lo reemplazan para probar la reversión
+var writeReplacedFile = atomicWriteFile
+
+// applyReplace valida todas las selecciones, escribe los archivos y revierte todo si alguna escritura falla
+func applyReplace(req ReplaceRequest, re *regexp.Regexp, template string) ReplaceResponse {
+	if len(req.Selections) == 0 {
+		return ReplaceResponse{Success: false, Message: "No files selected"}
+	}
+	if len(req.Selections) > maxReplaceFiles {
+		return ReplaceResponse{Success: false, Message: fmt.Sprintf("Too many files selected (max %d)", maxReplaceFiles)}
+	}
+	paths := make([]string, len(req.Selections))
+	for i, sel := range req.Selections {
+		paths[i] = resolveWorkspacePath(sel.Path, req.ProjectBaseDir)
+	}
+	// Nadie más puede escribir estos archivos entre la comprobación del hash y la escritura
+	defer lockPaths(paths...)()
+	var pending []pendingReplace
+	for i, sel := range req.Selections {
+		path := paths[i]
+		if len(sel.Matches) > 0 && sel.Hash == "" {
+			return ReplaceResponse{Success: false, Message: "Selected matches need the file hash from the preview: " + sel.Path}
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return ReplaceResponse{Success: false, Message: "Error reading file " + sel.Path + ": " + err.Error()}
+		}
+		// Igual que en la vista previa, los archivos binarios o demasiado grandes no se tocan
+		if info.Size() > maxSearchFileSize {
+			fmt.Printf("[BACK] Skipping %s in replace: file too large\n", path)
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return ReplaceResponse{Success: false, Message: "Error reading file " + sel.Path + ": " + err.Error()}
+		}
+		if isBinaryContent(content) {
+			fmt.Printf("[BACK] Skipping %s in replace: binary file\n", path)
+			continue
+		}
+		if sel.Hash != "" && sel.Hash != contentHash(content) {
+			return ReplaceResponse{Success: false, Message: "File changed since preview: " + sel.Path}
+		}
+		var selected map[int]bool
+		if len(sel.Matches) > 0 {
+			selected = map[int]bool{}
+			for _, idx := range sel.Matches {
+				selected[idx] = true
+			}
+		}
+		updated, changes := computeReplacements(re, template, string(content), selected)
+		if len(changes) == 0 {
+			continue
+		}
+		pending = append(pending, pendingReplace{
+			path:     path,
+			relPath:  sel.Path,
+			original: content,
+			updated:  updated,
+			mode:     info.Mode().Perm(),
+			changes:  len(changes),
+		})
+	}
+
+	resp := ReplaceResponse{Success: true, Message: "Replacements applied successfully"}
+	for i, p := range pending {
+		if err := writeReplacedFile(p.path, []byte(p.updated), p.mode); err != nil {
+			fmt.Printf("[BACK] Error writing %s, rolling back %d files: %v\n", p.path, i, err)
+			// Restaurar el contenido original de los archivos ya escritos (incluido el que falló)
+			for _, done := range pending[:i+1] {
+				if rbErr := writeReplacedFile(done.path, done.original, done.mode); rbErr != nil {
+					fmt.Printf("[BACK] Rollback failed for %s: %v\n", done.path, rbErr)
+				}
+			}
+			return ReplaceResponse{
+				Success:    false,
+				Message:    "Error writing file " + p.relPath + ": " + err.Error(),
+				RolledBack: true,
+			}
+		}
+		resp.Applied = append(resp.Applied, ReplaceFileResult{Path: p.relPath, Replacements: p.changes})
+		resp.TotalChanges += p.changes
+	}
+	// Como al guardar, el historial local guarda lo que había y el resultado, así el reemplazo se puede deshacer
+	root := workspaceRoot(req.ProjectBaseDir)
+	for _, p := range pending {
+		recordHistorySnapshot(root, p.path, p.original, "external")
+		recordHistorySnapshot(root, p.path, []byte(p.updated), "replace")
+		notifyLanguageServers(lspFileChanged, p.path)
+	}
+	return resp
+}
+
+// selectsWorkspaceConfig indica si el reemplazo tocaría algún archivo de .airide
+func selectsWorkspaceConfig(req ReplaceRequest) bool {
+	for _, sel := range req.Selections {
+		if isWorkspaceConfigPath(resolveWorkspacePath(sel.Path, req.ProjectBaseDir)) {
+			return true
+		}
+	}
+	return false
+}
+
+func replaceHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	fmt.Println("[BACK] /api/replace endpoint hit")
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ReplaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fmt.Println("[BACK] Error decoding replace request:", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	fmt.Printf("[BACK] ReplaceRequest: operation=%s query=%q files=%d\n", req.Operation, req.Query, len(req.Selections))
+
+	var resp ReplaceResponse
+	re, err := compileSearchPattern(req.Query, req.Regex, req.CaseSensitive, req.WholeWord)
+	if err != nil {
+		resp = ReplaceResponse{Success: false, Message: err.Error()}
+	} else {
+		template := replacementTemplate(req.Replacement, req.Regex)
+		switch req.Operation {
+		case "", "preview":
+			resp = previewReplace(req, re, template)
+		case "apply":
+			if !isUIRequest(r) && selectsWorkspaceConfig(req) {
+				resp = ReplaceResponse{Success: false, Message: workspaceConfigReadOnly}
+				break
+			}
+			resp = applyReplace(req, re, template)
+		default:
+			resp = ReplaceResponse{Success: false, Message: "Unknown replace operation: " + req.Operation}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
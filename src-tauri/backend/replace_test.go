@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComputeReplacements(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		replacement string
+		regex       bool
+		content     string
+		selected    map[int]bool
+		want        string
+	}{
+		{"literal", "foo", "bar", false, "foo foo\n", nil, "bar bar\n"},
+		{"group expansion", `(\w+)@example\.com`, "$1@test.dev", true, "ana@example.com, bo@example.com", nil, "ana@test.dev, bo@test.dev"},
+		{"named group", `(?P<key>\w+)=1`, "${key}=2", true, "a=1 b=1", nil, "a=2 b=2"},
+		{"escaped dollar", `price (\d+)`, "$$$1", true, "price 10", nil, "$10"},
+		{"dollar is literal outside regex", "x", "$1", false, "x", nil, "$1"},
+		{"selected subset", "foo", "bar", false, "foo foo foo", map[int]bool{1: true}, "foo bar foo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := compileSearchPattern(tt.query, tt.regex, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := computeReplacements(re, replacementTemplate(tt.replacement, tt.regex), tt.content, tt.selected)
+			if got != tt.want {
+				t.Errorf("computeReplacements = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyReplace(t *testing.T) {
+	re, err := compileSearchPattern("foo", false, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := replacementTemplate("bar", false)
+
+	t.Run("selected matches", func(t *testing.T) {
+		root := t.TempDir()
+		writeTestFile(t, filepath.Join(root, "a.txt"), "foo foo foo\n")
+		preview := previewReplace(ReplaceRequest{ProjectBaseDir: root}, re, template)
+		if len(preview.Files) != 1 || len(preview.Files[0].Changes) != 3 {
+			t.Fatalf("preview = %+v", preview)
+		}
+		sel := ReplaceSelection{Path: "a.txt", Matches: []int{preview.Files[0].Changes[2].Index}}
+		// Sin el hash de la vista previa los índices no identifican nada
+		if resp := applyReplace(ReplaceRequest{Selections: []ReplaceSelection{sel}, ProjectBaseDir: root}, re, template); resp.Success {
+			t.Fatal("matches applied without the preview hash")
+		}
+		sel.Hash = preview.Files[0].Hash
+		resp := applyReplace(ReplaceRequest{Selections: []ReplaceSelection{sel}, ProjectBaseDir: root}, re, template)
+		if !resp.Success || resp.TotalChanges != 1 {
+			t.Fatalf("apply = %+v", resp)
+		}
+		if got := readTestFile(t, filepath.Join(root, "a.txt")); got != "foo foo bar\n" {
+			t.Errorf("a.txt = %q", got)
+		}
+		// El archivo cambió: el mismo hash ya no vale
+		if resp := applyReplace(ReplaceRequest{Selections: []ReplaceSelection{sel}, ProjectBaseDir: root}, re, template); resp.Success {
+			t.Error("stale hash accepted")
+		}
+	})
+
+	t.Run("binary files are skipped", func(t *testing.T) {
+		root := t.TempDir()
+		writeTestFile(t, filepath.Join(root, "data.bin"), "foo\x00foo")
+		writeTestFile(t, filepath.Join(root, "a.txt"), "foo\n")
+		sels := []ReplaceSelection{{Path: "data.bin"}, {Path: "a.txt"}}
+		resp := applyReplace(ReplaceRequest{Selections: sels, ProjectBaseDir: root}, re, template)
+		if !resp.Success || len(resp.Applied) != 1 || resp.Applied[0].Path != "a.txt" {
+			t.Fatalf("apply = %+v", resp)
+		}
+		if got := readTestFile(t, filepath.Join(root, "data.bin")); got != "foo\x00foo" {
+			t.Errorf("binary file changed: %q", got)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		root := t.TempDir()
+		writeTestFile(t, filepath.Join(root, "a.txt"), "foo a\n")
+		writeTestFile(t, filepath.Join(root, "b.txt"), "foo b\n")
+		failing := filepath.Join(root, "b.txt")
+		writeReplacedFile = func(path string, data []byte, mode os.FileMode) error {
+			if path == failing && strings.HasPrefix(string(data), "bar") {
+				return errors.New("disk full")
+			}
+			return atomicWriteFile(path, data, mode)
+		}
+		t.Cleanup(func() { writeReplacedFile = atomicWriteFile })
+		sels := []ReplaceSelection{{Path: "a.txt"}, {Path: "b.txt"}}
+		resp := applyReplace(ReplaceRequest{Selections: sels, ProjectBaseDir: root}, re, template)
+		if resp.Success || !resp.RolledBack {
+			t.Fatalf("apply = %+v, want a rolled back failure", resp)
+		}
+		if got := readTestFile(t, filepath.Join(root, "a.txt")); got != "foo a\n" {
+			t.Errorf("a.txt not restored: %q", got)
+		}
+		if got := readTestFile(t, failing); got != "foo b\n" {
+			t.Errorf("b.txt = %q", got)
+		}
+	})
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultSearchMaxResults = 2000
+	maxSearchContextLines   = 10
+	// Los archivos más grandes que esto se omiten en la búsqueda
+	maxSearchFileSize = 5 * 1024 * 1024
+	// Cantidad de bytes inspeccionados para decidir si un archivo es binario
+	binarySniffLen = 8000
+)
+
+type SearchRequest struct {
+	Query         string `json:"query"`
+	Regex         bool   `json:"regex,omitempty"`
+	CaseSensitive bool   `json:"caseSensitive,omitempty"`
+	WholeWord     bool   `json:"wholeWord,omitempty"`
+	// Multiline deja que las coincidencias abarquen varias líneas; se activa solo si la consulta tiene saltos de línea
+	Multiline      bool     `json:"multiline,omitempty"`
+	Include        []string `json:"include,omitempty"`
+	Exclude        []string `json:"exclude,omitempty"`
+	NoIgnore       bool     `json:"noIgnore,omitempty"`
+	MaxResults     int      `json:"maxResults,omitempty"`
+	ContextLines   int      `json:"contextLines,omitempty"`
+	Stream         bool     `json:"stream,omitempty"`
+	ProjectBaseDir string   `json:"projectBaseDir,omitempty"`
+}
+
+type SearchMatch struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Length int    `json:"length"`
+	Text   string `json:"text"`
+	// EndLine y EndColumn (exclusiva) indican dónde termina una coincidencia que abarca varias líneas; Text las trae todas
+	EndLine   int      `json:"endLine,omitempty"`
+	EndColumn int      `json:"endColumn,omitempty"`
+	Before    []string `json:"before,omitempty"`
+	After     []string `json:"after,omitempty"`
+}
+
+type SearchFileResult struct {
+	Path    string        `json:"path"`
+	Matches []SearchMatch `json:"matches"`
+	// Encoding es el charset del archivo cuando no es UTF-8; líneas y columnas se refieren al texto decodificado
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type SearchResponse struct {
+	Success       bool               `json:"success"`
+	Message       string             `json:"message,omitempty"`
+	Results       []SearchFileResult `json:"results,omitempty"`
+	TotalMatches  int                `json:"totalMatches"`
+	FilesSearched int                `json:"filesSearched"`
+	Truncated     bool               `json:"truncated,omitempty"`
+}
+
+// searchStreamEvent es cada línea NDJSON enviada cuando la búsqueda se pide en modo stream
+type searchStreamEvent struct {
+	Type string `json:"type"`
+	*SearchFileResult
+	*SearchResponse
+}
+
+// walkOptions controla qué archivos del workspace se visitan
+type walkOptions struct {
+	include  []*regexp.Regexp
+	exclude  []*regexp.Regexp
+	noIgnore bool
+	// ignoreRoot es el workspace cuando se recorre solo una subcarpeta: sus .gitignore y .airideignore,
+	// y los de las carpetas intermedias, siguen aplicando dentro de la subcarpeta
+	ignoreRoot string
+}
+
+// errStopWalk detiene el recorrido sin considerarse un error
+var errStopWalk = fs.SkipAll
+
+// walkWorkspaceFiles recorre los archivos regulares del workspace respetando ignore files e include/exclude
+func walkWorkspaceFiles(root string, opts walkOptions, fn func(absPath, relPath string, info fs.FileInfo) error) error {
+	matcher := newIgnoreMatcher(root)
+	// Las reglas se evalúan con rutas relativas a matcher.root; prefix es la subcarpeta recorrida dentro de él
+	prefix := ""
+	if opts.ignoreRoot != "" && !opts.noIgnore && isSubPath(opts.ignoreRoot, root) {
+		if rel, err := filepath.Rel(opts.ignoreRoot, root); err == nil && rel != "." {
+			matcher = newIgnoreMatcher(opts.ignoreRoot)
+			prefix = filepath.ToSlash(rel) + "/"
+			dir := ""
+			for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+				matcher.loadDir(dir)
+				dir = strings.TrimPrefix(dir+"/"+part, "/")
+			}
+		}
+	}
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Directorios sin permisos u otros errores puntuales no detienen la búsqueda
+			if d != nil && d.IsDir() && p != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, relErr := filepath.Rel(root, p)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if p == root {
+				rel = ""
+			} else {
+				if alwaysIgnoredDirs[d.Name()] || (!opts.noIgnore && matcher.ignored(prefix+rel, true)) || matchAnyGlob(opts.exclude, rel) {
+					return filepath.SkipDir
+				}
+			}
+			if !opts.noIgnore {
+				matcher.loadDir(strings.TrimSuffix(prefix+rel, "/"))
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if !opts.noIgnore && matcher.ignored(prefix+rel, false) {
+			return nil
+		}
+		if matchAnyGlob(opts.exclude, rel) {
+			return nil
+		}
+		if len(opts.include) > 0 && !matchAnyGlob(opts.include, rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		return fn(p, rel, info)
+	})
+}
+
+// isBinaryContent aplica la misma heurística que git: un byte NUL al inicio indica binario
+func isBinaryContent(content []byte) bool {
+	if len(content) > binarySniffLen {
+		content = content[:binarySniffLen]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// compileSearchPattern construye la expresión regular a partir de las opciones de búsqueda
+func compileSearchPattern(query string, isRegex, caseSensitive, wholeWord bool) (*regexp.Regexp, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+	pattern := query
+	if !isRegex {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if wholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %v", err)
+	}
+	return re, nil
+}
+
+// splitLines separa el contenido en líneas sin los terminadores (\n o \r\n)
+func splitLines(content string) []string {
+	lines := strings.Split(content, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	return lines
+}
+
+// searchFileContent devuelve las coincidencias de un archivo; limit es el máximo de coincidencias a devolver
+func searchFileContent(re *regexp.Regexp, content string, contextLines, limit int) []SearchMatch {
+	var matches []SearchMatch
+	lines := splitLines(content)
+	for i, line := range lines {
+		locs := re.FindAllStringIndex(line, -1)
+		for _, loc := range locs {
+			if loc[0] == loc[1] {
+				continue
+			}
+			m := SearchMatch{
+				Line:   i + 1,
+				Column: utf8.RuneCountInString(line[:loc[0]]) + 1,
+				Length: utf8.RuneCountInString(line[loc[0]:loc[1]]),
+				Text:   line,
+			}
+			if contextLines > 0 {
+				start := i - contextLines
+				if start < 0 {
+					start = 0
+				}
+				end := i + 1 + contextLines
+				if end > len(lines) {
+					end = len(lines)
+				}
+				m.Before = lines[start:i]
+				m.After = lines[i+1 : end]
+			}
+			matches = append(matches, m)
+			if len(matches) >= limit {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+// searchMultilineContent busca en el contenido completo, de modo que la expresión puede abarcar varias líneas.
+// Line y Column son el inicio de la coincidencia y EndLine/EndColumn su final si termina en otra línea.
+func searchMultilineContent(re *regexp.Regexp, content string, contextLines, limit int) []SearchMatch {
+	var matches []SearchMatch
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+	starts := make([]int, len(lines))
+	offset := 0
+	for i, l := range lines {
+		starts[i] = offset
+		offset += len(l) + 1
+	}
+	lineAt := func(pos int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > pos }) - 1
+	}
+	for _, loc := range re.FindAllStringIndex(content, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		first, last := lineAt(loc[0]), lineAt(loc[1]-1)
+		m := SearchMatch{
+			Line:   first + 1,
+			Column: utf8.RuneCountInString(content[starts[first]:loc[0]]) + 1,
+			Length: utf8.RuneCountInString(content[loc[0]:loc[1]]),
+			Text:   strings.Join(lines[first:last+1], "\n"),
+		}
+		if last > first {
+			m.EndLine = last + 1
+			m.EndColumn = utf8.RuneCountInString(content[starts[last]:loc[1]]) + 1
+		}
+		if contextLines > 0 {
+			start := first - contextLines
+			if start < 0 {
+				start = 0
+			}
+			end := last + 1 + contextLines
+			if end > len(lines) {
+				end = len(lines)
+			}
+			m.Before = lines[start:first]
+			m.After = lines[last+1 : end]
+		}
+		matches = append(matches, m)
+		if len(matches) >= limit {
+			break
+		}
+	}
+	return matches
+}
+
+// readSearchText lee un archivo como texto UTF-8: los UTF-16 y los de otros charsets (Latin-1, Shift-JIS...) se
+// decodifican como al abrirlos en el editor. Devuelve "" en charset si era UTF-8 y ok=false si es binario.
+func readSearchText(path string) (text, charset string, ok bool) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false
+	}
+	charset = detectCharset(content)
+	// UTF-16 tiene bytes NUL en texto normal; para el resto un NUL significa binario
+	if !strings.HasPrefix(charset, "utf-16") && isBinaryContent(content) {
+		return "", "", false
+	}
+	if text, err = decodeText(content, charset); err != nil {
+		return "", "", false
+	}
+	text = strings.TrimPrefix(text, "\ufeff")
+	if charset == "utf-8" {
+		charset = ""
+	}
+	return text, charset, true
+}
+
+// runSearch ejecuta la búsqueda y llama a emit por cada archivo con coincidencias
+func runSearch(req SearchRequest, stop <-chan struct{}, emit func(SearchFileResult)) (SearchResponse, error) {
+	re, err := compileSearchPattern(req.Query, req.Regex, req.CaseSensitive, req.WholeWord)
+	if err != nil {
+		return SearchResponse{}, err
+	}
+	include, err := compileGlobs(req.Include)
+	if err != nil {
+		return SearchResponse{}, fmt.Errorf("invalid include pattern: %v", err)
+	}
+	exclude, err := compileGlobs(req.Exclude)
+	if err != nil {
+		return SearchResponse{}, fmt.Errorf("invalid exclude pattern: %v", err)
+	}
+	maxResults := req.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultSearchMaxResults
+	}
+	contextLines := req.ContextLines
+	if contextLines > maxSearchContextLines {
+		contextLines = maxSearchContextLines
+	}
+	search := searchFileContent
+	if req.Multiline || strings.Contains(req.Query, "\n") || (req.Regex && strings.Contains(req.Query, `\n`)) {
+		// (?m): ^ y $ siguen marcando inicio y fin de cada línea
+		re = regexp.MustCompile("(?m)" + re.String())
+		search = searchMultilineContent
+	}
+	root := workspaceRoot(req.ProjectBaseDir)
+	var summary SearchResponse
+	opts := walkOptions{include: include, exclude: exclude, noIgnore: req.NoIgnore}
+	err = walkWorkspaceFiles(root, opts, func(absPath, relPath string, info fs.FileInfo) error {
+		select {
+		case <-stop:
+			return errStopWalk
+		default:
+		}
+		if info.Size() > maxSearchFileSize {
+			return nil
+		}
+		text, charset, ok := readSearchText(absPath)
+		if !ok {
+			return nil
+		}
+		summary.FilesSearched++
+		matches := search(re, text, contextLines, maxResults-summary.TotalMatches)
+		if len(matches) == 0 {
+			return nil
+		}
+		summary.TotalMatches += len(matches)
+		emit(SearchFileResult{Path: relPath, Matches: matches, Encoding: charset})
+		if summary.TotalMatches >= maxResults {
+			summary.Truncated = true
+			return errStopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+	summary.Success = true
+	summary.Message = "Search completed"
+	return summary, nil
+}
+
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	fmt.Println("[BACK] /api/search endpoint hit")
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fmt.Println("[BACK] Error decoding search request:", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	fmt.Printf("[BACK] SearchRequest: %+v\n", req)
+
+	if req.Stream {
+		// En modo stream cada archivo se envía como una línea NDJSON apenas se encuentra
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, _ := w.(http.Flusher)
+		enc := json.NewEncoder(w)
+		summary, err := runSearch(req, r.Context().Done(), func(res SearchFileResult) {
+			enc.Encode(searchStreamEvent{Type: "file", SearchFileResult: &res})
+			if flusher != nil {
+				flusher.Flush()
+			}
+		})
+		if err != nil {
+			summary = SearchResponse{Success: false, Message: err.Error()}
+		}
+		enc.Encode(searchStreamEvent{Type: "done", SearchResponse: &summary})
+		return
+	}
+
+	var results []SearchFileResult
+	resp, err := runSearch(req, r.Context().Done(), func(res SearchFileResult) {
+		results = append(results, res)
+	})
+	if err != nil {
+		resp = SearchResponse{Success: false, Message: err.Error()}
+	}
+	resp.Results = results
+	fmt.Printf("[BACK] Search finished: %d matches in %d files (searched %d)\n", resp.TotalMatches, len(results), resp.FilesSearched)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
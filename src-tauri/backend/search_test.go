@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSearchFileContent(t *testing.T) {
+	content := "package main\r\n\r\nfunc añadir() {\n\tañadir()\n}\n"
+	tests := []struct {
+		name         string
+		query        string
+		regex        bool
+		contextLines int
+		limit        int
+		want         []SearchMatch
+	}{
+		{
+			name: "columns count runes", query: "dir", limit: 10,
+			want: []SearchMatch{
+				{Line: 3, Column: 9, Length: 3, Text: "func añadir() {"},
+				{Line: 4, Column: 5, Length: 3, Text: "\tañadir()"},
+			},
+		},
+		{
+			name: "context lines without CR", query: "func", contextLines: 1, limit: 10,
+			want: []SearchMatch{{Line: 3, Column: 1, Length: 4, Text: "func añadir() {", Before: []string{""}, After: []string{"\tañadir()"}}},
+		},
+		{
+			name: "limit", query: "añadir", limit: 1,
+			want: []SearchMatch{{Line: 3, Column: 6, Length: 6, Text: "func añadir() {"}},
+		},
+		{
+			name: "empty matches are skipped", query: `x*`, regex: true, limit: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := compileSearchPattern(tt.query, tt.regex, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := searchFileContent(re, content, tt.contextLines, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchFileContent =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchMultilineContent(t *testing.T) {
+	content := "a\r\nfunc f() {\r\n\treturn\r\n}\r\nz\n"
+	re, err := compileSearchPattern(`\{\n\treturn`, true, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := searchMultilineContent(re, content, 1, 10)
+	want := []SearchMatch{{
+		Line: 2, Column: 10, Length: 9, Text: "func f() {\n\treturn",
+		EndLine: 3, EndColumn: 8, Before: []string{"a"}, After: []string{"}"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("searchMultilineContent =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestRunSearch(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "main.go"), "// TODO: first\nfunc main() {}\n// TODO: second\n")
+	writeTestFile(t, filepath.Join(root, "docs", "notes.md"), "TODO: write docs\n")
+	writeTestFile(t, filepath.Join(root, "data.bin"), "TODO\x00\x01\x02")
+	// "TODO: café" en UTF-16 LE con BOM y en Latin-1
+	utf16 := []byte{0xFF, 0xFE}
+	for _, r := range "TODO: café\r\n" {
+		utf16 = append(utf16, byte(r), byte(r>>8))
+	}
+	if err := os.WriteFile(filepath.Join(root, "win.txt"), utf16, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "latin1.txt"), []byte("TODO: caf\xe9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(req SearchRequest) (map[string]SearchFileResult, SearchResponse) {
+		t.Helper()
+		req.ProjectBaseDir = root
+		results := map[string]SearchFileResult{}
+		summary, err := runSearch(req, nil, func(res SearchFileResult) { results[res.Path] = res })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results, summary
+	}
+
+	results, summary := search(SearchRequest{Query: "todo"})
+	if len(results) != 4 || summary.TotalMatches != 5 || summary.Truncated {
+		t.Fatalf("results = %+v, summary = %+v", results, summary)
+	}
+	if _, ok := results["data.bin"]; ok {
+		t.Error("binary file searched")
+	}
+	for path, charset := range map[string]string{"win.txt": "utf-16le", "latin1.txt": "iso-8859-1", "main.go": ""} {
+		if res := results[path]; res.Encoding != charset {
+			t.Errorf("%s = %+v, want encoding %q", path, res, charset)
+		}
+	}
+	// El BOM no cuenta como columna
+	if m := results["win.txt"].Matches; len(m) != 1 || m[0].Column != 1 {
+		t.Errorf("utf-16 matches = %+v", m)
+	}
+	if got := results["win.txt"].Matches[0].Text; got != "TODO: café" {
+		t.Errorf("utf-16 line = %q", got)
+	}
+
+	results, _ = search(SearchRequest{Query: "café", Include: []string{"*.txt"}})
+	if len(results) != 2 {
+		t.Errorf("decoded files not matched: %+v", results)
+	}
+
+	results, _ = search(SearchRequest{Query: "TODO", CaseSensitive: true, Exclude: []string{"docs/**"}, Include: []string{"*.go", "**/*.md"}})
+	if len(results) != 1 || len(results["main.go"].Matches) != 2 {
+		t.Errorf("include/exclude results = %+v", results)
+	}
+
+	_, summary = search(SearchRequest{Query: "TODO", MaxResults: 2})
+	if summary.TotalMatches != 2 || !summary.Truncated {
+		t.Errorf("maxResults summary = %+v", summary)
+	}
+
+	// Un salto de línea en la consulta activa la búsqueda en varias líneas
+	results, _ = search(SearchRequest{Query: "first\nfunc"})
+	if m := results["main.go"].Matches; len(m) != 1 || m[0].Line != 1 || m[0].EndLine != 2 {
+		t.Errorf("multiline results = %+v", results)
+	}
+
+	if _, err := runSearch(SearchRequest{Query: "(", Regex: true, ProjectBaseDir: root}, nil, func(SearchFileResult) {}); err == nil {
+		t.Error("invalid regex accepted")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workspaceConfigDir es la carpeta de configuración de AirIde dentro de cada workspace
+const workspaceConfigDir = ".airide"
+
+// workspaceConfigReadOnly es el error que recibe quien no sea la interfaz al intentar escribir en .airide
+const workspaceConfigReadOnly = "Files under " + workspaceConfigDir + " can only be changed from the IDE"
+
+// isWorkspaceConfigPath indica si la ruta (o el lugar al que apunta siguiendo symlinks) está dentro de una
+// carpeta .airide, donde viven la política de comandos, los ajustes y la auditoría
+func isWorkspaceConfigPath(path string) bool {
+	for _, p := range []string{filepath.Clean(path), realPath(path)} {
+		for _, part := range strings.Split(filepath.ToSlash(p), "/") {
+			if part == workspaceConfigDir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FileSettings controla cómo se normaliza el texto al guardar
+type FileSettings struct {
+	// EOL: "auto" conserva el fin de línea detectado, "lf" o "crlf" lo fuerzan
+	EOL string `json:"eol,omitempty"`
+	// BOM: "auto" conserva el BOM UTF-8 original, "add" o "remove" lo fuerzan
+	BOM string `json:"bom,omitempty"`
+	// FinalNewline: "auto" conserva el salto final original, "insert" o "trim" lo fuerzan
+	FinalNewline string `json:"finalNewline,omitempty"`
+	// MaxReadSizeMB es el tamaño máximo de archivo que se abre completo; los más grandes se leen por rangos
+	MaxReadSizeMB int `json:"maxReadSizeMB,omitempty"`
+}
+
+// WorkspaceSettings es el contenido de .airide/settings.json
+type WorkspaceSettings struct {
+	Files    FileSettings          `json:"files"`
+	History  HistorySettings       `json:"history"`
+	Terminal TerminalSettings      `json:"terminal"`
+	Commands CommandPolicySettings `json:"commands"`
+}
+
+// defaultWorkspaceSettings conserva el formato de cada archivo tal como está en disco
+func defaultWorkspaceSettings() WorkspaceSettings {
+	return WorkspaceSettings{
+		Files: FileSettings{EOL: "auto", BOM: "auto", FinalNewline: "auto", MaxReadSizeMB: defaultMaxReadSizeMB},
+	}
+}
+
+// loadWorkspaceSettings lee .airide/settings.json del workspace; si no existe usa los valores por defecto
+func loadWorkspaceSettings(root string) WorkspaceSettings {
+	settings := defaultWorkspaceSettings()
+	data, err := os.ReadFile(filepath.Join(root, workspaceConfigDir, "settings.json"))
+	if err != nil {
+		return settings
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		fmt.Printf("[BACK] Invalid workspace settings in %s: %v\n", root, err)
+		return defaultWorkspaceSettings()
+	}
+	return settings
+}
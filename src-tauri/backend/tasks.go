@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Archivo de tareas definidas por el usuario dentro de .airide
+const tasksFileName = "tasks.json"
+
+// Task es una tarea ejecutable del workspace, descubierta en los manifiestos del proyecto o definida en tasks.json
+type Task struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Source      string            `json:"source"` // "npm", "make", "go", "cargo", "just" o "custom"
+	Command     string            `json:"command"`
+	Cwd         string            `json:"cwd,omitempty"` // relativo al workspace
+	Group       string            `json:"group,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+}
+
+type TaskRequest struct {
+	Operation      string            `json:"operation"` // "start"
+	ID             string            `json:"id"`
+	Args           []string          `json:"args,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	ProjectBaseDir string            `json:"projectBaseDir,omitempty"`
+	Source         string            `json:"source,omitempty"`
+	ConfirmToken   string            `json:"confirmToken,omitempty"`
+}
+
+type TaskResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message"`
+	Tasks   []*Task  `json:"tasks,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// Manifiestos que definen tareas; se buscan en la raíz y un nivel por debajo
+var taskManifests = map[string]bool{
+	"package.json": true, "Makefile": true, "makefile": true, "GNUmakefile": true,
+	"go.mod": true, "Cargo.toml": true, "justfile": true, "Justfile": true, ".justfile": true,
+}
+
+var (
+	makeTargetPattern = regexp.MustCompile(`^([A-Za-z0-9_][A-Za-z0-9_./ -]*?)\s*:([^=]|$)`)
+	justRecipePattern = regexp.MustCompile(`^@?([A-Za-z_][A-Za-z0-9_-]*)(\s+[^:]*)?:([^=]|$)`)
+)
+
+// taskWalkExclude limita la búsqueda de manifiestos a dos niveles y salta dependencias y salidas de compilación
+var taskWalkExclude = []*regexp.Regexp{
+	regexp.MustCompile(`^[^/]+/[^/]+/[^/]+`),
+	regexp.MustCompile(`(^|/)(node_modules|vendor|target|dist|build)(/|$)`),
+}
+
+// discoverTasks devuelve las tareas de los manifiestos del workspace seguidas de las de .airide/tasks.json.
+// Los manifiestos que no se pueden leer se informan en errs sin impedir el resto.
+func discoverTasks(root string) (tasks []*Task, errs []string) {
+	var manifests []string
+	walkWorkspaceFiles(root, walkOptions{exclude: taskWalkExclude}, func(absPath, relPath string, info fs.FileInfo) error {
+		if taskManifests[path.Base(relPath)] {
+			manifests = append(manifests, relPath)
+		}
+		return nil
+	})
+	sort.Slice(manifests, func(i, j int) bool {
+		// Primero la raíz, después los subproyectos
+		di, dj := strings.Count(manifests[i], "/"), strings.Count(manifests[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return manifests[i] < manifests[j]
+	})
+	for _, rel := range manifests {
+		dir := path.Dir(rel)
+		if dir == "." {
+			dir = ""
+		}
+		var found []*Task
+		var err error
+		abs := filepath.Join(root, filepath.FromSlash(rel))
+		switch path.Base(rel) {
+		case "package.json":
+			found, err = npmTasks(abs, filepath.Join(root, filepath.FromSlash(dir)))
+		case "Makefile", "makefile", "GNUmakefile":
+			found, err = makeTasks(abs)
+		case "go.mod":
+			found = goTasks()
+		case "Cargo.toml":
+			found = cargoTasks()
+		default:
+			found, err = justTasks(abs)
+		}
+		if err != nil {
+			errs = append(errs, rel+": "+err.Error())
+			continue
+		}
+		for _, t := range found {
+			t.Cwd = dir
+			t.ID = t.Source + ":" + t.Name
+			if dir != "" {
+				t.ID = t.Source + ":" + dir + ":" + t.Name
+			}
+			tasks = append(tasks, t)
+		}
+	}
+
+	custom, err := customTasks(root)
+	if err != nil {
+		errs = append(errs, path.Join(workspaceConfigDir, tasksFileName)+": "+err.Error())
+	}
+	return append(tasks, custom...), errs
+}
+
+// taskGroup clasifica la tarea por su nombre para que la UI pueda agrupar build/test/run
+func taskGroup(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(lower, "test") || strings.HasSuffix(lower, "test") || strings.HasPrefix(lower, "e2e"):
+		return "test"
+	case strings.HasPrefix(lower, "build") || lower == "compile" || lower == "all":
+		return "build"
+	case lower == "start" || lower == "dev" || lower == "serve" || lower == "run" || lower == "watch":
+		return "run"
+	case strings.HasPrefix(lower, "lint") || lower == "fmt" || lower == "format" || lower == "vet" || lower == "check":
+		return "lint"
+	}
+	return ""
+}
+
+// npmTasks lee los scripts de package.json con el gestor de paquetes que indique el lockfile
+func npmTasks(manifest, dir string) ([]*Task, error) {
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		return nil, err
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, err
+	}
+	runner, source := "npm run ", "npm"
+	if _, err := os.Stat(filepath.Join(dir, "pnpm-lock.yaml")); err == nil {
+		runner, source = "pnpm run ", "pnpm"
+	} else if _, err := os.Stat(filepath.Join(dir, "yarn.lock")); err == nil {
+		runner, source = "yarn run ", "yarn"
+	}
+	names := make([]string, 0, len(pkg.Scripts))
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var tasks []*Task
+	for _, name := range names {
+		tasks = append(tasks, &Task{Name: name, Source: source, Command: runner + shellQuote(name), Group: taskGroup(name), Description: pkg.Scripts[name]})
+	}
+	return tasks, nil
+}
+
+// makeTasks lee los targets de un Makefile; un comentario "## texto" en la línea del target es su descripción
+func makeTasks(manifest string) ([]*Task, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tasks []*Task
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		m := makeTargetPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		desc := ""
+		if i := strings.Index(line, "##"); i >= 0 {
+			desc = strings.TrimSpace(line[i+2:])
+		}
+		// "a b: deps" define varios targets a la vez; los especiales como .PHONY y los patrones con % no encajan
+		for _, name := range strings.Fields(m[1]) {
+			if seen[name] || strings.HasPrefix(name, ".") {
+				continue
+			}
+			seen[name] = true
+			tasks = append(tasks, &Task{Name: name, Source: "make", Command: "make " + shellQuote(name), Group: taskGroup(name), Description: desc})
+		}
+	}
+	return tasks, scanner.Err()
+}
+
+// justTasks lee las recetas de un justfile; el comentario de la línea anterior es su descripción
+func justTasks(manifest string) ([]*Task, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var tasks []*Task
+	comment := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			comment = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			continue
+		}
+		m := justRecipePattern.FindStringSubmatch(line)
+		if m == nil {
+			comment = ""
+			continue
+		}
+		switch m[1] {
+		case "set", "alias", "export", "import", "mod":
+			comment = ""
+			continue
+		}
+		if !strings.HasPrefix(m[1], "_") {
+			tasks = append(tasks, &Task{Name: m[1], Source: "just", Command: "just " + shellQuote(m[1]), Group: taskGroup(m[1]), Description: comment})
+		}
+		comment = ""
+	}
+	return tasks, scanner.Err()
+}
+
+func goTasks() []*Task {
+	return []*Task{
+		{Name: "build", Source: "go", Command: "go build ./...", Group: "build"},
+		{Name: "test", Source: "go", Command: "go test ./...", Group: "test"},
+		{Name: "vet", Source: "go", Command: "go vet ./...", Group: "lint"},
+		{Name: "run", Source: "go", Command: "go run .", Group: "run"},
+	}
+}
+
+func cargoTasks() []*Task {
+	return []*Task{
+		{Name: "build", Source: "cargo", Command: "cargo build", Group: "build"},
+		{Name: "test", Source: "cargo", Command: "cargo test", Group: "test"},
+		{Name: "check", Source: "cargo", Command: "cargo check", Group: "lint"},
+		{Name: "clippy", Source: "cargo", Command: "cargo clippy", Group: "lint"},
+		{Name: "run", Source: "cargo", Command: "cargo run", Group: "run"},
+	}
+}
+
+// customTasks lee .airide/tasks.json: {"tasks": [{"name": "...", "command": "...", "cwd": "...", "env": {...}}]}
+func customTasks(root string) ([]*Task, error) {
+	data, err := os.ReadFile(filepath.Join(root, workspaceConfigDir, tasksFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Tasks []*Task `json:"tasks"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	var tasks []*Task
+	for i, t := range file.Tasks {
+		if t == nil || strings.TrimSpace(t.Command) == "" {
+			return nil, fmt.Errorf("task %d has no command", i)
+		}
+		if t.Name == "" {
+			t.Name = t.Command
+		}
+		t.Source = "custom"
+		t.ID = "custom:" + t.Name
+		t.Cwd = filepath.ToSlash(t.Cwd)
+		if t.Group == "" {
+			t.Group = taskGroup(t.Name)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// shellQuote protege un argumento para la shell que usa buildShellCommand. La "@" se cita siempre:
+// en PowerShell "@args" expande una variable (splatting)
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-./:=") == "" {
+		return arg
+	}
+	if runtime.GOOS == "windows" {
+		return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// findTask busca una tarea por su id y la convierte en el comando a ejecutar con los argumentos extra
+func findTask(req TaskRequest) (*Task, TerminalRequest, error) {
+	root := workspaceRoot(req.ProjectBaseDir)
+	tasks, _ := discoverTasks(root)
+	for _, t := range tasks {
+		if t.ID != req.ID {
+			continue
+		}
+		command := t.Command
+		for _, arg := range req.Args {
+			command += " " + shellQuote(arg)
+		}
+		env := map[string]string{}
+		for k, v := range t.Env {
+			env[k] = v
+		}
+		for k, v := range req.Env {
+			env[k] = v
+		}
+		if err := checkEnvOverrides(env); err != nil {
+			return nil, TerminalRequest{}, fmt.Errorf("task %s: %v", t.ID, err)
+		}
+		cwd := root
+		if t.Cwd != "" {
+			cwd = resolveWorkspacePath(t.Cwd, root)
+		}
+		treq := TerminalRequest{Command: command, WorkingDir: cwd, ProjectBaseDir: root, Source: req.Source, ConfirmToken: req.ConfirmToken, Env: env}
+		return t, treq, nil
+	}
+	return nil, TerminalRequest{}, fmt.Errorf("task not found: %s", req.ID)
+}
+
+// tasksHandler lista las tareas del workspace (GET) o lanza una en segundo plano como proceso gestionado (POST start)
+func tasksHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+		tasks, errs := discoverTasks(root)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(TaskResponse{Success: true, Message: fmt.Sprintf("Found %d tasks", len(tasks)), Tasks: tasks, Errors: errs})
+	case "POST":
+		var req TaskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Source = requestSource(r, req.Source)
+		if req.Operation != "start" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(TaskResponse{Success: false, Message: "Unknown task operation: " + req.Operation})
+			return
+		}
+		var resp interface{}
+		t, treq, err := findTask(req)
+		if err != nil {
+			resp = ProcessResponse{Success: false, Message: err.Error()}
+		} else {
+			fmt.Printf("[BACK] Starting task %s: '%s'\n", t.ID, treq.Command)
+			resp = handleProcessRequest(ProcessRequest{Operation: "start", Name: t.Name, Command: treq.Command, WorkingDir: treq.WorkingDir, ProjectBaseDir: treq.ProjectBaseDir, Source: req.Source, ConfirmToken: req.ConfirmToken, Env: treq.Env})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// taskRunHandler ejecuta una tarea transmitiendo su salida igual que /terminal/stream.
+// Por WebSocket el primer mensaje es el TaskRequest; por SSE va en el cuerpo de un POST.
+func taskRunHandler(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		streamCommandWebSocket(w, r, "task", func(conn *websocket.Conn) (TerminalRequest, error) {
+			var req TaskRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return TerminalRequest{}, err
+			}
+			t, treq, err := findTask(req)
+			if err == nil {
+				fmt.Printf("[BACK] Running task %s (WebSocket): '%s'\n", t.ID, treq.Command)
+			}
+			return treq, err
+		})
+		return
+	}
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	var req TaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	t, treq, err := findTask(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	fmt.Printf("[BACK] Running task %s (SSE): '%s'\n", t.ID, treq.Command)
+	streamCommandSSE(w, r, "task", treq)
+}
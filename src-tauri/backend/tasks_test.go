@@ -0,0 +1,173 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// taskSummary resume las tareas como "nombre=comando (grupo) descripción" para compararlas en una línea
+func taskSummary(tasks []*Task) []string {
+	var out []string
+	for _, t := range tasks {
+		s := t.Name + "=" + t.Command
+		if t.Group != "" {
+			s += " (" + t.Group + ")"
+		}
+		if t.Description != "" {
+			s += " " + t.Description
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestMakeTasks(t *testing.T) {
+	root := t.TempDir()
+	manifest := filepath.Join(root, "Makefile")
+	writeTestFile(t, manifest, strings.Join([]string{
+		"CC := gcc",
+		"VERSION = 1.0",
+		".PHONY: build test",
+		"build: deps ## Compile everything",
+		"\tgo build ./...",
+		"test:",
+		"\techo test: done",
+		"lint fmt: build",
+		"%.o: %.c",
+		"build:",
+		"",
+	}, "\n"))
+	tasks, err := makeTasks(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"build=make build (build) Compile everything",
+		"test=make test (test)",
+		"lint=make lint (lint)",
+		"fmt=make fmt (lint)",
+	}
+	if got := taskSummary(tasks); !reflect.DeepEqual(got, want) {
+		t.Errorf("makeTasks = %q, want %q", got, want)
+	}
+}
+
+func TestJustTasks(t *testing.T) {
+	root := t.TempDir()
+	manifest := filepath.Join(root, "justfile")
+	writeTestFile(t, manifest, strings.Join([]string{
+		"set shell := [\"bash\", \"-c\"]",
+		"alias b := build",
+		"export RUST_LOG := \"debug\"",
+		"# Build the project",
+		"build:",
+		"    cargo build",
+		"",
+		"# Run the tests",
+		"@test filter='':",
+		"    cargo test {{filter}}",
+		"",
+		"# Not shown",
+		"_helper:",
+		"    echo hidden",
+		"serve port=\"8080\": build",
+		"",
+	}, "\n"))
+	tasks, err := justTasks(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"build=just build (build) Build the project",
+		"test=just test (test) Run the tests",
+		"serve=just serve (run)",
+	}
+	if got := taskSummary(tasks); !reflect.DeepEqual(got, want) {
+		t.Errorf("justTasks = %q, want %q", got, want)
+	}
+}
+
+func TestNpmTasksLockfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		lockfile string
+		want     string
+	}{
+		{"npm", "package-lock.json", "npm run build"},
+		{"no lockfile", "", "npm run build"},
+		{"yarn", "yarn.lock", "yarn run build"},
+		{"pnpm", "pnpm-lock.yaml", "pnpm run build"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, "package.json"), `{"scripts": {"build": "vite build", "test:unit": "vitest"}}`)
+			if tt.lockfile != "" {
+				writeTestFile(t, filepath.Join(dir, tt.lockfile), "")
+			}
+			tasks, err := npmTasks(filepath.Join(dir, "package.json"), dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tasks) != 2 || tasks[0].Command != tt.want || tasks[0].Description != "vite build" {
+				t.Fatalf("npmTasks = %q", taskSummary(tasks))
+			}
+			if tasks[0].Source != strings.Fields(tt.want)[0] {
+				t.Errorf("source = %q", tasks[0].Source)
+			}
+			if tasks[1].Group != "test" {
+				t.Errorf("test:unit group = %q", tasks[1].Group)
+			}
+		})
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		arg         string
+		want        string
+		wantWindows string // PowerShell, si difiere
+	}{
+		{"--watch", "--watch", ""},
+		{"src/app.test.ts", "src/app.test.ts", ""},
+		{"--port=3000", "--port=3000", ""},
+		{"", "''", ""},
+		{"a b", "'a b'", ""},
+		{"$HOME", "'$HOME'", ""},
+		{"@args", "'@args'", ""},
+		{"it's", `'it'\''s'`, "'it''s'"},
+		{"a;rm -rf /", "'a;rm -rf /'", ""},
+	}
+	for _, tt := range tests {
+		want := tt.want
+		if runtime.GOOS == "windows" && tt.wantWindows != "" {
+			want = tt.wantWindows
+		}
+		if got := shellQuote(tt.arg); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.arg, got, want)
+		}
+	}
+}
+
+func TestFindTaskQuotesArgs(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "web", "package.json"), `{"scripts": {"test": "vitest"}}`)
+	writeTestFile(t, filepath.Join(root, "web", "yarn.lock"), "")
+
+	task, treq, err := findTask(TaskRequest{ID: "yarn:web:test", ProjectBaseDir: root, Args: []string{"--watch", "my file.ts", "@all"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "yarn run test --watch 'my file.ts' '@all'"; treq.Command != want {
+		t.Errorf("command = %s, want %s", treq.Command, want)
+	}
+	if treq.WorkingDir != filepath.Join(root, "web") || task.Cwd != "web" {
+		t.Errorf("working dir = %s (cwd %s)", treq.WorkingDir, task.Cwd)
+	}
+	if _, _, err := findTask(TaskRequest{ID: "npm:test", ProjectBaseDir: root}); err == nil {
+		t.Error("found a task that does not exist")
+	}
+}
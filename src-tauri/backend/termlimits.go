@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultTerminalTimeoutSeconds = 600
+	defaultTerminalMaxOutputKB    = 10 * 1024
+)
+
+// TerminalSettings limita los comandos de /terminal (en .airide/settings.json)
+type TerminalSettings struct {
+	// TimeoutSeconds es el tiempo máximo de un comando; -1 lo desactiva
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// MaxOutputKB es cuánto stdout y cuánto stderr se conserva como máximo
+	MaxOutputKB int `json:"maxOutputKB,omitempty"`
+}
+
+// terminalWorkspaceRoot devuelve el workspace de un comando: el projectBaseDir o, si no viene, su directorio.
+// Para los comandos que no son del usuario lo decide commandPolicyRoot.
+func terminalWorkspaceRoot(req TerminalRequest) string {
+	root := workspaceRoot(req.WorkingDir)
+	if req.ProjectBaseDir != "" {
+		root = workspaceRoot(req.ProjectBaseDir)
+	}
+	return commandPolicyRoot(root, req)
+}
+
+// terminalLimits combina lo pedido en la petición con la configuración del workspace
+func terminalLimits(req TerminalRequest) (time.Duration, int) {
+	settings := loadWorkspaceSettings(terminalWorkspaceRoot(req)).Terminal
+	timeout := req.TimeoutSeconds
+	if timeout == 0 {
+		timeout = settings.TimeoutSeconds
+	}
+	if timeout == 0 {
+		timeout = defaultTerminalTimeoutSeconds
+	}
+	maxKB := req.MaxOutputKB
+	if maxKB <= 0 {
+		maxKB = settings.MaxOutputKB
+	}
+	if maxKB <= 0 {
+		maxKB = defaultTerminalMaxOutputKB
+	}
+	if timeout < 0 {
+		return 0, maxKB * 1024
+	}
+	return time.Duration(timeout) * time.Second, maxKB * 1024
+}
+
+// killGroupOnCancel hace que, al vencer el contexto, se mate todo el grupo de procesos y no solo la shell
+func killGroupOnCancel(cmd *exec.Cmd) {
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return signalProcessGroup(cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// Si algún nieto escapó del grupo y mantiene las tuberías abiertas, no esperarlo
+	cmd.WaitDelay = 2 * time.Second
+}
+
+// cappedBuffer guarda como máximo limit bytes: la primera mitad de la salida y la última,
+// que es donde suelen estar el comando que arrancó y el error final
+type cappedBuffer struct {
+	limit int
+	head  []byte
+	tail  []byte
+	total int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.total += int64(n)
+	half := b.limit / 2
+	if room := half - len(b.head); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.head = append(b.head, p[:room]...)
+		p = p[room:]
+	}
+	if len(p) > 0 {
+		b.tail = append(b.tail, p...)
+		if keep := b.limit - half; len(b.tail) > 2*keep {
+			b.tail = append([]byte{}, b.tail[len(b.tail)-keep:]...)
+		}
+	}
+	return n, nil
+}
+
+func (b *cappedBuffer) truncated() bool {
+	return b.total > int64(b.limit)
+}
+
+func (b *cappedBuffer) String() string {
+	if !b.truncated() {
+		return string(b.head) + string(b.tail)
+	}
+	tail := b.tail
+	if keep := b.limit - b.limit/2; len(tail) > keep {
+		tail = tail[len(tail)-keep:]
+	}
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	head := trimToRuneBoundary(b.head)
+	omitted := b.total - int64(len(head)) - int64(len(tail))
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", head, omitted, tail)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		writes []string
+		want   string
+	}{
+		{"under the limit", 10, []string{"abc", "def"}, "abcdef"},
+		{"exactly the limit", 6, []string{"abcdef"}, "abcdef"},
+		{"keeps head and tail", 6, []string{"abcd", "efgh", "ijkl"}, "abc\n... [6 bytes truncated] ...\njkl"},
+		{"many small writes", 4, strings.Split("0123456789", ""), "01\n... [6 bytes truncated] ...\n89"},
+		// Los cortes no dejan caracteres UTF-8 a medias
+		{"rune boundaries", 4, []string{"añ", "xx", "ñb"}, "a\n... [6 bytes truncated] ...\nb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &cappedBuffer{limit: tt.limit}
+			total := 0
+			for _, w := range tt.writes {
+				if n, err := b.Write([]byte(w)); err != nil || n != len(w) {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+				total += len(w)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if b.truncated() != (total > tt.limit) {
+				t.Errorf("truncated() = %v with %d bytes and limit %d", b.truncated(), total, tt.limit)
+			}
+			if len(b.head)+len(b.tail) > 2*tt.limit {
+				t.Errorf("buffer keeps %d bytes for a limit of %d", len(b.head)+len(b.tail), tt.limit)
+			}
+		})
+	}
+}
+
+func TestTerminalLimits(t *testing.T) {
+	configured := t.TempDir()
+	writeTestFile(t, filepath.Join(configured, workspaceConfigDir, "settings.json"), `{"terminal":{"timeoutSeconds":30,"maxOutputKB":64}}`)
+	disabled := t.TempDir()
+	writeTestFile(t, filepath.Join(disabled, workspaceConfigDir, "settings.json"), `{"terminal":{"timeoutSeconds":-1}}`)
+	empty := t.TempDir()
+	writeTestFile(t, filepath.Join(empty, workspaceConfigDir, "settings.json"), `{}`)
+
+	tests := []struct {
+		name        string
+		req         TerminalRequest
+		wantTimeout time.Duration
+		wantMax     int
+	}{
+		{"defaults", TerminalRequest{WorkingDir: empty}, defaultTerminalTimeoutSeconds * time.Second, defaultTerminalMaxOutputKB * 1024},
+		{"workspace settings", TerminalRequest{WorkingDir: configured}, 30 * time.Second, 64 * 1024},
+		{"request wins", TerminalRequest{WorkingDir: configured, TimeoutSeconds: 5, MaxOutputKB: 8}, 5 * time.Second, 8 * 1024},
+		{"request disables timeout", TerminalRequest{WorkingDir: configured, TimeoutSeconds: -1}, 0, 64 * 1024},
+		{"workspace disables timeout", TerminalRequest{WorkingDir: disabled}, 0, defaultTerminalMaxOutputKB * 1024},
+		{"request overrides disabled timeout", TerminalRequest{WorkingDir: disabled, TimeoutSeconds: 7}, 7 * time.Second, defaultTerminalMaxOutputKB * 1024},
+		{"negative output limit is ignored", TerminalRequest{WorkingDir: configured, MaxOutputKB: -5}, 30 * time.Second, 64 * 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout, max := terminalLimits(tt.req)
+			if timeout != tt.wantTimeout || max != tt.wantMax {
+				t.Errorf("terminalLimits = %v, %d; want %v, %d", timeout, max, tt.wantTimeout, tt.wantMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Historial de comandos de la terminal dentro de .airide
+	terminalHistoryFileName = "terminal_history.jsonl"
+	// Al pasar de este tamaño el historial se recorta a las últimas maxTerminalHistoryEntries entradas
+	maxTerminalHistoryBytes   = 2 * 1024 * 1024
+	maxTerminalHistoryEntries = 5000
+	defaultHistoryLimit       = 100
+)
+
+// Variables que la shell cambia sola y que no forman parte del estado de la sesión
+var sessionIgnoredEnv = map[string]bool{"PWD": true, "OLDPWD": true, "SHLVL": true, "_": true}
+
+// terminalSession es una terminal con nombre para /terminal y /terminal/stream: cada comando empieza en el
+// directorio y con las variables que dejó el anterior (cd, export, unset...)
+type terminalSession struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Root     string            `json:"root"`
+	Cwd      string            `json:"cwd"`
+	Env      map[string]string `json:"env,omitempty"`   // variables exportadas o cambiadas en la sesión
+	Unset    []string          `json:"unset,omitempty"` // variables del entorno base eliminadas con unset
+	Created  time.Time         `json:"created"`
+	LastUsed time.Time         `json:"lastUsed"`
+	Busy     bool              `json:"busy"`
+
+	mu sync.Mutex
+}
+
+var (
+	terminalSessionsMu sync.Mutex
+	terminalSessions   = map[string]*terminalSession{}
+)
+
+// createTerminalSession abre una sesión; su env inicial pasa por las mismas reglas que el env de un comando,
+// así que no puede traer PATH ni variables de carga que luego recibirían todos los comandos de la sesión
+func createTerminalSession(name, cwd, projectBaseDir string, env map[string]string) (*terminalSession, error) {
+	if err := checkEnvOverrides(env); err != nil {
+		return nil, err
+	}
+	root := workspaceRoot(projectBaseDir)
+	dir := root
+	if cwd != "" {
+		dir = resolveWorkspacePath(cwd, projectBaseDir)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("working directory does not exist: %s", dir)
+	}
+	s := &terminalSession{ID: randomID(6), Name: name, Root: root, Cwd: dir, Env: map[string]string{}, Created: time.Now()}
+	if s.Name == "" {
+		s.Name = "Terminal " + s.ID
+	}
+	for k, v := range env {
+		s.Env[k] = v
+	}
+	s.LastUsed = s.Created
+	terminalSessionsMu.Lock()
+	terminalSessions[s.ID] = s
+	terminalSessionsMu.Unlock()
+	return s, nil
+}
+
+// acquireTerminalSession reserva la sesión para un comando; una sesión ejecuta un comando cada vez.
+// Sin id devuelve nil, y los métodos de *terminalSession aceptan nil como "sin sesión".
+func acquireTerminalSession(id string) (*terminalSession, error) {
+	if id == "" {
+		return nil, nil
+	}
+	terminalSessionsMu.Lock()
+	s := terminalSessions[id]
+	terminalSessionsMu.Unlock()
+	if s == nil {
+		return nil, fmt.Errorf("terminal session not found: %s", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Busy {
+		return nil, fmt.Errorf("terminal session %s is busy running another command", s.Name)
+	}
+	s.Busy = true
+	// Si alguien borró el directorio actual, volver a la raíz del workspace
+	if info, err := os.Stat(s.Cwd); err != nil || !info.IsDir() {
+		s.Cwd = s.Root
+	}
+	return s, nil
+}
+
+func (s *terminalSession) release() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Busy = false
+	s.LastUsed = time.Now()
+	s.mu.Unlock()
+}
+
+// apply hace que la petición se ejecute en el workspace y el directorio actual de la sesión
+func (s *terminalSession) apply(req TerminalRequest) TerminalRequest {
+	if s == nil {
+		return req
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req.WorkingDir, req.ProjectBaseDir = s.Cwd, s.Root
+	return req
+}
+
+// currentDir es el directorio actual de la sesión, o "" sin sesión
+func (s *terminalSession) currentDir() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Cwd
+}
+
+// commandEnv arma el entorno del comando: el del workspace más lo que cambió la sesión y los overrides de la petición.
+// Los comandos de la IA no reciben las herramientas que viven dentro del workspace.
+func (s *terminalSession) commandEnv(root string, overrides map[string]string, source string) []string {
+	if s == nil {
+		env := buildCommandEnv(root, overrides)
+		if source != "user" {
+			env.withoutWorkspaceTools(root)
+		}
+		return env.list()
+	}
+	s.mu.Lock()
+	sessionEnv := map[string]string{}
+	for k, v := range s.Env {
+		sessionEnv[k] = v
+	}
+	unset := append([]string{}, s.Unset...)
+	s.mu.Unlock()
+	env := buildSessionEnv(root, sessionEnv, overrides)
+	for _, k := range unset {
+		env.unset(k)
+	}
+	if source != "user" {
+		env.withoutWorkspaceTools(root)
+	}
+	return env.list()
+}
+
+// wrap añade al comando la escritura del directorio y el entorno finales en un archivo temporal.
+// El estado se guarda después del comando y, si este termina con exit, desde el trap EXIT; un comando que
+// reemplaza ese trap y sale, o que hace exec, no deja estado y update lo informa. Devuelve el comando sin
+// cambios si no hay sesión.
+func (s *terminalSession) wrap(command string) (string, string) {
+	if s == nil || (runtime.GOOS == "windows" && strings.HasPrefix(command, "WSL ")) {
+		return command, ""
+	}
+	f, err := os.CreateTemp("", "airide-session-*")
+	if err != nil {
+		fmt.Printf("[BACK] Could not create session state file: %v\n", err)
+		return command, ""
+	}
+	stateFile := f.Name()
+	f.Close()
+	if runtime.GOOS == "windows" {
+		save := "[IO.File]::WriteAllText('" + strings.ReplaceAll(stateFile, "'", "''") + "', $PWD.Path + [char]0 + ((Get-ChildItem env: | ForEach-Object { $_.Name + '=' + $_.Value }) -join [char]0))"
+		return "try {\n" + command + "\n} finally { " + save + " }; if ($LASTEXITCODE) { exit $LASTEXITCODE }", stateFile
+	}
+	save := "{ printf \"%s\\0\" \"$PWD\"; env -0; } > \"" + stateFile + "\""
+	return "trap '" + save + "' EXIT\n" + command + "\n__airide_status=$?; " + save + "; exit $__airide_status", stateFile
+}
+
+// update lee el estado que dejó el comando y guarda en la sesión el nuevo directorio y las variables
+// que difieren del entorno con el que empezó (baseEnv). Las variables de carga de código nunca se guardan
+// y PATH solo cuando lo cambió un comando del usuario. Devuelve false si el comando no dejó estado
+// (exec, un trap EXIT propio seguido de exit, o lo mataron) y la sesión quedó como estaba.
+func (s *terminalSession) update(stateFile string, baseEnv []string, source string) bool {
+	if s == nil || stateFile == "" {
+		return true
+	}
+	data, err := os.ReadFile(stateFile)
+	os.Remove(stateFile)
+	if err != nil || len(data) == 0 {
+		fmt.Printf("[BACK] Terminal session %s: the command left no state; directory and environment were not updated\n", s.ID)
+		return false
+	}
+	parts := bytes.Split(bytes.TrimRight(data, "\x00\r\n"), []byte{0})
+	cwd := strings.TrimSpace(string(parts[0]))
+	before := map[string]string{}
+	for _, kv := range baseEnv {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			before[envKey(k)] = v
+		}
+	}
+	after := map[string][2]string{}
+	for _, kv := range parts[1:] {
+		k, v, ok := strings.Cut(string(kv), "=")
+		if !ok || k == "" || sessionIgnoredEnv[k] {
+			continue
+		}
+		if isLoaderEnv(k) || (source != "user" && strings.EqualFold(k, "PATH")) {
+			if old, ok := before[envKey(k)]; !ok || old != v {
+				fmt.Printf("[BACK] Terminal session %s: not keeping %s set by the command\n", s.ID, k)
+			}
+			continue
+		}
+		after[envKey(k)] = [2]string{k, v}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cwd != "" {
+		if info, err := os.Stat(cwd); err == nil && info.IsDir() {
+			s.Cwd = cwd
+		}
+	}
+	unset := map[string]bool{}
+	for _, k := range s.Unset {
+		unset[envKey(k)] = true
+	}
+	for key, kv := range after {
+		if old, ok := before[key]; !ok || old != kv[1] {
+			s.Env[kv[0]] = kv[1]
+			delete(unset, key)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok && !sessionIgnoredEnv[k] && !isLoaderEnv(k) && !(source != "user" && strings.EqualFold(k, "PATH")) {
+			for name := range s.Env {
+				if envKey(name) == k {
+					delete(s.Env, name)
+				}
+			}
+			unset[k] = true
+		}
+	}
+	s.Unset = s.Unset[:0]
+	for k := range unset {
+		s.Unset = append(s.Unset, k)
+	}
+	sort.Strings(s.Unset)
+	return true
+}
+
+// sessionStateLostMessage explica por qué el comando no cambió el directorio ni el entorno de la sesión
+const sessionStateLostMessage = "The command replaced the shell (exec), overrode the EXIT trap before exiting or was killed; the session directory and environment were not updated"
+
+// snapshot copia la sesión para responder sin mostrar valores que parezcan secretos
+func (s *terminalSession) snapshot() *terminalSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := &terminalSession{ID: s.ID, Name: s.Name, Root: s.Root, Cwd: s.Cwd, Unset: append([]string{}, s.Unset...), Created: s.Created, LastUsed: s.LastUsed, Busy: s.Busy, Env: map[string]string{}}
+	for k, v := range s.Env {
+		c.Env[k] = maskedEnvValue(k, v)
+	}
+	return c
+}
+
+// TerminalHistoryEntry es un comando ejecutado desde la terminal, guardado en .airide/terminal_history.jsonl
+type TerminalHistoryEntry struct {
+	Time       time.Time `json:"time"`
+	Command    string    `json:"command"`
+	Cwd        string    `json:"cwd,omitempty"`
+	SessionID  string    `json:"sessionId,omitempty"`
+	Source     string    `json:"source"`
+	ExitCode   int       `json:"exitCode"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+var terminalHistoryMu sync.Mutex
+
+func terminalHistoryPath(root string) string {
+	return filepath.Join(root, workspaceConfigDir, terminalHistoryFileName)
+}
+
+// recordTerminalHistory añade el comando al historial del workspace y lo recorta si crece demasiado
+func recordTerminalHistory(root string, entry TerminalHistoryEntry) {
+	if root == "" || strings.TrimSpace(entry.Command) == "" {
+		return
+	}
+	entry.Time = time.Now()
+	entry.Source = normalizeCommandSource(entry.Source)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	terminalHistoryMu.Lock()
+	defer terminalHistoryMu.Unlock()
+	path := terminalHistoryPath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Printf("[BACK] Could not create terminal history dir: %v\n", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Printf("[BACK] Could not write terminal history: %v\n", err)
+		return
+	}
+	f.Write(append(line, '\n'))
+	info, _ := f.Stat()
+	f.Close()
+	if info != nil && info.Size() > maxTerminalHistoryBytes {
+		entries := readTerminalHistoryFile(path)
+		if len(entries) > maxTerminalHistoryEntries {
+			entries = entries[len(entries)-maxTerminalHistoryEntries:]
+		}
+		var buf bytes.Buffer
+		for _, e := range entries {
+			data, _ := json.Marshal(e)
+			buf.Write(append(data, '\n'))
+		}
+		if err := atomicWriteFile(path, buf.Bytes(), 0644); err != nil {
+			fmt.Printf("[BACK] Could not trim terminal history: %v\n", err)
+		}
+	}
+}
+
+// readTerminalHistoryFile devuelve las entradas en orden cronológico, saltando líneas dañadas
+func readTerminalHistoryFile(path string) []TerminalHistoryEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var entries []TerminalHistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e TerminalHistoryEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// searchTerminalHistory busca en el historial (de más reciente a más antiguo) los comandos que contienen query;
+// con unique solo se devuelve la última ejecución de cada comando
+func searchTerminalHistory(root, query, sessionID, source string, unique bool, limit int) []TerminalHistoryEntry {
+	terminalHistoryMu.Lock()
+	entries := readTerminalHistoryFile(terminalHistoryPath(root))
+	terminalHistoryMu.Unlock()
+	query = strings.ToLower(query)
+	seen := map[string]bool{}
+	var res []TerminalHistoryEntry
+	for i := len(entries) - 1; i >= 0 && len(res) < limit; i-- {
+		e := entries[i]
+		if query != "" && !strings.Contains(strings.ToLower(e.Command), query) {
+			continue
+		}
+		if (sessionID != "" && e.SessionID != sessionID) || (source != "" && e.Source != source) {
+			continue
+		}
+		if unique {
+			if seen[e.Command] {
+				continue
+			}
+			seen[e.Command] = true
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+type TerminalSessionRequest struct {
+	Operation      string            `json:"operation"` // "create" o "close"
+	ID             string            `json:"id,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Cwd            string            `json:"cwd,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	ProjectBaseDir string            `json:"projectBaseDir,omitempty"`
+}
+
+type TerminalSessionResponse struct {
+	Success  bool               `json:"success"`
+	Message  string             `json:"message"`
+	Session  *terminalSession   `json:"session,omitempty"`
+	Sessions []*terminalSession `json:"sessions,omitempty"`
+}
+
+// terminalSessionsHandler lista (GET) y crea o cierra (POST) las sesiones de terminal con nombre
+func terminalSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var resp TerminalSessionResponse
+	switch r.Method {
+	case "GET":
+		root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+		terminalSessionsMu.Lock()
+		for _, s := range terminalSessions {
+			if s.Root == root {
+				resp.Sessions = append(resp.Sessions, s.snapshot())
+			}
+		}
+		terminalSessionsMu.Unlock()
+		sort.Slice(resp.Sessions, func(i, j int) bool { return resp.Sessions[i].Created.Before(resp.Sessions[j].Created) })
+		resp.Success, resp.Message = true, fmt.Sprintf("%d sessions", len(resp.Sessions))
+	case "POST":
+		var req TerminalSessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		switch req.Operation {
+		case "create":
+			s, err := createTerminalSession(req.Name, req.Cwd, req.ProjectBaseDir, req.Env)
+			if err != nil {
+				resp = TerminalSessionResponse{Success: false, Message: err.Error()}
+			} else {
+				fmt.Printf("[BACK] Terminal session %s created in %s\n", s.ID, s.Cwd)
+				resp = TerminalSessionResponse{Success: true, Message: "Session created", Session: s.snapshot()}
+			}
+		case "close":
+			terminalSessionsMu.Lock()
+			_, ok := terminalSessions[req.ID]
+			delete(terminalSessions, req.ID)
+			terminalSessionsMu.Unlock()
+			if ok {
+				resp = TerminalSessionResponse{Success: true, Message: "Session closed"}
+			} else {
+				resp = TerminalSessionResponse{Success: false, Message: "terminal session not found: " + req.ID}
+			}
+		default:
+			resp = TerminalSessionResponse{Success: false, Message: "Unknown session operation: " + req.Operation}
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// terminalHistoryHandler busca en el historial (GET ?q=&sessionId=&source=&unique=&limit=) o lo borra (POST clear)
+func terminalHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var resp interface{}
+	switch r.Method {
+	case "GET":
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		if limit <= 0 {
+			limit = defaultHistoryLimit
+		}
+		root := workspaceRoot(q.Get("projectBaseDir"))
+		entries := searchTerminalHistory(root, q.Get("q"), q.Get("sessionId"), q.Get("source"), q.Get("unique") == "true", limit)
+		if entries == nil {
+			entries = []TerminalHistoryEntry{}
+		}
+		resp = map[string]interface{}{"success": true, "entries": entries}
+	case "POST":
+		var req struct {
+			Operation      string `json:"operation"`
+			ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Operation != "clear" {
+			resp = map[string]interface{}{"success": false, "message": "Unknown history operation: " + req.Operation}
+			break
+		}
+		terminalHistoryMu.Lock()
+		err := os.Remove(terminalHistoryPath(workspaceRoot(req.ProjectBaseDir)))
+		terminalHistoryMu.Unlock()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			resp = map[string]interface{}{"success": false, "message": "Error clearing history: " + err.Error()}
+		} else {
+			resp = map[string]interface{}{"success": true, "message": "History cleared"}
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
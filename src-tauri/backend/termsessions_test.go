@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSessionWrapKeepsState(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the session wrapper for PowerShell uses try/finally")
+	}
+	tests := []struct {
+		name      string
+		command   string
+		wantSaved bool
+		wantCwd   string // relativo a la raíz; "" es la raíz
+		wantEnv   string
+		wantExit  int
+	}{
+		{"plain command", "cd sub && export FOO=1", true, "sub", "1", 0},
+		{"exit keeps trap", "cd sub; export FOO=2; exit 3", true, "sub", "2", 3},
+		{"own exit trap", "trap 'true' EXIT; cd sub; export FOO=3", true, "sub", "3", 0},
+		{"failing command", "cd sub; false", true, "sub", "", 1},
+		{"own exit trap then exit", "trap 'true' EXIT; cd sub; exit 4", false, "", "", 4},
+		{"exec", "cd sub; exec true", false, "", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			s := &terminalSession{ID: "test", Root: root, Cwd: root, Env: map[string]string{}}
+			env := os.Environ()
+			command, stateFile := s.wrap(tt.command)
+			cmd := buildShellCommand(context.Background(), command, root, env)
+			cmd.Run()
+			if code := cmd.ProcessState.ExitCode(); code != tt.wantExit {
+				t.Errorf("exit code = %d, want %d", code, tt.wantExit)
+			}
+			if saved := s.update(stateFile, env, "user"); saved != tt.wantSaved {
+				t.Errorf("update() = %v, want %v", saved, tt.wantSaved)
+			}
+			if want := filepath.Join(root, tt.wantCwd); s.Cwd != want {
+				t.Errorf("cwd = %q, want %q", s.Cwd, want)
+			}
+			if s.Env["FOO"] != tt.wantEnv {
+				t.Errorf("FOO = %q, want %q", s.Env["FOO"], tt.wantEnv)
+			}
+			if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+				t.Errorf("state file was not removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateTerminalSessionRejectsProtectedEnv(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"PATH", "Path", "BASH_ENV", "LD_PRELOAD"} {
+		if s, err := createTerminalSession("", "", root, map[string]string{name: "./evil"}); err == nil {
+			terminalSessionsMu.Lock()
+			delete(terminalSessions, s.ID)
+			terminalSessionsMu.Unlock()
+			t.Errorf("session created with %s in its env", name)
+		}
+	}
+	s, err := createTerminalSession("", "", root, map[string]string{"NODE_ENV": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	terminalSessionsMu.Lock()
+	delete(terminalSessions, s.ID)
+	terminalSessionsMu.Unlock()
+	if s.Env["NODE_ENV"] != "test" {
+		t.Errorf("session env = %v", s.Env)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// allowedOrigins son los orígenes desde los que corre el frontend: la ventana de Tauri (macOS/Linux y Windows)
+// y el servidor de desarrollo de Vite
+var allowedOrigins = []string{
+	"tauri://localhost",
+	"http://tauri.localhost",
+	"https://tauri.localhost",
+	"http://localhost:5173",
+	"http://127.0.0.1:5173",
+}
+
+// checkOrigin rechaza las conexiones de otras páginas abiertas en el navegador. Las peticiones sin Origin no
+// vienen de un navegador (herramientas de la IA, curl) y pasan como en el resto de la API.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range allowedOrigins {
+		if strings.EqualFold(origin, o) {
+			return true
+		}
+	}
+	return false
+}
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 32 * 1024,
+	CheckOrigin:     checkOrigin,
+}
+
+// TerminalStreamEvent es cada mensaje enviado mientras corre el comando
+type TerminalStreamEvent struct {
+	Type       string `json:"type"` // "stdout", "stderr", "exit", "denied" o "error"
+	Data       string `json:"data,omitempty"`
+	ExitCode   *int   `json:"exitCode,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	Message    string `json:"message,omitempty"`
+	// ConfirmationToken llega en el evento "denied" cuando el comando se puede ejecutar tras confirmarlo
+	ConfirmationToken string `json:"confirmationToken,omitempty"`
+	// Problems llega en el evento "exit" con lo que reconocieron los problem matchers
+	Problems []Diagnostic `json:"problems,omitempty"`
+	// Cwd llega en el evento "exit" de los comandos de una sesión con el directorio en el que quedó
+	Cwd string `json:"cwd,omitempty"`
+	// SessionStateLost llega en el evento "exit" si el comando no dejó el estado de la sesión
+	SessionStateLost bool `json:"sessionStateLost,omitempty"`
+}
+
+// syncWriter serializa las escrituras de stdout y stderr, que llegan desde goroutines distintas
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// streamWriter reenvía cada escritura del proceso como un evento sin cortar caracteres UTF-8
+type streamWriter struct {
+	kind    string
+	send    func(TerminalStreamEvent)
+	pending []byte
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	sw.pending = append(sw.pending, p...)
+	chunk := trimToRuneBoundary(sw.pending)
+	if len(chunk) > 0 {
+		sw.send(TerminalStreamEvent{Type: sw.kind, Data: string(chunk)})
+	}
+	sw.pending = append([]byte{}, sw.pending[len(chunk):]...)
+	return len(p), nil
+}
+
+// flush envía lo que haya quedado pendiente al terminar el proceso
+func (sw *streamWriter) flush() {
+	if len(sw.pending) > 0 {
+		sw.send(TerminalStreamEvent{Type: sw.kind, Data: string(sw.pending)})
+		sw.pending = nil
+	}
+}
+
+// runStreamingCommand ejecuta el comando enviando stdout/stderr a medida que se producen y, al final, el evento exit
+func runStreamingCommand(ctx context.Context, channel string, req TerminalRequest, send func(TerminalStreamEvent)) {
+	if req.Command == "" {
+		send(TerminalStreamEvent{Type: "error", Message: "No command provided"})
+		return
+	}
+	if err := checkEnvOverrides(req.Env); err != nil {
+		send(TerminalStreamEvent{Type: "error", Message: err.Error()})
+		return
+	}
+	session, err := acquireTerminalSession(req.SessionID)
+	if err != nil {
+		send(TerminalStreamEvent{Type: "error", Message: err.Error()})
+		return
+	}
+	defer session.release()
+	req = session.apply(req)
+	root := terminalWorkspaceRoot(req)
+	auth := authorizeCommand(root, channel, req)
+	if !auth.Allowed {
+		send(TerminalStreamEvent{Type: "denied", Message: auth.Message, ConfirmationToken: auth.ConfirmationToken})
+		return
+	}
+	// La salida no se acumula, así que aquí solo aplica el tiempo máximo
+	timeout, _ := terminalLimits(req)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	source := normalizeCommandSource(req.Source)
+	env := scrubEnv(session.commandEnv(root, req.Env, source), auth.policy, source)
+	shellCommand, stateFile := session.wrap(req.Command)
+	cmd := buildShellCommand(ctx, shellCommand, req.WorkingDir, env)
+	killGroupOnCancel(cmd)
+	stdout := &streamWriter{kind: "stdout", send: send}
+	stderr := &streamWriter{kind: "stderr", send: send}
+	// Copia acotada de la salida para los problem matchers
+	output := &cappedBuffer{limit: problemOutputLimit}
+	capture := &syncWriter{w: output}
+	cmd.Stdout = io.MultiWriter(stdout, capture)
+	cmd.Stderr = io.MultiWriter(stderr, capture)
+
+	start := time.Now()
+	err = cmd.Run()
+	stateSaved := session.update(stateFile, env, normalizeCommandSource(req.Source))
+	stdout.flush()
+	stderr.flush()
+
+	exit := TerminalStreamEvent{Type: "exit", DurationMs: time.Since(start).Milliseconds()}
+	code := 0
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+		if ctx.Err() == context.DeadlineExceeded {
+			exit.Message = fmt.Sprintf("Command timed out after %v and was killed", timeout)
+		} else if ctx.Err() != nil {
+			exit.Message = "Command cancelled"
+		}
+	default:
+		code = -1
+		exit.Message = "Command execution failed: " + err.Error()
+	}
+	exit.ExitCode = &code
+	exit.Problems = recordProblems(root, req.WorkingDir, req.Command, output.String())
+	exit.Cwd = session.currentDir()
+	if !stateSaved {
+		exit.SessionStateLost = true
+		if exit.Message == "" {
+			exit.Message = sessionStateLostMessage
+		}
+	}
+	recordAudit(auth.root, AuditEntry{Source: req.Source, Channel: channel, Command: req.Command, WorkingDir: req.WorkingDir, Decision: auth.decision(), Rule: auth.Rule, ExitCode: &code, DurationMs: exit.DurationMs})
+	// Las tareas ya tienen su propia lista; el historial es para lo que se escribe en la terminal
+	if channel != "task" {
+		recordTerminalHistory(root, TerminalHistoryEntry{Command: req.Command, Cwd: req.WorkingDir, SessionID: req.SessionID, Source: req.Source, ExitCode: code, DurationMs: exit.DurationMs})
+	}
+	fmt.Printf("[BACK] Streamed command '%s' exited with code %d after %dms\n", req.Command, code, exit.DurationMs)
+	send(exit)
+}
+
+// terminalStreamHandler transmite la salida de un comando por WebSocket o, si no se pide upgrade, por SSE.
+// Por WebSocket el primer mensaje del cliente es el TerminalRequest y {"type":"kill"} cancela el comando;
+// por SSE el TerminalRequest va en el cuerpo de un POST y cerrar la conexión lo cancela. Por GET no se
+// aceptan comandos: cualquier página puede disparar un GET (una imagen, un enlace) sin mandar Origin.
+func terminalStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		terminalWebSocket(w, r)
+		return
+	}
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	var req TerminalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	fmt.Printf("[BACK] /terminal/stream (SSE) command='%s', workingDir='%s'\n", req.Command, req.WorkingDir)
+	streamCommandSSE(w, r, "stream", req)
+}
+
+// streamCommandSSE ejecuta el comando enviando cada evento como Server-Sent Event
+func streamCommandSSE(w http.ResponseWriter, r *http.Request, channel string, req TerminalRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	req.Source = requestSource(r, req.Source)
+	var mu sync.Mutex
+	runStreamingCommand(r.Context(), channel, req, func(ev TerminalStreamEvent) {
+		payload, _ := json.Marshal(ev)
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, payload)
+		flusher.Flush()
+	})
+}
+
+func terminalWebSocket(w http.ResponseWriter, r *http.Request) {
+	streamCommandWebSocket(w, r, "stream", func(conn *websocket.Conn) (TerminalRequest, error) {
+		var req TerminalRequest
+		err := conn.ReadJSON(&req)
+		if err == nil {
+			fmt.Printf("[BACK] /terminal/stream (WebSocket) command='%s', workingDir='%s'\n", req.Command, req.WorkingDir)
+		}
+		return req, err
+	})
+}
+
+// streamCommandWebSocket obtiene el comando con readRequest (el primer mensaje del cliente), lo ejecuta
+// enviando los eventos como JSON y lo cancela si llega {"type":"kill"} o se cierra el socket
+func streamCommandWebSocket(w http.ResponseWriter, r *http.Request, channel string, readRequest func(*websocket.Conn) (TerminalRequest, error)) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Println("[BACK] WebSocket upgrade failed:", err)
+		return
+	}
+	defer conn.Close()
+
+	req, err := readRequest(conn)
+	if err != nil {
+		conn.WriteJSON(TerminalStreamEvent{Type: "error", Message: "Invalid request: " + err.Error()})
+		return
+	}
+	req.Source = requestSource(r, req.Source)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	// Lector de control: un "kill" o el cierre del socket terminan el proceso
+	go func() {
+		for {
+			var msg struct {
+				Type string `json:"type"`
+			}
+			if err := conn.ReadJSON(&msg); err != nil {
+				cancel()
+				return
+			}
+			if msg.Type == "kill" {
+				fmt.Printf("[BACK] Kill requested for '%s'\n", req.Command)
+				cancel()
+			}
+		}
+	}()
+
+	// gorilla/websocket no admite escrituras concurrentes, y stdout/stderr escriben desde goroutines distintas
+	var mu sync.Mutex
+	runStreamingCommand(ctx, channel, req, func(ev TerminalStreamEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		conn.WriteJSON(ev)
+	})
+	mu.Lock()
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	mu.Unlock()
+}
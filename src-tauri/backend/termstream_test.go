@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"tauri://localhost", true},
+		{"https://tauri.localhost", true},
+		{"http://localhost:5173", true},
+		{"https://evil.example", false},
+		{"http://localhost:8080", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/terminal/stream", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := checkOrigin(r); got != tt.want {
+			t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestStreamWriterKeepsRunesWhole(t *testing.T) {
+	var events []TerminalStreamEvent
+	sw := &streamWriter{kind: "stdout", send: func(ev TerminalStreamEvent) { events = append(events, ev) }}
+	// "ñ" y "€" llegan partidos entre dos escrituras del proceso
+	writes := []string{"línea 1\na\xc3", "\xb1o\n\xe2\x82", "\xac 10\n"}
+	for _, w := range writes {
+		if n, err := sw.Write([]byte(w)); err != nil || n != len(w) {
+			t.Fatalf("Write(%q) = %d, %v", w, n, err)
+		}
+	}
+	sw.flush()
+	var got strings.Builder
+	for _, ev := range events {
+		if ev.Type != "stdout" {
+			t.Errorf("event type = %q", ev.Type)
+		}
+		if !utf8.ValidString(ev.Data) {
+			t.Errorf("event splits a character: %q", ev.Data)
+		}
+		got.WriteString(ev.Data)
+	}
+	if want := strings.Join(writes, ""); got.String() != want {
+		t.Errorf("stream = %q, want %q", got.String(), want)
+	}
+	if len(events) != len(writes) {
+		t.Errorf("%d events for %d writes", len(events), len(writes))
+	}
+
+	// Lo que quede incompleto al terminar se envía igual
+	events = nil
+	sw.Write([]byte("x\xe2\x82"))
+	sw.flush()
+	if len(events) != 2 || events[1].Data != "\xe2\x82" {
+		t.Errorf("flush events = %+v", events)
+	}
+}
+
+func TestTerminalStreamSSE(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, workspaceConfigDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"command":"printf 'a\\nb\\n'","workingDir":"` + filepath.ToSlash(root) + `"}`
+
+	w := httptest.NewRecorder()
+	terminalStreamHandler(w, httptest.NewRequest("GET", "/terminal/stream?command=ls", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+
+	r := httptest.NewRequest("POST", "/terminal/stream", strings.NewReader(body))
+	r.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	terminalStreamHandler(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("foreign origin status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w = httptest.NewRecorder()
+	terminalStreamHandler(w, httptest.NewRequest("POST", "/terminal/stream", strings.NewReader(body)))
+	frames := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+	var output strings.Builder
+	for i, frame := range frames {
+		lines := strings.Split(frame, "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") || !strings.HasPrefix(lines[1], "data: ") {
+			t.Fatalf("frame %d = %q", i, frame)
+		}
+		var ev TerminalStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &ev); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if ev.Type != strings.TrimPrefix(lines[0], "event: ") {
+			t.Errorf("frame %d: event %q with type %q", i, lines[0], ev.Type)
+		}
+		if ev.Type == "stdout" {
+			output.WriteString(ev.Data)
+		}
+		if i == len(frames)-1 && (ev.Type != "exit" || ev.ExitCode == nil || *ev.ExitCode != 0) {
+			t.Errorf("last event = %+v, want exit 0", ev)
+		}
+	}
+	if output.String() != "a\nb\n" {
+		t.Errorf("stdout = %q", output.String())
+	}
+}
+
+func TestTerminalStreamCancelsOnDisconnect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, workspaceConfigDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(terminalStreamHandler))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	if _, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example"}}); err == nil {
+		t.Error("WebSocket accepted from a foreign origin")
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"tauri://localhost"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(TerminalRequest{Command: "sleep 30", WorkingDir: root}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	conn.Close()
+
+	// Al cerrarse el socket el comando se cancela y queda en el historial mucho antes de los 30 s
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if entries := readTerminalHistoryFile(terminalHistoryPath(root)); len(entries) > 0 {
+			if entries[0].Command != "sleep 30" || entries[0].DurationMs >= 30_000 {
+				t.Errorf("history entry = %+v", entries[0])
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("command still running after the client disconnected")
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Tiempo máximo para que una herramienta conteste a --version
+	toolVersionTimeout = 5 * time.Second
+	// Cuánto se reutiliza una detección para el mismo PATH
+	toolchainCacheTTL = time.Minute
+)
+
+// toolSpec describe cómo encontrar una herramienta y preguntarle su versión
+type toolSpec struct {
+	Name     string
+	Commands []string // candidatos en orden; se usa el primero que exista
+	Args     []string
+}
+
+var knownTools = []toolSpec{
+	{Name: "go", Commands: []string{"go"}, Args: []string{"version"}},
+	{Name: "node", Commands: []string{"node"}, Args: []string{"--version"}},
+	{Name: "npm", Commands: []string{"npm"}, Args: []string{"--version"}},
+	{Name: "yarn", Commands: []string{"yarn"}, Args: []string{"--version"}},
+	{Name: "pnpm", Commands: []string{"pnpm"}, Args: []string{"--version"}},
+	{Name: "python", Commands: []string{"python3", "python", "py"}, Args: []string{"--version"}},
+	{Name: "pip", Commands: []string{"pip3", "pip"}, Args: []string{"--version"}},
+	{Name: "rustc", Commands: []string{"rustc"}, Args: []string{"--version"}},
+	{Name: "cargo", Commands: []string{"cargo"}, Args: []string{"--version"}},
+	{Name: "java", Commands: []string{"java"}, Args: []string{"-version"}},
+	{Name: "git", Commands: []string{"git"}, Args: []string{"--version"}},
+	{Name: "docker", Commands: []string{"docker"}, Args: []string{"--version"}},
+	{Name: "make", Commands: []string{"make"}, Args: []string{"--version"}},
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// ToolInfo es el resultado de buscar una herramienta en el PATH del workspace
+type ToolInfo struct {
+	Name    string `json:"name"`
+	Command string `json:"command,omitempty"`
+	Found   bool   `json:"found"`
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// InWorkspace indica que el ejecutable está dentro del workspace (node_modules/.bin, un venv...)
+	InWorkspace bool `json:"inWorkspace,omitempty"`
+}
+
+// ToolRequirement es una versión pedida por el workspace (go.mod, .nvmrc, engines...) y si se cumple
+type ToolRequirement struct {
+	Tool      string `json:"tool"`
+	Required  string `json:"required"`
+	Source    string `json:"source"`
+	Installed string `json:"installed,omitempty"`
+	Satisfied bool   `json:"satisfied"`
+	Message   string `json:"message,omitempty"`
+}
+
+type ToolchainResponse struct {
+	Success      bool              `json:"success"`
+	Message      string            `json:"message"`
+	Tools        []ToolInfo        `json:"tools"`
+	Requirements []ToolRequirement `json:"requirements"`
+	Unsatisfied  int               `json:"unsatisfied"`
+}
+
+var (
+	toolchainMu    sync.Mutex
+	toolchainCache = map[string]toolchainCacheEntry{}
+)
+
+type toolchainCacheEntry struct {
+	tools []ToolInfo
+	at    time.Time
+}
+
+// lookPathIn busca un ejecutable en los directorios de un PATH concreto (el del workspace, no el del backend).
+// El nombre no pasa nunca por una shell.
+func lookPathIn(name, pathList string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name != filepath.Base(name) {
+		return "", exec.ErrNotFound
+	}
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			continue
+		}
+		if path, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+			return path, nil
+		}
+	}
+	return "", exec.ErrNotFound
+}
+
+// toolVersion ejecuta la herramienta directamente (sin shell) y extrae la versión de su salida
+func toolVersion(path string, args []string, env []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), toolVersionTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, args...)
+	// Que go informe de la versión instalada en vez de intentar descargar la que pida un go.mod
+	cmd.Env = append(env, "GOTOOLCHAIN=local")
+	// java -version escribe en stderr
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+	version := versionPattern.FindString(string(out))
+	if version == "" {
+		return "", errors.New("could not parse version from: " + strings.TrimSpace(string(out)))
+	}
+	return version, nil
+}
+
+// insideWorkspace indica si el ejecutable, con los symlinks resueltos, es un archivo del workspace
+func insideWorkspace(root, path string) bool {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if resolvedRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolvedRoot
+	}
+	return isSubPath(root, path)
+}
+
+// detectToolchains busca todas las herramientas conocidas con el entorno del workspace. Los ejecutables que están
+// dentro del workspace los pudo poner cualquiera que escriba en él (un paquete de npm, la IA): solo se ejecutan
+// para preguntarles la versión si la petición viene de la interfaz (probeWorkspace).
+func detectToolchains(root string, refresh, probeWorkspace bool) []ToolInfo {
+	env := buildCommandEnv(root, nil)
+	pathList := env.get("PATH")
+	key := pathList
+	if probeWorkspace {
+		key += "\x00ui"
+	}
+	toolchainMu.Lock()
+	cached, ok := toolchainCache[key]
+	toolchainMu.Unlock()
+	if ok && !refresh && time.Since(cached.at) < toolchainCacheTTL {
+		return cached.tools
+	}
+
+	// Tampoco a través de las herramientas del sistema: npm, yarn o pnpm buscan node en el PATH
+	if !probeWorkspace {
+		env.withoutWorkspaceTools(root)
+	}
+	envList := env.list()
+	tools := make([]ToolInfo, len(knownTools))
+	var wg sync.WaitGroup
+	for i, spec := range knownTools {
+		wg.Add(1)
+		go func(i int, spec toolSpec) {
+			defer wg.Done()
+			info := ToolInfo{Name: spec.Name}
+			for _, name := range spec.Commands {
+				if path, err := lookPathIn(name, pathList); err == nil {
+					info.Command, info.Path, info.Found = name, path, true
+					break
+				}
+			}
+			if info.Found {
+				info.InWorkspace = insideWorkspace(root, info.Path)
+			}
+			if info.Found && info.InWorkspace && !probeWorkspace {
+				info.Error = "version not checked: the executable is inside the workspace"
+			} else if info.Found {
+				version, err := toolVersion(info.Path, spec.Args, envList)
+				info.Version = version
+				if err != nil {
+					info.Error = err.Error()
+				}
+			}
+			tools[i] = info
+		}(i, spec)
+	}
+	wg.Wait()
+
+	toolchainMu.Lock()
+	toolchainCache[key] = toolchainCacheEntry{tools: tools, at: time.Now()}
+	toolchainMu.Unlock()
+	return tools
+}
+
+// workspaceRequirements lee las versiones que pide el proyecto: la directiva go de go.mod,
+// .nvmrc, .python-version y "engines" de package.json
+func workspaceRequirements(root string) []ToolRequirement {
+	var reqs []ToolRequirement
+	if f, err := os.Open(filepath.Join(root, "go.mod")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 2 && fields[0] == "go" {
+				reqs = append(reqs, ToolRequirement{Tool: "go", Required: ">=" + fields[1], Source: "go.mod"})
+				break
+			}
+		}
+		f.Close()
+	}
+	if data, err := os.ReadFile(filepath.Join(root, ".nvmrc")); err == nil {
+		if wanted := strings.TrimSpace(string(data)); wanted != "" {
+			reqs = append(reqs, ToolRequirement{Tool: "node", Required: strings.TrimPrefix(wanted, "v"), Source: ".nvmrc"})
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(root, ".python-version")); err == nil {
+		if wanted := strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0]); wanted != "" {
+			reqs = append(reqs, ToolRequirement{Tool: "python", Required: wanted, Source: ".python-version"})
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil {
+		var pkg struct {
+			Engines map[string]string `json:"engines"`
+		}
+		if json.Unmarshal(data, &pkg) == nil {
+			for _, tool := range []string{"node", "npm", "yarn", "pnpm"} {
+				if rng, ok := pkg.Engines[tool]; ok {
+					reqs = append(reqs, ToolRequirement{Tool: tool, Required: rng, Source: "package.json engines"})
+				}
+			}
+		}
+	}
+	return reqs
+}
+
+// checkRequirements compara cada requisito con la versión instalada
+func checkRequirements(reqs []ToolRequirement, tools []ToolInfo) int {
+	installed := map[string]ToolInfo{}
+	for _, t := range tools {
+		installed[t.Name] = t
+	}
+	unsatisfied := 0
+	for i := range reqs {
+		r := &reqs[i]
+		tool := installed[r.Tool]
+		r.Installed = tool.Version
+		switch {
+		case !tool.Found:
+			r.Message = r.Tool + " is not installed or not in PATH"
+		case tool.Version == "" && tool.InWorkspace:
+			// No se ejecutó (ver detectToolchains): no se sabe si cumple, no se marca como error
+			r.Satisfied = true
+			r.Message = "Requirement not checked: " + r.Tool + " is inside the workspace"
+			continue
+		case tool.Version == "":
+			r.Message = "Could not determine the installed " + r.Tool + " version"
+		default:
+			ok, understood := versionSatisfies(tool.Version, r.Required)
+			if !understood {
+				// Alias como "lts/*" o nombres de entornos: no se pueden comprobar, no se marcan como error
+				r.Satisfied = true
+				r.Message = "Requirement not checked"
+				continue
+			}
+			r.Satisfied = ok
+			if !ok {
+				r.Message = r.Tool + " " + tool.Version + " does not satisfy " + r.Required + " (" + r.Source + ")"
+			}
+		}
+		if !r.Satisfied {
+			unsatisfied++
+		}
+	}
+	return unsatisfied
+}
+
+// versionSatisfies comprueba una versión contra un rango al estilo npm: "1.22", ">=18", "^9.1", "~3.11",
+// "18.x", ">=16 <21", "a - b" y alternativas con "||". El segundo valor es false si el rango no se entiende.
+func versionSatisfies(version, rng string) (bool, bool) {
+	v := parseVersion(version)
+	rng = strings.TrimSpace(rng)
+	if rng == "" || rng == "*" || rng == "x" {
+		return true, true
+	}
+	for _, alt := range strings.Split(rng, "||") {
+		fields := strings.Fields(alt)
+		if len(fields) == 3 && fields[1] == "-" {
+			fields = []string{">=" + fields[0], "<=" + fields[2]}
+		}
+		// ">= 18" con espacio: unir el operador con su versión
+		var comparators []string
+		for i := 0; i < len(fields); i++ {
+			if strings.Trim(fields[i], "<>=^~") == "" && i+1 < len(fields) {
+				comparators = append(comparators, fields[i]+fields[i+1])
+				i++
+				continue
+			}
+			comparators = append(comparators, fields[i])
+		}
+		all := true
+		for _, c := range comparators {
+			ok, understood := versionMatchesComparator(v, c)
+			if !understood {
+				return false, false
+			}
+			all = all && ok
+		}
+		if all {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func versionMatchesComparator(v []int, c string) (bool, bool) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(c, prefix) {
+			op = prefix
+			break
+		}
+	}
+	spec := strings.TrimPrefix(strings.TrimPrefix(c, op), "v")
+	if spec == "" || spec == "*" || spec == "x" || spec == "X" {
+		return true, true
+	}
+	// Partes fijadas antes del primer comodín ("18.x" -> [18])
+	var want []int
+	for _, part := range strings.Split(spec, ".") {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		nums := parseVersion(part)
+		if len(nums) != 1 || part[0] < '0' || part[0] > '9' {
+			return false, false
+		}
+		want = append(want, nums[0])
+	}
+	if len(want) == 0 {
+		return true, true
+	}
+	prefixEqual := func(n int) bool {
+		for i := 0; i < n && i < len(want); i++ {
+			if i >= len(v) || v[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+	cmp := compareVersions(v, want)
+	switch op {
+	case ">=":
+		return cmp >= 0, true
+	case ">":
+		return cmp > 0 && !prefixEqual(len(want)), true
+	case "<=":
+		return cmp <= 0 || prefixEqual(len(want)), true
+	case "<":
+		return cmp < 0, true
+	case "^":
+		// Fija hasta la primera parte distinta de cero: ^1.2 -> 1.x, ^0.2 -> 0.2.x, ^0.0.3 -> 0.0.3
+		n := len(want)
+		for i, part := range want {
+			if part != 0 {
+				n = i + 1
+				break
+			}
+		}
+		return cmp >= 0 && prefixEqual(n), true
+	case "~":
+		n := 2
+		if len(want) == 1 {
+			n = 1
+		}
+		return cmp >= 0 && prefixEqual(n), true
+	default:
+		// "18" o "=3.11": las partes indicadas tienen que coincidir
+		return prefixEqual(len(want)), true
+	}
+}
+
+// toolchainHandler informa de las herramientas instaladas y de los requisitos del workspace que no se cumplen
+func toolchainHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	root := workspaceRoot(q.Get("projectBaseDir"))
+	tools := detectToolchains(root, q.Get("refresh") == "true", isUIRequest(r))
+	reqs := workspaceRequirements(root)
+	unsatisfied := checkRequirements(reqs, tools)
+	resp := ToolchainResponse{Success: true, Message: "Toolchains detected", Tools: tools, Requirements: reqs, Unsatisfied: unsatisfied}
+	if reqs == nil {
+		resp.Requirements = []ToolRequirement{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// checkCommandHandler dice si un ejecutable existe en el PATH del workspace.
+// Solo acepta un nombre de programa, que se busca con exec.LookPath sin pasar por ninguna shell.
+func checkCommandHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Command        string `json:"command"`
+		ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Command)
+	// Por compatibilidad, "python3 --version" se trata como "python3"
+	if fields := strings.Fields(name); len(fields) > 0 {
+		name = fields[0]
+	}
+	response := map[string]interface{}{"command": req.Command, "exists": false, "path": ""}
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) {
+		response["error"] = "command must be a program name"
+	} else {
+		env := buildCommandEnv(workspaceRoot(req.ProjectBaseDir), nil)
+		if path, err := lookPathIn(name, env.get("PATH")); err == nil {
+			response["exists"] = true
+			response["path"] = path
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVersionSatisfies(t *testing.T) {
+	tests := []struct {
+		version, rng     string
+		want, understood bool
+	}{
+		{"1.22.3", "", true, true},
+		{"1.22.3", "*", true, true},
+		{"1.22.3", ">=1.21", true, true},
+		{"1.20.14", ">=1.21", false, true},
+		{"go1.21.0", ">=1.21.0", true, true},
+		{"v20.11.0", "20", true, true},
+		{"v21.0.0", "20", false, true},
+		{"20.11.0", "v20.11.0", true, true},
+		{"20.11.1", "=20.11.0", false, true},
+		{"18.19.0", "18.x", true, true},
+		{"19.0.0", "18.x", false, true},
+		{"20.1.0", ">=16 <21", true, true},
+		{"21.0.0", ">=16 <21", false, true},
+		{"16.0.0", ">= 16", true, true},
+		{"18.9.9", ">18", false, true},
+		{"19.0.0", ">18", true, true},
+		{"20.9.9", "<=20", true, true},
+		{"21.0.0", "<=20", false, true},
+		{"9.8.1", "^9.1", true, true},
+		{"10.0.0", "^9.1", false, true},
+		{"9.0.9", "^9.1", false, true},
+		{"0.2.9", "^0.2.3", true, true},
+		{"0.3.0", "^0.2.3", false, true},
+		{"0.0.3", "^0.0.3", true, true},
+		{"0.0.4", "^0.0.3", false, true},
+		{"3.11.9", "~3.11", true, true},
+		{"3.12.0", "~3.11", false, true},
+		{"1.9.0", "~1", true, true},
+		{"2.3.9", "1.2 - 2.3", true, true},
+		{"2.4.0", "1.2 - 2.3", false, true},
+		{"1.1.0", "1.2 - 2.3", false, true},
+		{"20.0.0", "18.x || 20.x", true, true},
+		{"19.0.0", "18.x || 20.x", false, true},
+		{"20.0.0", "lts/*", false, false},
+		{"3.11.0", "pypy3.9", false, false},
+	}
+	for _, tt := range tests {
+		ok, understood := versionSatisfies(tt.version, tt.rng)
+		if ok != tt.want || understood != tt.understood {
+			t.Errorf("versionSatisfies(%q, %q) = %v, %v; want %v, %v", tt.version, tt.rng, ok, understood, tt.want, tt.understood)
+		}
+	}
+}
+
+func TestCheckRequirements(t *testing.T) {
+	reqs := []ToolRequirement{
+		{Tool: "go", Required: ">=1.21", Source: "go.mod"},
+		{Tool: "node", Required: "lts/*", Source: ".nvmrc"},
+		{Tool: "python", Required: "3.12", Source: ".python-version"},
+		{Tool: "pnpm", Required: ">=8", Source: "package.json engines"},
+	}
+	tools := []ToolInfo{
+		{Name: "go", Found: true, Version: "1.22.1"},
+		{Name: "node", Found: true, Version: "20.11.0"},
+		{Name: "python", Found: true, Version: "3.11.4"},
+	}
+	if got := checkRequirements(reqs, tools); got != 2 {
+		t.Errorf("checkRequirements = %d unsatisfied, want 2", got)
+	}
+	want := []bool{true, true, false, false}
+	for i, r := range reqs {
+		if r.Satisfied != want[i] {
+			t.Errorf("%s %s satisfied = %v, want %v (%s)", r.Tool, r.Required, r.Satisfied, want[i], r.Message)
+		}
+	}
+}
+
+func TestDetectToolchainsSkipsWorkspaceBinaries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the fake tool")
+	}
+	root := t.TempDir()
+	marker := filepath.Join(root, "ran")
+	// Un "node" del proyecto que deja constancia de que se ejecutó
+	script := "#!/bin/sh\ntouch '" + marker + "'\necho v20.1.0\n"
+	writeTestFile(t, filepath.Join(root, "node_modules", ".bin", "node"), script)
+	if err := os.Chmod(filepath.Join(root, "node_modules", ".bin", "node"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	findNode := func(tools []ToolInfo) ToolInfo {
+		for _, tool := range tools {
+			if tool.Name == "node" {
+				return tool
+			}
+		}
+		t.Fatal("node not listed")
+		return ToolInfo{}
+	}
+
+	node := findNode(detectToolchains(root, true, false))
+	if !node.Found || !node.InWorkspace || node.Version != "" {
+		t.Errorf("node for the AI = %+v", node)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("workspace binary executed for a request without the UI token")
+	}
+	reqs := []ToolRequirement{{Tool: "node", Required: ">=18"}}
+	if n := checkRequirements(reqs, []ToolInfo{node}); n != 0 || !strings.Contains(reqs[0].Message, "not checked") {
+		t.Errorf("requirement = %+v", reqs[0])
+	}
+
+	node = findNode(detectToolchains(root, true, true))
+	if node.Version != "20.1.0" {
+		t.Errorf("node for the UI = %+v", node)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("workspace binary not probed for the UI")
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// La papelera vive dentro del workspace y usa el mismo formato que la del estándar freedesktop:
+// files/ guarda los elementos borrados e info/<nombre>.trashinfo su ruta original y fecha de borrado.
+const (
+	trashDirName    = "trash"
+	trashInfoExt    = ".trashinfo"
+	trashDateLayout = "2006-01-02T15:04:05"
+	maxUndoEntries  = 50
+)
+
+type TrashItem struct {
+	ID           string    `json:"id"`
+	OriginalPath string    `json:"originalPath"`
+	DeletedAt    time.Time `json:"deletedAt"`
+	IsDir        bool      `json:"isDir"`
+	Size         int64     `json:"size"`
+}
+
+type TrashRequest struct {
+	Operation      string `json:"operation"` // "restore", "purge" o "empty"
+	ID             string `json:"id,omitempty"`
+	Overwrite      bool   `json:"overwrite,omitempty"`
+	ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+}
+
+type TrashResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Items   []TrashItem `json:"items,omitempty"`
+	Path    string      `json:"path,omitempty"`
+}
+
+// trashDir devuelve la carpeta de papelera del workspace
+func trashDir(root string) string {
+	return filepath.Join(root, workspaceConfigDir, trashDirName)
+}
+
+// moveToTrash mueve la ruta a la papelera del workspace y devuelve el ID con el que se puede restaurar
+func moveToTrash(root, path string) (string, error) {
+	filesDir := filepath.Join(trashDir(root), "files")
+	infoDir := filepath.Join(trashDir(root), "info")
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(infoDir, 0700); err != nil {
+		return "", err
+	}
+	// Reservar un nombre único creando el .trashinfo con O_EXCL, como indica el estándar
+	base := filepath.Base(path)
+	id := base
+	var info *os.File
+	for i := 2; ; i++ {
+		f, err := os.OpenFile(filepath.Join(infoDir, id+trashInfoExt), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			info = f
+			break
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		id = base + "." + strconv.Itoa(i)
+	}
+	fmt.Fprintf(info, "[Trash Info]\nPath=%s\nDeletionDate=%s\n", (&url.URL{Path: filepath.ToSlash(path)}).EscapedPath(), time.Now().Format(trashDateLayout))
+	info.Close()
+	if err := movePath(path, filepath.Join(filesDir, id)); err != nil {
+		os.Remove(filepath.Join(infoDir, id+trashInfoExt))
+		return "", err
+	}
+	fmt.Printf("[BACK] Moved '%s' to trash as '%s'\n", path, id)
+	return id, nil
+}
+
+// readTrashInfo lee la ruta original y la fecha de borrado de un elemento
+func readTrashInfo(root, id string) (TrashItem, error) {
+	item := TrashItem{ID: id}
+	f, err := os.Open(filepath.Join(trashDir(root), "info", id+trashInfoExt))
+	if err != nil {
+		return item, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "Path="):
+			p, err := url.PathUnescape(strings.TrimPrefix(line, "Path="))
+			if err != nil {
+				return item, err
+			}
+			item.OriginalPath = filepath.FromSlash(p)
+		case strings.HasPrefix(line, "DeletionDate="):
+			item.DeletedAt, _ = time.ParseInLocation(trashDateLayout, strings.TrimPrefix(line, "DeletionDate="), time.Local)
+		}
+	}
+	if item.OriginalPath == "" {
+		return item, fmt.Errorf("invalid trash info for %s", id)
+	}
+	if st, err := os.Lstat(filepath.Join(trashDir(root), "files", id)); err == nil {
+		item.IsDir = st.IsDir()
+		item.Size = st.Size()
+	}
+	return item, nil
+}
+
+// listTrash devuelve los elementos de la papelera, los más recientes primero
+func listTrash(root string) []TrashItem {
+	entries, _ := os.ReadDir(filepath.Join(trashDir(root), "info"))
+	var items []TrashItem
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), trashInfoExt) {
+			continue
+		}
+		if item, err := readTrashInfo(root, strings.TrimSuffix(e.Name(), trashInfoExt)); err == nil {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items
+}
+
+// restoreFromTrash devuelve el elemento a su ruta original
+func restoreFromTrash(root, id string, overwrite bool) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid trash id: %q", id)
+	}
+	item, err := readTrashInfo(root, id)
+	if err != nil {
+		return "", err
+	}
+	replacedID := ""
+	if _, err := os.Lstat(item.OriginalPath); err == nil {
+		if !overwrite {
+			return "", fmt.Errorf("a file already exists at %s", item.OriginalPath)
+		}
+		if replacedID, err = moveToTrash(root, item.OriginalPath); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(item.OriginalPath), 0755); err != nil {
+		restoreReplaced(root, replacedID)
+		return "", err
+	}
+	if err := movePath(filepath.Join(trashDir(root), "files", id), item.OriginalPath); err != nil {
+		restoreReplaced(root, replacedID)
+		return "", err
+	}
+	os.Remove(filepath.Join(trashDir(root), "info", id+trashInfoExt))
+	fmt.Printf("[BACK] Restored '%s' from trash\n", item.OriginalPath)
+	return item.OriginalPath, nil
+}
+
+// purgeFromTrash borra definitivamente un elemento de la papelera
+func purgeFromTrash(root, id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return fmt.Errorf("invalid trash id: %q", id)
+	}
+	if err := os.RemoveAll(filepath.Join(trashDir(root), "files", id)); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(trashDir(root), "info", id+trashInfoExt))
+}
+
+func trashHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var resp TrashResponse
+	switch r.Method {
+	case "GET":
+		root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+		resp = TrashResponse{Success: true, Message: "Trash listed successfully", Items: listTrash(root)}
+	case "POST":
+		var req TrashRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("[BACK] TrashRequest: %+v\n", req)
+		root := workspaceRoot(req.ProjectBaseDir)
+		// Purgar o vaciar borra para siempre lo guardado en .airide/trash: solo desde la interfaz
+		if (req.Operation == "purge" || req.Operation == "empty") && !isUIRequest(r) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(TrashResponse{Success: false, Message: workspaceConfigReadOnly})
+			return
+		}
+		switch req.Operation {
+		case "restore":
+			path, err := restoreFromTrash(root, req.ID, req.Overwrite)
+			if err != nil {
+				resp = TrashResponse{Success: false, Message: "Error restoring: " + err.Error()}
+			} else {
+				invalidateFileIndex(root)
+				resp = TrashResponse{Success: true, Message: "Restored successfully", Path: path}
+			}
+		case "purge":
+			if err := purgeFromTrash(root, req.ID); err != nil {
+				resp = TrashResponse{Success: false, Message: "Error purging: " + err.Error()}
+			} else {
+				resp = TrashResponse{Success: true, Message: "Item permanently deleted"}
+			}
+		case "empty":
+			if err := os.RemoveAll(trashDir(root)); err != nil {
+				resp = TrashResponse{Success: false, Message: "Error emptying trash: " + err.Error()}
+			} else {
+				resp = TrashResponse{Success: true, Message: "Trash emptied"}
+			}
+		default:
+			resp = TrashResponse{Success: false, Message: "Unknown trash operation: " + req.Operation}
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// undoEntry describe una operación destructiva que se puede revertir
+type undoEntry struct {
+	Operation string `json:"operation"` // "delete", "rename", "move", "copy" o "batch"; dentro de un lote también "create" y "write"
+	Path      string `json:"path"`
+	NewPath   string `json:"newPath,omitempty"`
+	TrashID   string `json:"trashId,omitempty"`
+	// ReplacedTrashID es el destino que se sobrescribió (y se mandó a la papelera) en un rename, move o copy
+	ReplacedTrashID string `json:"replacedTrashId,omitempty"`
+	// Revision es el objeto del historial con el contenido que un write de un lote reemplazó
+	Revision string `json:"revision,omitempty"`
+	// Entries son las operaciones de un lote en el orden en que se aplicaron; se deshacen al revés
+	Entries []undoEntry `json:"entries,omitempty"`
+	Time    time.Time   `json:"time"`
+	// seq ordena las entradas: una que no se pudo deshacer vuelve a su lugar aunque se hayan registrado otras
+	seq uint64
+}
+
+var (
+	undoMu     sync.Mutex
+	undoStacks = map[string][]undoEntry{}
+	undoSeq    uint64
+)
+
+// recordUndo agrega una operación a la pila de deshacer del workspace
+func recordUndo(root string, entry undoEntry) {
+	entry.Time = time.Now()
+	undoMu.Lock()
+	defer undoMu.Unlock()
+	undoSeq++
+	entry.seq = undoSeq
+	stack := append(undoStacks[root], entry)
+	if len(stack) > maxUndoEntries {
+		stack = stack[len(stack)-maxUndoEntries:]
+	}
+	undoStacks[root] = stack
+}
+
+// undoLast revierte la última operación registrada en el workspace
+func undoLast(root string) (undoEntry, error) {
+	undoMu.Lock()
+	stack := undoStacks[root]
+	if len(stack) == 0 {
+		undoMu.Unlock()
+		return undoEntry{}, fmt.Errorf("nothing to undo")
+	}
+	entry := stack[len(stack)-1]
+	undoStacks[root] = stack[:len(stack)-1]
+	undoMu.Unlock()
+
+	retry, err := revertUndoEntry(root, &entry)
+	if err != nil && retry {
+		// Vuelve a su posición original: las operaciones registradas mientras tanto siguen por encima
+		undoMu.Lock()
+		stack := undoStacks[root]
+		i := sort.Search(len(stack), func(i int) bool { return stack[i].seq > entry.seq })
+		stack = append(stack[:i], append([]undoEntry{entry}, stack[i:]...)...)
+		if len(stack) > maxUndoEntries {
+			stack = stack[len(stack)-maxUndoEntries:]
+		}
+		undoStacks[root] = stack
+		undoMu.Unlock()
+	} else if err != nil {
+		err = fmt.Errorf("%v (removed from the undo stack)", err)
+	}
+	return entry, err
+}
+
+// revertUndoEntry deshace una entrada. retry indica que el error se puede resolver (p. ej. liberando la ruta
+// original) y la entrada vuelve a la pila; si lo que había que restaurar ya no existe o se deshizo a medias,
+// la entrada se descarta. En un lote, entry queda solo con las operaciones que faltan deshacer.
+func revertUndoEntry(root string, entry *undoEntry) (retry bool, err error) {
+	switch entry.Operation {
+	case "delete":
+		if _, err = restoreFromTrash(root, entry.TrashID, false); err != nil {
+			retry = !errors.Is(err, fs.ErrNotExist)
+		}
+	case "rename", "move":
+		if _, statErr := os.Lstat(entry.Path); statErr == nil {
+			err = fmt.Errorf("cannot undo %s: %s already exists", entry.Operation, entry.Path)
+			retry = true
+		} else if err = movePath(entry.NewPath, entry.Path); err != nil {
+			retry = !errors.Is(err, fs.ErrNotExist)
+		} else if entry.ReplacedTrashID != "" {
+			if _, restoreErr := restoreFromTrash(root, entry.ReplacedTrashID, false); restoreErr != nil {
+				err = fmt.Errorf("%s undone, but the overwritten file could not be restored: %v", entry.Operation, restoreErr)
+			}
+		}
+	case "copy":
+		// Igual que create: la copia va a la papelera y vuelve lo que había en el destino
+		if _, err = moveToTrash(root, entry.NewPath); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		retry = err != nil
+		if err == nil && entry.ReplacedTrashID != "" {
+			if _, restoreErr := restoreFromTrash(root, entry.ReplacedTrashID, false); restoreErr != nil {
+				err = fmt.Errorf("copy undone, but the overwritten file could not be restored: %v", restoreErr)
+			}
+		}
+	case "create":
+		// El archivo creado va a la papelera en lugar de borrarse, por si se editó después
+		if _, err = moveToTrash(root, entry.Path); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		retry = err != nil
+	case "write":
+		var content []byte
+		if content, err = loadHistoryObject(root, entry.Revision); err == nil {
+			err = atomicWriteFile(entry.Path, content, 0644)
+			retry = err != nil
+		}
+	case "batch":
+		for i := len(entry.Entries) - 1; i >= 0; i-- {
+			if retry, err = revertUndoEntry(root, &entry.Entries[i]); err != nil {
+				entry.Entries = entry.Entries[:i+1]
+				return retry, fmt.Errorf("batch operation %d: %v", i, err)
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown operation %q", entry.Operation)
+	}
+	return retry, err
+}
+
+func undoHandler(w http.ResponseWriter, r *http.Request) {
+	enableCORS(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case "GET":
+		root := workspaceRoot(r.URL.Query().Get("projectBaseDir"))
+		undoMu.Lock()
+		stack := append([]undoEntry{}, undoStacks[root]...)
+		undoMu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "entries": stack})
+	case "POST":
+		var req struct {
+			ProjectBaseDir string `json:"projectBaseDir,omitempty"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		root := workspaceRoot(req.ProjectBaseDir)
+		entry, err := undoLast(root)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Undo failed: " + err.Error()})
+			return
+		}
+		invalidateFileIndex(root)
+		fmt.Printf("[BACK] Undid %s of '%s'\n", entry.Operation, entry.Path)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Undid " + entry.Operation, "entry": entry})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
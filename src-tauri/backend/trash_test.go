@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestTrashRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "dir", "a b%.txt")
+	writeTestFile(t, path, "one")
+	id1, err := moveToTrash(root, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, path, "two")
+	id2, err := moveToTrash(root, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id1 == id2 {
+		t.Fatalf("both trash entries got id %q", id1)
+	}
+	if items := listTrash(root); len(items) != 2 || items[0].OriginalPath != path {
+		t.Fatalf("listTrash = %+v", items)
+	}
+	if _, err := restoreFromTrash(root, id2, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restoreFromTrash(root, id1, false); err == nil {
+		t.Fatal("restore over an existing file succeeded without overwrite")
+	}
+	// Al sobrescribir, el archivo actual va a la papelera
+	if _, err := restoreFromTrash(root, id1, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, path); got != "one" {
+		t.Errorf("restored content = %q, want %q", got, "one")
+	}
+	if items := listTrash(root); len(items) != 1 {
+		t.Errorf("trash has %d items, want the overwritten file", len(items))
+	}
+	for _, id := range []string{"", ".", "..", "../x", `a\\b`} {
+		if _, err := restoreFromTrash(root, id, false); err == nil {
+			t.Errorf("restoreFromTrash(%q) succeeded", id)
+		}
+	}
+}
+
+func TestUndoDropsUnrecoverableEntries(t *testing.T) {
+	root := t.TempDir()
+	older := filepath.Join(root, "older.txt")
+	writeTestFile(t, older, "older")
+	olderID, err := moveToTrash(root, older)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordUndo(root, undoEntry{Operation: "delete", Path: older, TrashID: olderID})
+	newer := filepath.Join(root, "newer.txt")
+	writeTestFile(t, newer, "newer")
+	newerID, err := moveToTrash(root, newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordUndo(root, undoEntry{Operation: "delete", Path: newer, TrashID: newerID})
+
+	// Vaciar el elemento más reciente de la papelera: su deshacer falla, pero no bloquea los anteriores
+	if err := purgeFromTrash(root, newerID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := undoLast(root); err == nil || !strings.Contains(err.Error(), "removed from the undo stack") {
+		t.Fatalf("undo of a purged entry: err = %v", err)
+	}
+	if _, err := undoLast(root); err != nil {
+		t.Fatalf("older entry could not be undone: %v", err)
+	}
+	if got := readTestFile(t, older); got != "older" {
+		t.Errorf("older content = %q", got)
+	}
+}
+
+func TestUndoKeepsEntriesThatCanBeRetried(t *testing.T) {
+	root := t.TempDir()
+	old, renamed := filepath.Join(root, "old.txt"), filepath.Join(root, "new.txt")
+	writeTestFile(t, renamed, "content")
+	writeTestFile(t, old, "in the way")
+	recordUndo(root, undoEntry{Operation: "rename", Path: old, NewPath: renamed})
+	if _, err := undoLast(root); err == nil {
+		t.Fatal("undo succeeded over an existing file")
+	}
+	os.Remove(old)
+	if _, err := undoLast(root); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if got := readTestFile(t, old); got != "content" {
+		t.Errorf("content = %q", got)
+	}
+}
+
+func TestRestoreReplaced(t *testing.T) {
+	root := t.TempDir()
+	dst := filepath.Join(root, "dst.txt")
+	writeTestFile(t, dst, "keep me")
+	id, err := moveToTrash(root, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreReplaced(root, id)
+	if got := readTestFile(t, dst); got != "keep me" {
+		t.Errorf("content = %q", got)
+	}
+}
+
+func TestFailedUndoKeepsItsPosition(t *testing.T) {
+	root := t.TempDir()
+	old, renamed := filepath.Join(root, "old.txt"), filepath.Join(root, "new.txt")
+	writeTestFile(t, renamed, "content")
+	recordUndo(root, undoEntry{Operation: "delete", Path: filepath.Join(root, "first.txt"), TrashID: "first"})
+	recordUndo(root, undoEntry{Operation: "rename", Path: old, NewPath: renamed})
+	// Mientras se deshace el rename se registra otra operación, y el rename falla
+	renamePath = func(src, dst string) error {
+		recordUndo(root, undoEntry{Operation: "move", Path: filepath.Join(root, "a"), NewPath: filepath.Join(root, "b")})
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: syscall.EACCES}
+	}
+	t.Cleanup(func() { renamePath = os.Rename })
+	if _, err := undoLast(root); err == nil {
+		t.Fatal("undo succeeded with a failing rename")
+	}
+	var ops []string
+	for _, e := range undoStacks[root] {
+		ops = append(ops, e.Operation)
+	}
+	if want := []string{"delete", "rename", "move"}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("undo stack = %v, want %v", ops, want)
+	}
+}
+
+func TestCopyCanBeUndone(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "src.txt"), "new")
+	writeTestFile(t, filepath.Join(root, "dst.txt"), "old")
+	if resp := copyFileOrDir(root, "src.txt", "dst.txt", true); !resp.Success {
+		t.Fatal(resp.Message)
+	}
+	entry, err := undoLast(root)
+	if err != nil || entry.Operation != "copy" {
+		t.Fatalf("undo = %+v, %v", entry, err)
+	}
+	if got := readTestFile(t, filepath.Join(root, "dst.txt")); got != "old" {
+		t.Errorf("dst.txt = %q, want the overwritten content back", got)
+	}
+	if got := readTestFile(t, filepath.Join(root, "src.txt")); got != "new" {
+		t.Errorf("src.txt = %q", got)
+	}
+	// La copia quedó en la papelera
+	if items := listTrash(root); len(items) != 1 || items[0].OriginalPath != filepath.Join(root, "dst.txt") {
+		t.Errorf("trash = %+v", items)
+	}
+}